@@ -0,0 +1,73 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"go/token"
+	"io"
+	"os"
+
+	"github.com/libxyz/gizmo/parsing/jsontokenizer/schema"
+)
+
+// runInfer 执行 infer 子命令并返回退出码
+func runInfer(args []string, stdin io.Reader, stdout, stderr io.Writer) int {
+	fs := flag.NewFlagSet("jsontok infer", flag.ContinueOnError)
+	fs.SetOutput(stderr)
+	goSource := fs.Bool("go", false, "print Go type declarations instead of a JSON Schema")
+	pkg := fs.String("package", "main", "package `name` of the Go source")
+	typ := fs.String("type", "Root", "`name` of the Go type for the whole document")
+	maxEnum := fs.Int("enum", schema.DefaultMaxEnum, "report strings with at most `n` distinct values as an enumeration, none if 0")
+	if err := fs.Parse(args); err != nil {
+		return 2
+	}
+	if !token.IsIdentifier(*pkg) || !token.IsIdentifier(*typ) {
+		fmt.Fprintln(stderr, "jsontok: infer: -package and -type must be Go identifiers")
+		return 2
+	}
+	if *maxEnum <= 0 {
+		*maxEnum = -1
+	}
+
+	inf := schema.NewInferrer(schema.Options{MaxEnum: *maxEnum})
+	files := fs.Args()
+	if len(files) == 0 {
+		files = []string{"-"}
+	}
+	for _, name := range files {
+		if err := inferFile(inf, name, stdin); err != nil {
+			fmt.Fprintf(stderr, "jsontok: %s: %v\n", name, err)
+			return 1
+		}
+	}
+
+	var out []byte
+	var err error
+	if *goSource {
+		out, err = inf.GoSource(*pkg, *typ)
+	} else {
+		out, err = inf.JSONSchema()
+	}
+	if err == nil {
+		_, err = stdout.Write(out)
+	}
+	if err != nil {
+		fmt.Fprintf(stderr, "jsontok: %v\n", err)
+		return 1
+	}
+	return 0
+}
+
+// inferFile 将一个输入文件中的值加入样本，name 为 "-" 时读取标准输入
+func inferFile(inf *schema.Inferrer, name string, stdin io.Reader) error {
+	r := stdin
+	if name != "-" {
+		f, err := os.Open(name)
+		if err != nil {
+			return err
+		}
+		defer f.Close()
+		r = f
+	}
+	return inf.Add(r)
+}
@@ -0,0 +1,332 @@
+// Command jsontok prints the token stream produced by jsontokenizer for JSON
+// read from files or standard input.
+//
+// Usage:
+//
+//	jsontok [flags] [file ...]
+//	jsontok query [-c] [-r] filter [file ...]
+//	jsontok infer [-go] [-package name] [-type name] [-enum n] [file ...]
+//	jsontok profile [-sort key] [-json] [file ...]
+//
+// The query subcommand evaluates a jq-style filter, as supported by package
+// jsontokenizer/query, and prints each result. The infer subcommand treats
+// every JSON value of its input as a sample and prints a JSON Schema, or with
+// -go Go type declarations, inferred by package jsontokenizer/schema. The
+// profile subcommand prints the count, size, depth, longest string and types
+// of the values at every path, with array indexes collapsed to [*]. With no
+// files, or when a file is "-", jsontok reads standard input.
+package main
+
+import (
+	"bytes"
+	"cmp"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/libxyz/gizmo/parsing/jsontokenizer"
+)
+
+func main() {
+	os.Exit(run(os.Args[1:], os.Stdin, os.Stdout, os.Stderr))
+}
+
+// options 是命令行参数
+type options struct {
+	format  string
+	pattern string
+	values  bool
+	leaves  bool
+	runes   bool
+}
+
+// run 执行命令并返回退出码
+func run(args []string, stdin io.Reader, stdout, stderr io.Writer) int {
+	if len(args) > 0 && args[0] == "query" {
+		return runQuery(args[1:], stdin, stdout, stderr)
+	}
+	if len(args) > 0 && args[0] == "infer" {
+		return runInfer(args[1:], stdin, stdout, stderr)
+	}
+	if len(args) > 0 && args[0] == "profile" {
+		return runProfile(args[1:], stdin, stdout, stderr)
+	}
+	fs := flag.NewFlagSet("jsontok", flag.ContinueOnError)
+	fs.SetOutput(stderr)
+	var opts options
+	fs.StringVar(&opts.format, "format", "text", "output format: text or ndjson")
+	fs.StringVar(&opts.pattern, "path", "", "only print tokens at or below paths matching `pattern`, where * matches any key or index, e.g. $.users[*].name")
+	fs.BoolVar(&opts.values, "values", false, "only print value-level tokens: strings, numbers, booleans and nulls")
+	fs.BoolVar(&opts.leaves, "leaves", false, "print one `path = value` line per leaf value, like gron, with keys quoted as by jsontokenizer.Flatten; -format is ignored")
+	fs.BoolVar(&opts.runes, "runes", false, "print one token per character instead of one per lexical element")
+	if err := fs.Parse(args); err != nil {
+		return 2
+	}
+	if opts.format != "text" && opts.format != "ndjson" {
+		fmt.Fprintf(stderr, "jsontok: unknown format %q\n", opts.format)
+		return 2
+	}
+
+	files := fs.Args()
+	if len(files) == 0 {
+		files = []string{"-"}
+	}
+	status := 0
+	for _, name := range files {
+		if err := processFile(name, len(files) > 1, stdin, stdout, opts); err != nil {
+			fmt.Fprintf(stderr, "jsontok: %v\n", err)
+			status = 1
+		}
+	}
+	return status
+}
+
+// processFile 处理一个输入文件，name 为 "-" 时读取标准输入
+func processFile(name string, labeled bool, stdin io.Reader, stdout io.Writer, opts options) error {
+	r := stdin
+	if name != "-" {
+		f, err := os.Open(name)
+		if err != nil {
+			return err
+		}
+		defer f.Close()
+		r = f
+	}
+	label := ""
+	if labeled {
+		label = name
+	}
+
+	p := &printer{w: stdout, opts: opts, label: label}
+	switch {
+	case opts.leaves:
+		return p.leaves(r)
+	case opts.runes:
+		return p.runes(r)
+	}
+	return p.elements(r)
+}
+
+// printer 按选项输出Token
+type printer struct {
+	w      io.Writer
+	opts   options
+	label  string
+	offset int
+	err    error
+}
+
+// elements 按词法单元输出Token
+func (p *printer) elements(r io.Reader) error {
+	t := jsontokenizer.NewTokenizer()
+	t.Coalesce()
+	buf := make([]byte, 32<<10)
+	for {
+		n, err := r.Read(buf)
+		for _, tk := range t.Feed(buf[:n]) {
+			p.token(tk, len(tk.Raw))
+		}
+		if errors.Is(err, io.EOF) {
+			for _, tk := range t.Flush() {
+				p.token(tk, len(tk.Raw))
+			}
+			return p.err
+		}
+		if err != nil {
+			return err
+		}
+		if p.err != nil {
+			return p.err
+		}
+	}
+}
+
+// runes 逐字符输出Token，跨越读取边界的字符留到下一次读取后再解码
+func (p *printer) runes(r io.Reader) error {
+	t := jsontokenizer.NewTokenizer()
+	buf := make([]byte, 32<<10)
+	var pending []byte
+	for {
+		n, err := r.Read(buf)
+		eof := errors.Is(err, io.EOF)
+		data := append(pending, buf[:n]...)
+		for len(data) > 0 && (eof || utf8.FullRune(data)) {
+			c, size := utf8.DecodeRune(data)
+			data = data[size:]
+			if tk := t.Push(c); tk != nil {
+				p.token(*tk, size)
+			}
+		}
+		pending = append(pending[:0], data...)
+		if eof {
+			return p.err
+		}
+		if err != nil {
+			return err
+		}
+		if p.err != nil {
+			return p.err
+		}
+	}
+}
+
+// leaves 以 jsontokenizer.Flatten 的格式输出叶子值
+func (p *printer) leaves(r io.Reader) error {
+	err := jsontokenizer.Flatten(r, &leafWriter{p: p})
+	return cmp.Or(p.err, err)
+}
+
+// leafWriter 将 Flatten 的输出按行过滤后交给 printer
+type leafWriter struct {
+	p   *printer
+	buf []byte // 尚不完整的行
+}
+
+// Write 实现 io.Writer
+func (w *leafWriter) Write(b []byte) (int, error) {
+	w.buf = append(w.buf, b...)
+	for {
+		i := bytes.IndexByte(w.buf, '\n')
+		if i < 0 {
+			break
+		}
+		line := string(w.buf[:i])
+		w.buf = w.buf[i+1:]
+		if w.p.opts.pattern == "" || matchPath(w.p.opts.pattern, leafPath(line)) {
+			w.p.line(line)
+		}
+	}
+	return len(b), w.p.err
+}
+
+// leafPath 返回 path = value 形式的行中的路径，跳过带引号键名中的内容
+func leafPath(line string) string {
+	for i := 0; i < len(line); i++ {
+		switch {
+		case line[i] == '"':
+			i = skipString(line, i)
+		case strings.HasPrefix(line[i:], " = "):
+			return line[:i]
+		}
+	}
+	return line
+}
+
+// skipString 返回从 i 处开始的JSON字符串的结束引号的位置
+func skipString(s string, i int) int {
+	for i++; i < len(s) && s[i] != '"'; i++ {
+		if s[i] == '\\' {
+			i++
+		}
+	}
+	return i
+}
+
+// token 过滤并输出一个Token，size 为其在输入中占用的字节数
+func (p *printer) token(tk jsontokenizer.Token, size int) {
+	offset := p.offset
+	p.offset += size
+	if p.opts.pattern != "" && !matchPath(p.opts.pattern, tk.Path) {
+		return
+	}
+	if p.opts.values && !isValue(tk.Type) {
+		return
+	}
+	p.print(tk, offset)
+}
+
+// tokenRecord 是 ndjson 格式的一行
+type tokenRecord struct {
+	File   string `json:"file,omitempty"`
+	Offset int    `json:"offset"`
+	Type   string `json:"type"`
+	Path   string `json:"path"`
+	Val    string `json:"val"`
+	Raw    string `json:"raw,omitempty"`
+}
+
+// print 以选定的格式输出一个Token
+func (p *printer) print(tk jsontokenizer.Token, offset int) {
+	if p.opts.format == "ndjson" {
+		b, err := json.Marshal(tokenRecord{File: p.label, Offset: offset, Type: tk.Type.String(), Path: tk.Path, Val: tk.Val, Raw: tk.Raw})
+		if err != nil {
+			p.err = err
+			return
+		}
+		p.line(string(b))
+		return
+	}
+	p.line(fmt.Sprintf("%d\t%s\t%s\t%q", offset, tk.Type, tk.Path, tk.Val))
+}
+
+// line 输出一行，处理多个文件时在文本行前加上文件名，并记录第一个写入错误
+func (p *printer) line(s string) {
+	if p.err != nil {
+		return
+	}
+	if p.label != "" && (p.opts.format == "text" || p.opts.leaves) {
+		s = p.label + ":" + s
+	}
+	_, p.err = io.WriteString(p.w, s+"\n")
+}
+
+// isValue 判断Token是否表示一个标量值
+func isValue(t jsontokenizer.TokenType) bool {
+	return t == jsontokenizer.TokenString || t == jsontokenizer.TokenStringChunk || t == jsontokenizer.TokenNumber ||
+		t == jsontokenizer.TokenBoolean || t == jsontokenizer.TokenNull
+}
+
+// matchPath 判断 path 是否匹配 pattern 或位于匹配的路径之下
+// pattern 中的 * 匹配任意一个键名或数组下标
+func matchPath(pattern, path string) bool {
+	ps, ok := splitPath(pattern)
+	if !ok {
+		return false
+	}
+	segs, ok := splitPath(path)
+	if !ok || len(segs) < len(ps) {
+		return false
+	}
+	for i, p := range ps {
+		s := segs[i]
+		switch {
+		case p == s:
+		case p == ".*" && (strings.HasPrefix(s, ".") || strings.HasPrefix(s, `["`)):
+		case p == "[*]" && strings.HasPrefix(s, "[") && !strings.HasPrefix(s, `["`):
+		default:
+			return false
+		}
+	}
+	return true
+}
+
+// splitPath 将 $.a[0].b 拆分为 [".a" "[0]" ".b"]，["a.b"] 形式的键名作为一段
+func splitPath(path string) ([]string, bool) {
+	if !strings.HasPrefix(path, "$") {
+		return nil, false
+	}
+	var segs []string
+	rest := path[1:]
+	for rest != "" {
+		var end int
+		switch {
+		case strings.HasPrefix(rest, `["`):
+			end = skipString(rest, 1) + 2
+		case rest[0] == '[':
+			end = strings.IndexByte(rest, ']') + 1
+		default:
+			end = strings.IndexAny(rest[1:], ".[") + 1
+		}
+		if end <= 0 || end > len(rest) {
+			end = len(rest)
+		}
+		segs = append(segs, rest[:end])
+		rest = rest[end:]
+	}
+	return segs, true
+}
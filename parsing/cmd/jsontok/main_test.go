@@ -0,0 +1,229 @@
+package main
+
+import (
+	"bytes"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"testing/iotest"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func runJsontok(t *testing.T, stdin string, args ...string) (string, string, int) {
+	t.Helper()
+	var stdout, stderr bytes.Buffer
+	code := run(args, strings.NewReader(stdin), &stdout, &stderr)
+	return stdout.String(), stderr.String(), code
+}
+
+func TestRun_Text(t *testing.T) {
+	out, _, code := runJsontok(t, `{"a": [1, "x"]}`)
+	require.Equal(t, 0, code)
+	assert.Equal(t, strings.Join([]string{
+		"0\tObjectStart\t$\t\"{\"",
+		"1\tKey\t$\t\"a\"",
+		"4\tColon\t$.a\t\":\"",
+		"5\tWhitespace\t$.a\t\" \"",
+		"6\tArrayStart\t$.a\t\"[\"",
+		"7\tNumber\t$.a[0]\t\"1\"",
+		"8\tComma\t$.a[1]\t\",\"",
+		"9\tWhitespace\t$.a[1]\t\" \"",
+		"10\tString\t$.a[1]\t\"x\"",
+		"13\tArrayEnd\t$.a\t\"]\"",
+		"14\tObjectEnd\t$\t\"}\"",
+	}, "\n")+"\n", out)
+}
+
+func TestRun_NDJSONValues(t *testing.T) {
+	out, _, code := runJsontok(t, `{"n": 1, "s": "é"}`, "-format", "ndjson", "-values")
+	require.Equal(t, 0, code)
+	assert.Equal(t,
+		`{"offset":6,"type":"Number","path":"$.n","val":"1","raw":"1"}`+"\n"+
+			`{"offset":14,"type":"String","path":"$.s","val":"é","raw":"\"é\""}`+"\n", out)
+}
+
+func TestRun_PathFilter(t *testing.T) {
+	input := `{"users":[{"name":"a","age":1},{"name":"b","age":2}],"name":"root"}`
+	out, _, code := runJsontok(t, input, "-values", "-path", "$.users[*].name")
+	require.Equal(t, 0, code)
+	assert.Equal(t, "18\tString\t$.users[0].name\t\"a\"\n39\tString\t$.users[1].name\t\"b\"\n", out)
+}
+
+func TestRun_Leaves(t *testing.T) {
+	out, _, code := runJsontok(t, `{"a":{"b":[1,true,null]},"c":{},"d":[ ],"e":"x"}`, "-leaves")
+	require.Equal(t, 0, code)
+	assert.Equal(t, strings.Join([]string{
+		"$.a.b[0] = 1",
+		"$.a.b[1] = true",
+		"$.a.b[2] = null",
+		"$.c = {}",
+		"$.d = []",
+		`$.e = "x"`,
+	}, "\n")+"\n", out)
+}
+
+func TestRun_LeavesQuotedKeys(t *testing.T) {
+	input := `{"a.b":1,"a":{"b":2},"":3,"q\" = ]":[4,{}]}`
+	out, _, code := runJsontok(t, input, "-leaves")
+	require.Equal(t, 0, code)
+	assert.Equal(t, strings.Join([]string{
+		`$["a.b"] = 1`,
+		`$.a.b = 2`,
+		`$[""] = 3`,
+		`$["q\" = ]"][0] = 4`,
+		`$["q\" = ]"][1] = {}`,
+	}, "\n")+"\n", out)
+
+	out, _, code = runJsontok(t, input, "-leaves", "-path", `$["a.b"]`)
+	require.Equal(t, 0, code)
+	assert.Equal(t, "$[\"a.b\"] = 1\n", out)
+
+	out, _, code = runJsontok(t, input, "-leaves", "-path", `$.*[*]`)
+	require.Equal(t, 0, code)
+	assert.Equal(t, "$[\"q\\\" = ]\"][0] = 4\n$[\"q\\\" = ]\"][1] = {}\n", out)
+}
+
+func TestRun_Runes(t *testing.T) {
+	out, _, code := runJsontok(t, `[true]`, "-runes", "-values")
+	require.Equal(t, 0, code)
+	assert.Equal(t, "1\tBoolean\t$[0]\t\"t\"\n2\tBoolean\t$[0]\t\"r\"\n3\tBoolean\t$[0]\t\"u\"\n4\tBoolean\t$[0]\t\"e\"\n", out)
+}
+
+func TestRun_RunesSplitRead(t *testing.T) {
+	var stdout bytes.Buffer
+	code := run([]string{"-runes", "-values"}, iotest.OneByteReader(strings.NewReader(`["é🚀"]`)), &stdout, io.Discard)
+	require.Equal(t, 0, code)
+	assert.Equal(t, "2\tString\t$[0]\t\"é\"\n4\tString\t$[0]\t\"🚀\"\n", stdout.String())
+}
+
+func TestRun_Files(t *testing.T) {
+	dir := t.TempDir()
+	a := filepath.Join(dir, "a.json")
+	require.NoError(t, os.WriteFile(a, []byte(`{"x":1}`), 0o600))
+
+	out, _, code := runJsontok(t, `{"y":2}`, "-leaves", a, "-")
+	require.Equal(t, 0, code)
+	assert.Equal(t, a+":$.x = 1\n-:$.y = 2\n", out)
+
+	_, errOut, code := runJsontok(t, "", filepath.Join(dir, "missing.json"))
+	assert.Equal(t, 1, code)
+	assert.Contains(t, errOut, "missing.json")
+}
+
+func TestMatchPath(t *testing.T) {
+	assert.True(t, matchPath("$", "$.a"))
+	assert.True(t, matchPath("$.a", "$.a.b"))
+	assert.True(t, matchPath("$.*.b", "$.a.b[0]"))
+	assert.True(t, matchPath("$[*].id", "$[3].id"))
+	assert.False(t, matchPath("$[*].id", "$.x.id"))
+	assert.False(t, matchPath("$.a.b", "$.a"))
+	assert.False(t, matchPath("$.a", "$.ab"))
+}
+
+func TestRun_Query(t *testing.T) {
+	input := `{"items": [{"n": "a", "p": 1.50}, {"n": "b", "p": 7}]}` + "\n" + `{"items": []}`
+	out, _, code := runJsontok(t, input, "query", ".items[] | select(.p > 1) | {n, p}")
+	require.Equal(t, 0, code)
+	assert.Equal(t, "{\n  \"n\": \"a\",\n  \"p\": 1.50\n}\n{\n  \"n\": \"b\",\n  \"p\": 7\n}\n", out)
+
+	out, _, code = runJsontok(t, input, "query", "-c", ".items | map(.n)")
+	require.Equal(t, 0, code)
+	assert.Equal(t, "[\"a\",\"b\"]\n[]\n", out)
+
+	out, _, code = runJsontok(t, input, "query", "-r", ".items[].n")
+	require.Equal(t, 0, code)
+	assert.Equal(t, "a\nb\n", out)
+}
+
+func TestRun_QueryErrors(t *testing.T) {
+	_, stderr, code := runJsontok(t, `{}`, "query")
+	assert.Equal(t, 2, code)
+	assert.Equal(t, "jsontok: query: missing filter\n", stderr)
+
+	_, stderr, code = runJsontok(t, `{}`, "query", ".a |")
+	assert.Equal(t, 2, code)
+	assert.Equal(t, "jsontok: query: unexpected end of query at offset 4\n", stderr)
+
+	out, stderr, code := runJsontok(t, `[1] "x"`, "query", "-c", ".[0]")
+	assert.Equal(t, 5, code)
+	assert.Equal(t, "1\n", out)
+	assert.Equal(t, "jsontok: query: cannot index string with number\n", stderr)
+}
+
+func TestRun_Infer(t *testing.T) {
+	input := `{"id": 1, "kind": "a", "tags": ["x"]}` + "\n" + `{"id": 2, "kind": "a", "tags": null}`
+	out, _, code := runJsontok(t, input, "infer")
+	require.Equal(t, 0, code)
+	assert.JSONEq(t, `{
+		"$schema": "https://json-schema.org/draft/2020-12/schema",
+		"type": "object",
+		"properties": {
+			"id": {"type": "integer", "minimum": 1, "maximum": 2},
+			"kind": {"type": "string", "enum": ["a"]},
+			"tags": {"type": ["null", "array"], "items": {"type": "string"}}
+		},
+		"required": ["id", "kind", "tags"]
+	}`, out)
+
+	out, _, code = runJsontok(t, input, "infer", "-enum", "0")
+	require.Equal(t, 0, code)
+	assert.NotContains(t, out, "enum")
+
+	dir := t.TempDir()
+	name := filepath.Join(dir, "b.json")
+	require.NoError(t, os.WriteFile(name, []byte(`{"id": 3, "ok": true}`), 0o644))
+	out, _, code = runJsontok(t, input, "infer", "-go", "-package", "hooks", "-type", "Event", "-", name)
+	require.Equal(t, 0, code)
+	assert.Equal(t, "package hooks\n\n"+
+		"type Event struct {\n"+
+		"\tID   int64    `json:\"id\"`\n"+
+		"\tKind string   `json:\"kind,omitempty\"`\n"+
+		"\tTags []string `json:\"tags,omitempty\"`\n"+
+		"\tOk   bool     `json:\"ok,omitempty\"`\n"+
+		"}\n", out)
+}
+
+func TestRun_InferErrors(t *testing.T) {
+	_, stderr, code := runJsontok(t, `{}`, "infer", "-go", "-type", "a-b")
+	assert.Equal(t, 2, code)
+	assert.Equal(t, "jsontok: infer: -package and -type must be Go identifiers\n", stderr)
+
+	out, stderr, code := runJsontok(t, `{"a":`, "infer")
+	assert.Equal(t, 1, code)
+	assert.Empty(t, out)
+	assert.Contains(t, stderr, "jsontok: -: ")
+}
+
+func TestRun_Profile(t *testing.T) {
+	input := `{"a": [1, 2], "b": "xyz"}`
+	out, _, code := runJsontok(t, input, "profile", "-sort", "bytes")
+	require.Equal(t, 0, code)
+	assert.Equal(t, strings.Join([]string{
+		"PATH    COUNT  BYTES  MAX DEPTH  MAX STRING  TYPES",
+		"$       1      25     2          0           object:1",
+		"$.a     1      6      1          0           array:1",
+		"$.b     1      5      0          3           string:1",
+		"$.a[*]  2      2      0          0           number:2",
+	}, "\n")+"\n", out)
+
+	out, _, code = runJsontok(t, input, "profile", "-json")
+	require.Equal(t, 0, code)
+	assert.JSONEq(t, `[
+		{"path": "$", "count": 1, "bytes": 25, "maxDepth": 2, "maxStringLen": 0, "types": {"object": 1}},
+		{"path": "$.a", "count": 1, "bytes": 6, "maxDepth": 1, "maxStringLen": 0, "types": {"array": 1}},
+		{"path": "$.a[*]", "count": 2, "bytes": 2, "maxDepth": 0, "maxStringLen": 0, "types": {"number": 2}},
+		{"path": "$.b", "count": 1, "bytes": 5, "maxDepth": 0, "maxStringLen": 3, "types": {"string": 1}}
+	]`, out)
+
+	_, stderr, code := runJsontok(t, input, "profile", "-sort", "size")
+	assert.Equal(t, 2, code)
+	assert.Equal(t, "jsontok: profile: unknown sort key \"size\"\n", stderr)
+
+	_, stderr, code = runJsontok(t, `{"a": [1}`, "profile")
+	assert.Equal(t, 1, code)
+	assert.Equal(t, "jsontok: -: jsontokenizer: profile: unexpected \"}\" at offset 8\n", stderr)
+}
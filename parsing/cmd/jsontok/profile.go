@@ -0,0 +1,85 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+
+	"github.com/libxyz/gizmo/parsing/jsontokenizer"
+)
+
+// statsOrders 是 -sort 的取值，doc 保持路径首次出现的顺序
+var statsOrders = map[string]jsontokenizer.StatsOrder{
+	"path":   jsontokenizer.ByPath,
+	"bytes":  jsontokenizer.ByBytes,
+	"count":  jsontokenizer.ByCount,
+	"depth":  jsontokenizer.ByDepth,
+	"string": jsontokenizer.ByStringLen,
+}
+
+// runProfile 执行 profile 子命令并返回退出码
+func runProfile(args []string, stdin io.Reader, stdout, stderr io.Writer) int {
+	fs := flag.NewFlagSet("jsontok profile", flag.ContinueOnError)
+	fs.SetOutput(stderr)
+	order := fs.String("sort", "doc", "sort paths by `key`: doc, path, bytes, count, depth or string")
+	asJSON := fs.Bool("json", false, "print the statistics as a JSON array instead of a table")
+	if err := fs.Parse(args); err != nil {
+		return 2
+	}
+	by, ok := statsOrders[*order]
+	if !ok && *order != "doc" {
+		fmt.Fprintf(stderr, "jsontok: profile: unknown sort key %q\n", *order)
+		return 2
+	}
+
+	p := jsontokenizer.NewProfiler()
+	files := fs.Args()
+	if len(files) == 0 {
+		files = []string{"-"}
+	}
+	for _, name := range files {
+		if err := profileFile(p, name, stdin); err != nil {
+			fmt.Fprintf(stderr, "jsontok: %s: %v\n", name, err)
+			return 1
+		}
+	}
+
+	stats := p.Stats()
+	if ok {
+		jsontokenizer.SortStats(stats, by)
+	}
+	var err error
+	if *asJSON {
+		var b []byte
+		if b, err = json.Marshal(stats); err == nil {
+			err = jsontokenizer.Format(bytes.NewReader(b), stdout, jsontokenizer.FormatOptions{})
+		}
+	} else {
+		err = jsontokenizer.WriteStatsTable(stdout, stats)
+	}
+	if err != nil {
+		fmt.Fprintf(stderr, "jsontok: %v\n", err)
+		return 1
+	}
+	return 0
+}
+
+// profileFile 统计一个输入文件，name 为 "-" 时读取标准输入
+func profileFile(p *jsontokenizer.Profiler, name string, stdin io.Reader) error {
+	r := stdin
+	if name != "-" {
+		f, err := os.Open(name)
+		if err != nil {
+			return err
+		}
+		defer f.Close()
+		r = f
+	}
+	if _, err := io.Copy(p, r); err != nil {
+		return err
+	}
+	return p.Close()
+}
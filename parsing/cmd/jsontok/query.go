@@ -0,0 +1,81 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+
+	"github.com/libxyz/gizmo/parsing/jsontokenizer"
+	"github.com/libxyz/gizmo/parsing/jsontokenizer/query"
+)
+
+// runQuery 执行 query 子命令并返回退出码
+func runQuery(args []string, stdin io.Reader, stdout, stderr io.Writer) int {
+	fs := flag.NewFlagSet("jsontok query", flag.ContinueOnError)
+	fs.SetOutput(stderr)
+	compact := fs.Bool("c", false, "print each result on one line instead of pretty-printing it")
+	raw := fs.Bool("r", false, "print string results without quotes")
+	if err := fs.Parse(args); err != nil {
+		return 2
+	}
+	if fs.NArg() == 0 {
+		fmt.Fprintln(stderr, "jsontok: query: missing filter")
+		return 2
+	}
+	q, err := query.Parse(fs.Arg(0))
+	if err != nil {
+		fmt.Fprintf(stderr, "jsontok: %v\n", err)
+		return 2
+	}
+
+	files := fs.Args()[1:]
+	if len(files) == 0 {
+		files = []string{"-"}
+	}
+	w := bufio.NewWriter(stdout)
+	status := 0
+	for _, name := range files {
+		if err := queryFile(q, name, stdin, w, *compact, *raw); err != nil {
+			w.Flush()
+			fmt.Fprintf(stderr, "jsontok: %v\n", err)
+			status = 5 // 与 jq 的运行时错误退出码相同
+		}
+	}
+	if err := w.Flush(); err != nil {
+		fmt.Fprintf(stderr, "jsontok: %v\n", err)
+		return 1
+	}
+	return status
+}
+
+// queryFile 对一个输入文件求值并输出结果，name 为 "-" 时读取标准输入
+func queryFile(q *query.Query, name string, stdin io.Reader, w *bufio.Writer, compact, raw bool) error {
+	r := stdin
+	if name != "-" {
+		f, err := os.Open(name)
+		if err != nil {
+			return err
+		}
+		defer f.Close()
+		r = f
+	}
+	return q.Run(r, func(result json.RawMessage) error {
+		if raw && result[0] == '"' {
+			var s string
+			if err := json.Unmarshal(result, &s); err != nil {
+				return err
+			}
+			w.WriteString(s)
+			return w.WriteByte('\n')
+		}
+		if compact {
+			w.Write(result)
+			return w.WriteByte('\n')
+		}
+		return jsontokenizer.Format(bytes.NewReader(result), w, jsontokenizer.FormatOptions{})
+	})
+}
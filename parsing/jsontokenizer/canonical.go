@@ -0,0 +1,181 @@
+package jsontokenizer
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"fmt"
+	"io"
+	"math"
+	"slices"
+	"strconv"
+	"strings"
+	"unicode/utf16"
+
+	"github.com/libxyz/gizmo/parsing/jsontokenizer/internal/jsonstr"
+)
+
+// Canonicalize writes the JSON document read from r to w in the canonical
+// form of RFC 8785 (JCS): no whitespace, object members sorted by the UTF-16
+// code units of their keys, numbers formatted as ECMAScript does and strings
+// with minimal escaping. Numbers that do not fit in a float64 and duplicate
+// keys are rejected.
+//
+// Sorting needs a whole object, so every object is buffered, in canonical
+// form, until its end; memory therefore grows with the largest object
+// including everything nested in it. Arrays that are not inside an object,
+// such as a top-level array of records, are written as they are read, so
+// such a document is canonicalized with memory bounded by its largest
+// element.
+func Canonicalize(r io.Reader, w io.Writer) error {
+	return transform(r, w, func(p *patcher, first Token) error {
+		return canonicalValue(p.in, first, p.w)
+	})
+}
+
+// CanonicalSHA256 returns the SHA-256 digest of the canonical form of the
+// JSON document read from r, as written by Canonicalize.
+func CanonicalSHA256(r io.Reader) ([sha256.Size]byte, error) {
+	h := sha256.New()
+	if err := Canonicalize(r, h); err != nil {
+		return [sha256.Size]byte{}, err
+	}
+	return [sha256.Size]byte(h.Sum(nil)), nil
+}
+
+// canonicalWriter 是 bufio.Writer 和 bytes.Buffer 共有的写入方法
+type canonicalWriter interface {
+	io.Writer
+	io.ByteWriter
+	io.StringWriter
+}
+
+// canonicalValue 输出以 first 开头的值的规范形式
+func canonicalValue(in *valueReader, first Token, w canonicalWriter) error {
+	switch first.Type {
+	case TokenObjectStart:
+		return canonicalObject(in, w)
+	case TokenArrayStart:
+		w.WriteByte('[')
+		for n := 0; ; n++ {
+			tk, err := in.next()
+			if err != nil {
+				return err
+			}
+			if tk.Type == TokenArrayEnd {
+				return w.WriteByte(']')
+			}
+			if n > 0 {
+				w.WriteByte(',')
+			}
+			if err := canonicalValue(in, tk, w); err != nil {
+				return err
+			}
+		}
+	case TokenString:
+		_, err := w.Write(jsonstr.AppendQuote(nil, first.Val))
+		return err
+	case TokenNumber:
+		b, err := appendCanonicalNumber(nil, first.Val)
+		if err != nil {
+			return in.d.syntaxError(first, err.Error())
+		}
+		_, err = w.Write(b)
+		return err
+	case TokenUnknown, TokenStringEscape, TokenBoolean, TokenNull, TokenObjectEnd, TokenArrayEnd, TokenKey,
+		TokenKeyEscape, TokenComma, TokenColon, TokenQuote, TokenWhitespace, TokenStringChunk:
+	}
+	_, err := w.WriteString(first.Raw)
+	return err
+}
+
+// canonicalObject 缓冲对象的全部成员，按键名的 UTF-16 编码排序后输出
+func canonicalObject(in *valueReader, w canonicalWriter) error {
+	type member struct {
+		key   string
+		units []uint16
+		value []byte
+	}
+	var members []member
+	for {
+		key, err := in.next()
+		if err != nil {
+			return err
+		}
+		if key.Type == TokenObjectEnd {
+			break
+		}
+		first, err := in.next()
+		if err != nil {
+			return err
+		}
+		var value bytes.Buffer
+		if err := canonicalValue(in, first, &value); err != nil {
+			return err
+		}
+		members = append(members, member{key: key.Val, units: utf16.Encode([]rune(key.Val)), value: value.Bytes()})
+	}
+	slices.SortFunc(members, func(a, b member) int { return slices.Compare(a.units, b.units) })
+
+	w.WriteByte('{')
+	for i, m := range members {
+		if i > 0 {
+			if m.key == members[i-1].key {
+				return fmt.Errorf("jsontokenizer: duplicate key %s", strconv.Quote(m.key))
+			}
+			w.WriteByte(',')
+		}
+		w.Write(jsonstr.AppendQuote(nil, m.key))
+		w.WriteByte(':')
+		w.Write(m.value)
+	}
+	return w.WriteByte('}')
+}
+
+// appendCanonicalNumber 按 ECMAScript Number.prototype.toString 的规则追加数字
+func appendCanonicalNumber(dst []byte, s string) ([]byte, error) {
+	f, err := strconv.ParseFloat(s, 64)
+	if err != nil || math.IsInf(f, 0) {
+		return dst, fmt.Errorf("number %s out of range", s)
+	}
+	if f == 0 {
+		return append(dst, '0'), nil // 包括 -0
+	}
+	if f < 0 {
+		dst = append(dst, '-')
+		f = -f
+	}
+
+	// 最短的十进制表示为 0.digits × 10^n
+	e := strconv.AppendFloat(nil, f, 'e', -1, 64)
+	mant, exp, _ := strings.Cut(string(e), "e")
+	digits := strings.Replace(mant, ".", "", 1)
+	x, _ := strconv.Atoi(exp)
+	n, k := x+1, len(digits)
+
+	switch {
+	case k <= n && n <= 21:
+		dst = append(dst, digits...)
+		for range n - k {
+			dst = append(dst, '0')
+		}
+	case 0 < n && n <= 21:
+		dst = append(append(append(dst, digits[:n]...), '.'), digits[n:]...)
+	case -6 < n && n <= 0:
+		dst = append(dst, "0."...)
+		for range -n {
+			dst = append(dst, '0')
+		}
+		dst = append(dst, digits...)
+	default:
+		dst = append(dst, digits[0])
+		if k > 1 {
+			dst = append(append(dst, '.'), digits[1:]...)
+		}
+		dst = append(dst, 'e')
+		if n-1 >= 0 {
+			dst = append(dst, '+')
+		}
+		dst = strconv.AppendInt(dst, int64(n-1), 10)
+	}
+	return dst, nil
+}
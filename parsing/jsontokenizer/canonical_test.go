@@ -0,0 +1,82 @@
+package jsontokenizer
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"strings"
+	"testing"
+	"testing/iotest"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func canonicalize(t *testing.T, input string) string {
+	t.Helper()
+	var out bytes.Buffer
+	require.NoError(t, Canonicalize(iotest.OneByteReader(strings.NewReader(input)), &out))
+	return out.String()
+}
+
+func TestCanonicalize(t *testing.T) {
+	// RFC 8785 第 3.2.2 节的示例
+	input := `{
+		"numbers": [333333333.33333329, 1E30, 4.50, 2e-3, 0.000000000000000000000000001],
+		"string": "\u20ac$\u000F\u000aA'\u0042\u0022\u005c\\\"\/",
+		"literals": [null, true, false]
+	}`
+	assert.Equal(t,
+		`{"literals":[null,true,false],"numbers":[333333333.3333333,1e+30,4.5,0.002,1e-27],"string":"€$\u000f\nA'B\"\\\\\"/"}`,
+		canonicalize(t, input))
+}
+
+func TestCanonicalize_KeyOrder(t *testing.T) {
+	// RFC 8785 第 3.2.3 节：按 UTF-16 编码单元排序
+	input := `{"\u20ac":"Euro Sign","\r":"Carriage Return","\ufb33":"Hebrew Letter Dalet With Dagesh","1":"One",` +
+		`"😀":"Emoji: Grinning Face","\u0080":"Control","ö":"Latin Small Letter O With Diaeresis"}`
+	expected := `{"\r":"Carriage Return","1":"One","` + "\u0080" + `":"Control","ö":"Latin Small Letter O With Diaeresis",` +
+		`"€":"Euro Sign","😀":"Emoji: Grinning Face","` + "\ufb33" + `":"Hebrew Letter Dalet With Dagesh"}`
+	assert.Equal(t, expected, canonicalize(t, input))
+	assert.Equal(t, `[{"a":{"b":1,"c":[]},"z":{}},2]`, canonicalize(t, ` [ {"z":{}, "a":{"c":[],"b":1.0}}, 2 ] `))
+}
+
+func TestCanonicalNumber(t *testing.T) {
+	tests := map[string]string{
+		"0":                      "0",
+		"-0.0":                   "0",
+		"1":                      "1",
+		"-1.5":                   "-1.5",
+		"100":                    "100",
+		"1e21":                   "1e+21",
+		"123456789012345678901":  "123456789012345680000",
+		"0.000001":               "0.000001",
+		"0.0000001":              "1e-7",
+		"1.5e-7":                 "1.5e-7",
+		"9007199254740993":       "9007199254740992",
+		"5e-324":                 "5e-324",
+		"1.7976931348623157e308": "1.7976931348623157e+308",
+		"123.456e2":              "12345.6",
+	}
+	for in, expected := range tests {
+		got, err := appendCanonicalNumber(nil, in)
+		require.NoError(t, err, in)
+		assert.Equal(t, expected, string(got), in)
+	}
+	_, err := appendCanonicalNumber(nil, "1e400")
+	assert.Error(t, err)
+}
+
+func TestCanonicalize_Errors(t *testing.T) {
+	for _, input := range []string{`{"a":1,"a":2}`, `[1e400]`, `[1,]`, `{"a":1} 2`, `[`} {
+		assert.Error(t, Canonicalize(strings.NewReader(input), &bytes.Buffer{}), input)
+	}
+}
+
+func TestCanonicalSHA256(t *testing.T) {
+	a, err := CanonicalSHA256(strings.NewReader(`{"b": 2, "a": [1.0]}`))
+	require.NoError(t, err)
+	b, err := CanonicalSHA256(strings.NewReader(`{"a":[1],"b":2}`))
+	require.NoError(t, err)
+	assert.Equal(t, sha256.Sum256([]byte(`{"a":[1],"b":2}`)), a)
+	assert.Equal(t, a, b)
+}
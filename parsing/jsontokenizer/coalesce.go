@@ -0,0 +1,129 @@
+package jsontokenizer
+
+// element 是合并模式下正在累积的词法单元
+type element struct {
+	typ  TokenType // 单元类型，TokenUnknown 表示当前没有未完成的单元
+	path string    // 单元起始位置的路径
+	raw  []byte    // 原始文本
+	val  []byte    // 解码后的值，仅用于字符串和键名
+	unes unescaper // 字符串转义解码状态
+}
+
+// Coalesce switches Feed to coalesced mode, in which every lexical element
+// (object key, string, number, literal, whitespace run or structural
+// character) is reported as a single token. Keys and strings carry their
+// decoded value in Val and their source text, quotes included, in Raw; the
+// other elements carry their source text in both fields. Push is unaffected
+// and keeps producing one token per rune.
+//
+// A number, literal or whitespace run is only known to be complete once the
+// character after it arrives, so the last element of the input is reported by
+// Flush.
+func (p *Tokenizer) Coalesce() {
+	p.coalesce = true
+}
+
+// StringChunks makes coalesced mode report the part of a string value received
+// so far as a TokenStringChunk at the end of each Feed call, instead of holding
+// it until the closing quote. The final TokenString then only carries the
+// remainder of the string, so the full value is the concatenation of the
+// chunks' Vals and the final Val. Keys are never split.
+func (p *Tokenizer) StringChunks() {
+	p.coalesce = true
+	p.stringChunks = true
+}
+
+// Flush reports the element still pending at the end of the input in
+// coalesced mode, such as a trailing number. An unterminated string is
+// incomplete and is not reported.
+func (p *Tokenizer) Flush() []Token {
+	// 不使用 Feed 的输出缓冲区，调用方可能仍持有上一次 Feed 的结果
+	buf := p.out
+	p.out = nil
+	if p.elem.typ != TokenString && p.elem.typ != TokenKey {
+		p.finishElement()
+	}
+	out := p.out
+	p.out = buf[:0]
+	return out
+}
+
+// coalesceRun 将 feed 扫描出的字符片段合并为词法单元
+func (p *Tokenizer) coalesceRun(t TokenType, path string, val []byte) {
+	switch t {
+	case TokenQuote:
+		if p.inner.state == stateKey || p.inner.state == stateString {
+			p.finishElement()
+			p.elem.typ = TokenString
+			if p.inner.state == stateKey {
+				p.elem.typ = TokenKey
+			}
+			p.elem.path = path
+			p.elem.raw = append(p.elem.raw, val...)
+			if p.elem.typ == TokenString && len(p.embedded) > 0 {
+				p.startEmbedded(path)
+			}
+			return
+		}
+		p.elem.raw = append(p.elem.raw, val...)
+		p.elem.val = p.elem.unes.finish(p.elem.val)
+		if p.nested != nil {
+			p.feedEmbedded(true)
+		}
+		p.finishElement()
+	case TokenString, TokenStringEscape, TokenKey, TokenKeyEscape:
+		p.elem.raw = append(p.elem.raw, val...)
+		p.elem.val = p.elem.unes.append(p.elem.val, val)
+		if p.nested != nil {
+			p.feedEmbedded(false)
+		}
+	case TokenNumber, TokenBoolean, TokenNull, TokenWhitespace:
+		if p.elem.typ != t || p.elem.path != path {
+			p.finishElement()
+			p.elem.typ = t
+			p.elem.path = path
+		}
+		p.elem.raw = append(p.elem.raw, val...)
+	case TokenObjectStart, TokenObjectEnd, TokenArrayStart, TokenArrayEnd,
+		TokenComma, TokenColon, TokenUnknown, TokenStringChunk:
+		p.finishElement()
+		raw := string(val)
+		p.out = append(p.out, Token{Val: raw, Type: t, Path: path, Raw: raw})
+	}
+}
+
+// finishElement 输出当前累积的词法单元
+func (p *Tokenizer) finishElement() {
+	e := &p.elem
+	if e.typ == TokenUnknown {
+		return
+	}
+	raw := string(e.raw)
+	val := raw
+	if e.typ == TokenString || e.typ == TokenKey {
+		val = string(e.val)
+	}
+	p.out = append(p.out, Token{Val: val, Type: e.typ, Path: e.path, Raw: raw})
+	e.typ = TokenUnknown
+	e.raw = e.raw[:0]
+	e.val = e.val[:0]
+}
+
+// flushStringChunk 将尚未结束的字符串值中已收到的部分作为一个分片输出
+func (p *Tokenizer) flushStringChunk() {
+	e := &p.elem
+	if e.typ != TokenString || len(e.raw) == 0 {
+		return
+	}
+	p.out = append(p.out, Token{Val: string(e.val), Type: TokenStringChunk, Path: e.path, Raw: string(e.raw)})
+	e.raw = e.raw[:0]
+	e.val = e.val[:0]
+	if p.nested != nil {
+		p.nested.fed = 0
+	}
+}
+
+// incomplete 判断输入是否停在字符串、键名或多字节字符的中间
+func (p *Tokenizer) incomplete() bool {
+	return len(p.pending) > 0 || p.inner.state == stateString || p.inner.state == stateKey
+}
@@ -0,0 +1,107 @@
+package jsontokenizer
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func feedCoalesced(t *Tokenizer, b []byte, size int) []Token {
+	return append(feedChunks(t, b, size), t.Flush()...)
+}
+
+func TestCoalesce_Elements(t *testing.T) {
+	z := NewTokenizer()
+	z.Coalesce()
+	json := `{"na\"me": "te\nA", "n": [-1.5e3, true, null] }`
+	tokens := feedCoalesced(z, []byte(json), len(json))
+
+	expected := []Token{
+		{Type: TokenObjectStart, Path: "$", Val: "{", Raw: "{"},
+		{Type: TokenKey, Path: "$", Val: `na"me`, Raw: `"na\"me"`},
+		{Type: TokenColon, Path: `$.na\"me`, Val: ":", Raw: ":"},
+		{Type: TokenWhitespace, Path: `$.na\"me`, Val: " ", Raw: " "},
+		{Type: TokenString, Path: `$.na\"me`, Val: "te\nA", Raw: `"te\nA"`},
+		{Type: TokenComma, Path: "$", Val: ",", Raw: ","},
+		{Type: TokenWhitespace, Path: "$", Val: " ", Raw: " "},
+		{Type: TokenKey, Path: "$", Val: "n", Raw: `"n"`},
+		{Type: TokenColon, Path: "$.n", Val: ":", Raw: ":"},
+		{Type: TokenWhitespace, Path: "$.n", Val: " ", Raw: " "},
+		{Type: TokenArrayStart, Path: "$.n", Val: "[", Raw: "["},
+		{Type: TokenNumber, Path: "$.n[0]", Val: "-1.5e3", Raw: "-1.5e3"},
+		{Type: TokenComma, Path: "$.n[1]", Val: ",", Raw: ","},
+		{Type: TokenWhitespace, Path: "$.n[1]", Val: " ", Raw: " "},
+		{Type: TokenBoolean, Path: "$.n[1]", Val: "true", Raw: "true"},
+		{Type: TokenComma, Path: "$.n[2]", Val: ",", Raw: ","},
+		{Type: TokenWhitespace, Path: "$.n[2]", Val: " ", Raw: " "},
+		{Type: TokenNull, Path: "$.n[2]", Val: "null", Raw: "null"},
+		{Type: TokenArrayEnd, Path: "$.n", Val: "]", Raw: "]"},
+		{Type: TokenWhitespace, Path: "$.n", Val: " ", Raw: " "},
+		{Type: TokenObjectEnd, Path: "$", Val: "}", Raw: "}"},
+	}
+	assert.Equal(t, expected, tokens)
+}
+
+func TestCoalesce_ChunkSizeIndependent(t *testing.T) {
+	for _, input := range feedInputs {
+		whole := NewTokenizer()
+		whole.Coalesce()
+		want := feedCoalesced(whole, []byte(input), len(input))
+
+		raw := strings.Builder{}
+		for _, tk := range want {
+			raw.WriteString(tk.Raw)
+		}
+		require.Equal(t, input, raw.String())
+
+		for _, size := range []int{1, 2, 3, 7} {
+			z := NewTokenizer()
+			z.Coalesce()
+			assert.Equal(t, want, feedCoalesced(z, []byte(input), size), "input %q, chunk size %d", input, size)
+		}
+	}
+}
+
+func TestCoalesce_FlushTrailingNumber(t *testing.T) {
+	z := NewTokenizer()
+	z.Coalesce()
+	assert.Empty(t, z.Feed([]byte("12")))
+	assert.Empty(t, z.Feed([]byte("34")))
+	assert.Equal(t, []Token{{Type: TokenNumber, Path: "$", Val: "1234", Raw: "1234"}}, z.Flush())
+}
+
+func TestCoalesce_StringChunks(t *testing.T) {
+	z := NewTokenizer()
+	z.StringChunks()
+
+	var got []Token
+	for _, chunk := range []string{`{"k":"ab`, `c\u00`, `e9`, `d"}`} {
+		got = append(got, z.Feed([]byte(chunk))...)
+	}
+
+	expected := []Token{
+		{Type: TokenObjectStart, Path: "$", Val: "{", Raw: "{"},
+		{Type: TokenKey, Path: "$", Val: "k", Raw: `"k"`},
+		{Type: TokenColon, Path: "$.k", Val: ":", Raw: ":"},
+		{Type: TokenStringChunk, Path: "$.k", Val: "ab", Raw: `"ab`},
+		{Type: TokenStringChunk, Path: "$.k", Val: "c", Raw: `c\u00`},
+		{Type: TokenStringChunk, Path: "$.k", Val: "é", Raw: `e9`},
+		{Type: TokenString, Path: "$.k", Val: "d", Raw: `d"`},
+		{Type: TokenObjectEnd, Path: "$", Val: "}", Raw: "}"},
+	}
+	assert.Equal(t, expected, got)
+}
+
+func TestCoalesce_KeysAreNotChunked(t *testing.T) {
+	z := NewTokenizer()
+	z.StringChunks()
+
+	var got []Token
+	for _, chunk := range []string{`{"lo`, `ng":1}`} {
+		got = append(got, z.Feed([]byte(chunk))...)
+	}
+	require.Len(t, got, 5)
+	assert.Equal(t, Token{Type: TokenKey, Path: "$", Val: "long", Raw: `"long"`}, got[1])
+}
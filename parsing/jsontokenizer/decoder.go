@@ -0,0 +1,483 @@
+package jsontokenizer
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"strconv"
+)
+
+// SyntaxError describes malformed JSON found by a Decoder.
+type SyntaxError struct {
+	Msg    string // Description of the error
+	Offset int64  // Input offset of the offending token
+	Path   string // Path of the offending token
+}
+
+func (e *SyntaxError) Error() string {
+	return fmt.Sprintf("jsontokenizer: %s at offset %d (%s)", e.Msg, e.Offset, e.Path)
+}
+
+// decodeState 表示解码器在语法中的位置
+type decodeState int
+
+const (
+	decodeTop         decodeState = iota // 等待顶层值
+	decodeValue                          // 冒号之后，等待值
+	decodeArrayStart                     // '[' 之后，等待值或 ']'
+	decodeArrayValue                     // 数组中的逗号之后，等待值
+	decodeArrayComma                     // 数组元素之后，等待 ',' 或 ']'
+	decodeObjectStart                    // '{' 之后，等待键名或 '}'
+	decodeObjectKey                      // 对象中的逗号之后，等待键名
+	decodeObjectColon                    // 键名之后，等待 ':'
+	decodeObjectComma                    // 成员之后，等待 ',' 或 '}'
+)
+
+// Decoder reads JSON values from an input stream with the same Token-based
+// API as encoding/json.Decoder, and additionally reports the path of every
+// token. Tokens are returned as soon as they are complete, so a Decoder can
+// consume a document that is still being received.
+type Decoder struct {
+	r         io.Reader
+	t         *Tokenizer
+	buf       []byte
+	queue     []Token // Feed 产生、尚未消费的Token
+	err       error   // 读取结束或失败后的错误
+	fail      error   // 遇到的语法错误，之后的调用都返回它
+	useNumber bool
+
+	state  decodeState
+	stack  []containerType
+	offset int64  // 已消费的输入字节数
+	end    int64  // 最近返回的Token的结束位置
+	path   string // 最近返回的Token的路径
+}
+
+// NewDecoder returns a Decoder reading from r.
+func NewDecoder(r io.Reader) *Decoder {
+	t := NewTokenizer()
+	t.Coalesce()
+	return &Decoder{r: r, t: t, buf: make([]byte, 4096)}
+}
+
+// UseNumber makes Token and Decode return numbers as json.Number instead of
+// float64.
+func (d *Decoder) UseNumber() {
+	d.useNumber = true
+}
+
+// Token returns the next JSON token: a json.Delim for the four delimiters,
+// a string for keys and strings, a float64 or json.Number for numbers, a
+// bool, or nil for null. Commas and colons are checked and skipped. At the
+// end of the input Token returns io.EOF, or io.ErrUnexpectedEOF if the input
+// stops in the middle of a value.
+func (d *Decoder) Token() (json.Token, error) {
+	for {
+		tk, err := d.step()
+		if err != nil {
+			return nil, err
+		}
+		switch tk.Type {
+		case TokenComma, TokenColon:
+			continue
+		case TokenObjectStart, TokenObjectEnd, TokenArrayStart, TokenArrayEnd:
+			d.setPath(tk)
+			return json.Delim(tk.Val[0]), nil
+		case TokenKey, TokenString:
+			d.setPath(tk)
+			return tk.Val, nil
+		case TokenNumber:
+			d.setPath(tk)
+			if d.useNumber {
+				return json.Number(tk.Val), nil
+			}
+			f, err := strconv.ParseFloat(tk.Val, 64)
+			if err != nil {
+				return nil, d.syntaxError(tk, "invalid number "+tk.Val)
+			}
+			return f, nil
+		case TokenBoolean:
+			d.setPath(tk)
+			return tk.Val == "true", nil
+		case TokenNull:
+			d.setPath(tk)
+			return nil, nil
+		case TokenUnknown, TokenStringEscape, TokenKeyEscape, TokenQuote, TokenWhitespace, TokenStringChunk:
+			return nil, d.syntaxError(tk, "unexpected token")
+		}
+	}
+}
+
+// More reports whether there is another element in the current array or
+// object, or another value at the top level.
+func (d *Decoder) More() bool {
+	tk, err := d.peek()
+	return err == nil && tk.Type != TokenArrayEnd && tk.Type != TokenObjectEnd
+}
+
+// InputOffset returns the input offset of the end of the most recently
+// returned token.
+func (d *Decoder) InputOffset() int64 {
+	return d.end
+}
+
+// Path returns the path of the most recently returned token. The path of a
+// key includes the key itself, so after the key "name" of the root object
+// Path returns "$.name".
+func (d *Decoder) Path() string {
+	return d.path
+}
+
+// Decode reads the next JSON value and stores it in v, as json.Unmarshal
+// would. It can be mixed with Token to decode the elements of a large array
+// one at a time.
+func (d *Decoder) Decode(v any) error {
+	raw, err := d.readValue()
+	if err != nil {
+		return err
+	}
+	dec := json.NewDecoder(bytes.NewReader(raw))
+	if d.useNumber {
+		dec.UseNumber()
+	}
+	if err := dec.Decode(v); err != nil {
+		return fmt.Errorf("jsontokenizer: decode %s: %w", d.path, err)
+	}
+	return nil
+}
+
+// readValue 读取下一个完整的值，返回其去掉空白后的原始文本
+func (d *Decoder) readValue() ([]byte, error) {
+	for {
+		tk, err := d.peek()
+		if err != nil {
+			return nil, d.eofError(err)
+		}
+		if tk.Type != TokenComma && tk.Type != TokenColon {
+			break
+		}
+		if _, err := d.step(); err != nil {
+			return nil, err
+		}
+	}
+
+	depth := len(d.stack)
+	var raw []byte
+	for {
+		tk, err := d.step()
+		if err != nil {
+			if raw != nil && errors.Is(err, io.EOF) {
+				err = io.ErrUnexpectedEOF
+			}
+			return nil, err
+		}
+		if raw == nil {
+			if tk.Type == TokenArrayEnd || tk.Type == TokenObjectEnd || tk.Type == TokenKey {
+				return nil, d.syntaxError(tk, "expected value")
+			}
+			d.setPath(tk)
+		}
+		raw = append(raw, tk.Raw...)
+		if len(d.stack) == depth {
+			return raw, nil
+		}
+	}
+}
+
+// step 读取并校验下一个非空白Token，包括逗号和冒号
+func (d *Decoder) step() (Token, error) {
+	if d.fail != nil {
+		return Token{}, d.fail
+	}
+	tk, err := d.next()
+	if err != nil {
+		return Token{}, d.eofError(err)
+	}
+
+	switch tk.Type {
+	case TokenObjectStart, TokenArrayStart, TokenString, TokenNumber, TokenBoolean, TokenNull:
+		if d.state != decodeTop && d.state != decodeValue && d.state != decodeArrayStart && d.state != decodeArrayValue {
+			return tk, d.syntaxError(tk, "unexpected "+describe(tk))
+		}
+		switch {
+		case tk.Type == TokenObjectStart:
+			d.stack = append(d.stack, containerTypeObject)
+			d.state = decodeObjectStart
+		case tk.Type == TokenArrayStart:
+			d.stack = append(d.stack, containerTypeArray)
+			d.state = decodeArrayStart
+		default:
+			if !validScalar(tk) {
+				return tk, d.syntaxError(tk, "invalid "+describe(tk))
+			}
+			d.afterValue()
+		}
+	case TokenObjectEnd:
+		if d.state != decodeObjectStart && d.state != decodeObjectComma {
+			return tk, d.syntaxError(tk, "unexpected '}'")
+		}
+		d.stack = d.stack[:len(d.stack)-1]
+		d.afterValue()
+	case TokenArrayEnd:
+		if d.state != decodeArrayStart && d.state != decodeArrayComma {
+			return tk, d.syntaxError(tk, "unexpected ']'")
+		}
+		d.stack = d.stack[:len(d.stack)-1]
+		d.afterValue()
+	case TokenKey:
+		if d.state != decodeObjectStart && d.state != decodeObjectKey {
+			return tk, d.syntaxError(tk, "unexpected key")
+		}
+		d.state = decodeObjectColon
+	case TokenColon:
+		if d.state != decodeObjectColon {
+			return tk, d.syntaxError(tk, "unexpected ':'")
+		}
+		d.state = decodeValue
+	case TokenComma:
+		switch d.state {
+		case decodeArrayComma:
+			d.state = decodeArrayValue
+		case decodeObjectComma:
+			d.state = decodeObjectKey
+		case decodeTop, decodeValue, decodeArrayStart, decodeArrayValue, decodeObjectStart, decodeObjectKey, decodeObjectColon:
+			return tk, d.syntaxError(tk, "unexpected ','")
+		}
+	case TokenUnknown, TokenStringEscape, TokenKeyEscape, TokenQuote, TokenWhitespace, TokenStringChunk:
+		return tk, d.syntaxError(tk, "invalid character "+strconv.Quote(tk.Val))
+	}
+	return tk, nil
+}
+
+// afterValue 在一个完整的值之后更新语法状态
+func (d *Decoder) afterValue() {
+	if len(d.stack) == 0 {
+		d.state = decodeTop
+	} else if d.stack[len(d.stack)-1] == containerTypeArray {
+		d.state = decodeArrayComma
+	} else {
+		d.state = decodeObjectComma
+	}
+}
+
+// eofError 将值中间的输入结束转换为 io.ErrUnexpectedEOF
+func (d *Decoder) eofError(err error) error {
+	if errors.Is(err, io.EOF) && d.state != decodeTop {
+		return io.ErrUnexpectedEOF
+	}
+	return err
+}
+
+// next 消费下一个非空白Token
+func (d *Decoder) next() (Token, error) {
+	tk, err := d.peek()
+	if err != nil {
+		return Token{}, err
+	}
+	d.queue = d.queue[1:]
+	d.offset += int64(len(tk.Raw))
+	d.end = d.offset
+	return tk, nil
+}
+
+// peek 返回下一个非空白Token但不消费它，必要时从输入读取更多数据
+func (d *Decoder) peek() (Token, error) {
+	for {
+		for len(d.queue) > 0 {
+			if d.queue[0].Type != TokenWhitespace {
+				return d.queue[0], nil
+			}
+			d.offset += int64(len(d.queue[0].Raw))
+			d.queue = d.queue[1:]
+		}
+		if d.err != nil {
+			return Token{}, d.err
+		}
+		d.fill()
+	}
+}
+
+// fill 从输入读取一块数据并解析
+func (d *Decoder) fill() {
+	n, err := d.r.Read(d.buf)
+	d.queue = append(d.queue, d.t.Feed(d.buf[:n])...)
+	switch {
+	case errors.Is(err, io.EOF):
+		d.queue = append(d.queue, d.t.Flush()...)
+		d.err = io.EOF
+		if d.t.incomplete() {
+			d.err = io.ErrUnexpectedEOF
+		}
+	case err != nil:
+		d.err = fmt.Errorf("jsontokenizer: read: %w", err)
+	}
+}
+
+// setPath 记录最近返回的Token的路径
+func (d *Decoder) setPath(tk Token) {
+	if tk.Type == TokenKey {
+		d.path = tk.Path + "." + tk.Raw[1:len(tk.Raw)-1]
+		return
+	}
+	d.path = tk.Path
+}
+
+// syntaxError 构造指向 tk 的语法错误，之后的调用都会返回该错误
+func (d *Decoder) syntaxError(tk Token, msg string) error {
+	d.fail = &SyntaxError{Msg: msg, Offset: d.offset - int64(len(tk.Raw)), Path: tk.Path}
+	return d.fail
+}
+
+// describe 返回Token的简短描述，用于错误信息
+func describe(tk Token) string {
+	switch tk.Type {
+	case TokenString:
+		return "string"
+	case TokenNumber:
+		return "number " + tk.Val
+	case TokenBoolean, TokenNull:
+		return "literal " + tk.Val
+	case TokenUnknown, TokenStringEscape, TokenKey, TokenKeyEscape, TokenQuote, TokenWhitespace, TokenStringChunk,
+		TokenObjectStart, TokenObjectEnd, TokenArrayStart, TokenArrayEnd, TokenComma, TokenColon:
+	}
+	return strconv.Quote(tk.Val)
+}
+
+// validScalar 检查数字和字面量是否符合JSON语法
+func validScalar(tk Token) bool {
+	switch tk.Type {
+	case TokenNumber:
+		return validNumber(tk.Val)
+	case TokenBoolean:
+		return tk.Val == "true" || tk.Val == "false"
+	case TokenNull:
+		return tk.Val == "null"
+	case TokenUnknown, TokenString, TokenStringEscape, TokenObjectStart, TokenObjectEnd, TokenArrayStart, TokenArrayEnd,
+		TokenKey, TokenKeyEscape, TokenComma, TokenColon, TokenQuote, TokenWhitespace, TokenStringChunk:
+	}
+	return true
+}
+
+// validNumber 检查数字文本是否符合JSON语法
+func validNumber(s string) bool {
+	i := 0
+	if i < len(s) && s[i] == '-' {
+		i++
+	}
+	switch {
+	case i < len(s) && s[i] == '0':
+		i++
+	case i < len(s) && s[i] >= '1' && s[i] <= '9':
+		for i < len(s) && isDigit(rune(s[i])) {
+			i++
+		}
+	default:
+		return false
+	}
+	if i < len(s) && s[i] == '.' {
+		i++
+		start := i
+		for i < len(s) && isDigit(rune(s[i])) {
+			i++
+		}
+		if i == start {
+			return false
+		}
+	}
+	if i < len(s) && (s[i] == 'e' || s[i] == 'E') {
+		i++
+		if i < len(s) && (s[i] == '+' || s[i] == '-') {
+			i++
+		}
+		start := i
+		for i < len(s) && isDigit(rune(s[i])) {
+			i++
+		}
+		if i == start {
+			return false
+		}
+	}
+	return i == len(s)
+}
+
+// valueReader 从解码器读取值级别的Token，跳过逗号和冒号
+type valueReader struct {
+	d *Decoder
+}
+
+func newValueReader(r io.Reader) *valueReader {
+	return &valueReader{d: NewDecoder(r)}
+}
+
+// next 返回下一个非分隔符的Token，文档为空或中途结束时返回 io.ErrUnexpectedEOF
+func (in *valueReader) next() (Token, error) {
+	for {
+		tk, err := in.d.step()
+		if errors.Is(err, io.EOF) {
+			return tk, io.ErrUnexpectedEOF
+		}
+		if err != nil || (tk.Type != TokenComma && tk.Type != TokenColon) {
+			return tk, err
+		}
+	}
+}
+
+// rest 读取以 first 开头的值的剩余部分，返回去掉空白后的完整文本
+func (in *valueReader) rest(first Token) (json.RawMessage, error) {
+	raw := []byte(first.Raw)
+	if first.Type != TokenObjectStart && first.Type != TokenArrayStart {
+		return raw, nil
+	}
+	depth := len(in.d.stack) - 1
+	for len(in.d.stack) > depth {
+		tk, err := in.d.step()
+		if errors.Is(err, io.EOF) {
+			return nil, io.ErrUnexpectedEOF
+		}
+		if err != nil {
+			return nil, err
+		}
+		raw = append(raw, tk.Raw...)
+	}
+	return raw, nil
+}
+
+// skip 跳过以 first 开头的值的剩余部分
+func (in *valueReader) skip(first Token) error {
+	if first.Type != TokenObjectStart && first.Type != TokenArrayStart {
+		return nil
+	}
+	depth := len(in.d.stack) - 1
+	for len(in.d.stack) > depth {
+		if _, err := in.next(); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// members 读取对象的剩余成员，first 是已读取的键名或 '}'
+func (in *valueReader) members(first Token) ([]string, map[string]json.RawMessage, error) {
+	var keys []string
+	vals := make(map[string]json.RawMessage)
+	for tk := first; tk.Type != TokenObjectEnd; {
+		vt, err := in.next()
+		if err != nil {
+			return nil, nil, err
+		}
+		val, err := in.rest(vt)
+		if err != nil {
+			return nil, nil, err
+		}
+		if _, ok := vals[tk.Val]; !ok {
+			keys = append(keys, tk.Val)
+		}
+		vals[tk.Val] = val
+		if tk, err = in.next(); err != nil {
+			return nil, nil, err
+		}
+	}
+	return keys, vals, nil
+}
@@ -0,0 +1,170 @@
+package jsontokenizer
+
+import (
+	"encoding/json"
+	"errors"
+	"io"
+	"strings"
+	"testing"
+	"testing/iotest"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// tokensOf 读取全部Token，直到出错
+func tokensOf(t *testing.T, next func() (json.Token, error)) []json.Token {
+	t.Helper()
+	var out []json.Token
+	for {
+		tk, err := next()
+		if errors.Is(err, io.EOF) {
+			return out
+		}
+		require.NoError(t, err)
+		out = append(out, tk)
+	}
+}
+
+func TestDecoder_MatchesEncodingJSON(t *testing.T) {
+	inputs := []string{
+		`{"a":"te\n\"st", "b":42, "c":[true,false,null,{}], "d":{"e":[]}}`,
+		`[1, -2.5e3, "🚀🚀", {"k\"ey": "v"}]`,
+		`1 "two" [3] {"four":4}`,
+		` "root" `,
+		`{"":"x"}`,
+		`{"a":{"":"s"},"b":1}`,
+	}
+	for _, input := range inputs {
+		want := tokensOf(t, json.NewDecoder(strings.NewReader(input)).Token)
+		got := tokensOf(t, NewDecoder(iotest.OneByteReader(strings.NewReader(input))).Token)
+		assert.Equal(t, want, got, "input %q", input)
+	}
+}
+
+func TestDecoder_PathAndOffset(t *testing.T) {
+	input := `{"users": [{"name": "a"}, {"name": "b"}]}`
+	std := json.NewDecoder(strings.NewReader(input))
+	d := NewDecoder(strings.NewReader(input))
+
+	var paths []string
+	for {
+		tk, err := d.Token()
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		require.NoError(t, err)
+		_, err = std.Token()
+		require.NoError(t, err)
+		assert.Equal(t, std.InputOffset(), d.InputOffset(), "offset after %v", tk)
+		paths = append(paths, d.Path())
+	}
+	assert.Equal(t, []string{
+		"$", "$.users", "$.users",
+		"$.users[0]", "$.users[0].name", "$.users[0].name", "$.users[0]",
+		"$.users[1]", "$.users[1].name", "$.users[1].name", "$.users[1]",
+		"$.users", "$",
+	}, paths)
+}
+
+func TestDecoder_DecodeElements(t *testing.T) {
+	input := `{"items": [{"id": 1, "tags": ["x"]}, {"id": 2, "tags": []}], "next": null}`
+	d := NewDecoder(strings.NewReader(input))
+
+	for _, want := range []json.Token{json.Delim('{'), "items", json.Delim('[')} {
+		tk, err := d.Token()
+		require.NoError(t, err)
+		require.Equal(t, want, tk)
+	}
+
+	type item struct {
+		ID   int      `json:"id"`
+		Tags []string `json:"tags"`
+	}
+	var items []item
+	var paths []string
+	for d.More() {
+		var it item
+		require.NoError(t, d.Decode(&it))
+		items = append(items, it)
+		paths = append(paths, d.Path())
+	}
+	assert.Equal(t, []item{{1, []string{"x"}}, {2, []string{}}}, items)
+	assert.Equal(t, []string{"$.items[0]", "$.items[1]"}, paths)
+
+	tk, err := d.Token()
+	require.NoError(t, err)
+	assert.Equal(t, json.Delim(']'), tk)
+
+	tk, err = d.Token()
+	require.NoError(t, err)
+	assert.Equal(t, "next", tk)
+
+	var next any = "unchanged"
+	require.NoError(t, d.Decode(&next))
+	assert.Nil(t, next)
+}
+
+func TestDecoder_UseNumber(t *testing.T) {
+	d := NewDecoder(strings.NewReader(`[1.0, 12345678901234567890]`))
+	d.UseNumber()
+	assert.Equal(t, []json.Token{json.Delim('['), json.Number("1.0"), json.Number("12345678901234567890"), json.Delim(']')},
+		tokensOf(t, d.Token))
+}
+
+func TestDecoder_SyntaxErrors(t *testing.T) {
+	inputs := []string{
+		`{"a" "b"}`,
+		`[1 2]`,
+		`{1: 2}`,
+		`[1,]`,
+		`{"a":1}}`,
+		`[tru]`,
+		`[01]`,
+		`[x]`,
+	}
+	for _, input := range inputs {
+		d := NewDecoder(strings.NewReader(input))
+		var err error
+		for err == nil {
+			_, err = d.Token()
+		}
+		var syntaxErr *SyntaxError
+		assert.ErrorAs(t, err, &syntaxErr, "input %q", input)
+
+		_, again := d.Token()
+		assert.Equal(t, err, again, "errors should be sticky")
+	}
+}
+
+func TestDecoder_PartialInput(t *testing.T) {
+	pr, pw := io.Pipe()
+	d := NewDecoder(pr)
+
+	go func() { _, _ = pw.Write([]byte(`{"status":"running","progress":`)) }()
+	for _, want := range []json.Token{json.Delim('{'), "status", "running", "progress"} {
+		tk, err := d.Token()
+		require.NoError(t, err)
+		assert.Equal(t, want, tk)
+	}
+
+	// 输入在值中间截断
+	require.NoError(t, pw.Close())
+	_, err := d.Token()
+	assert.ErrorIs(t, err, io.ErrUnexpectedEOF)
+
+	d = NewDecoder(strings.NewReader(`["abc`))
+	_, err = d.Token()
+	require.NoError(t, err)
+	_, err = d.Token()
+	assert.ErrorIs(t, err, io.ErrUnexpectedEOF)
+}
+
+func TestValidNumber(t *testing.T) {
+	for _, s := range []string{"0", "-0", "1", "-12.5", "1e10", "1E-2", "0.5e+3"} {
+		assert.True(t, validNumber(s), s)
+	}
+	for _, s := range []string{"", "-", "01", "1.", ".5", "+1", "1e", "1e+", "--1", "1.2.3"} {
+		assert.False(t, validNumber(s), s)
+	}
+}
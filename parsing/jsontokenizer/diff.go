@@ -0,0 +1,290 @@
+package jsontokenizer
+
+import (
+	"bytes"
+	"encoding/json"
+	"io"
+	"math/big"
+	"strconv"
+	"strings"
+)
+
+// Operation is a single RFC 6902 JSON Patch operation. Path and From are
+// JSON Pointers (RFC 6901) and Value holds the raw JSON value.
+type Operation struct {
+	Op    string          `json:"op"`
+	Path  string          `json:"path"`
+	From  string          `json:"from,omitempty"`
+	Value json.RawMessage `json:"value,omitempty"`
+	// Old is the value that was removed or replaced. It is set by Diff and is
+	// not part of the patch document.
+	Old json.RawMessage `json:"-"`
+}
+
+// Diff compares the JSON documents read from a and b and calls emit with the
+// RFC 6902 operations that turn a into b, in an order in which they can be
+// applied: "add" for added paths, "remove" for removed paths and "replace"
+// for changed values, with Old set to the previous value.
+//
+// Both documents are read in lockstep and nothing is retained while they
+// agree. Objects are compared key by key as long as their keys come in the
+// same order; from the first differing key on, the rest of both objects is
+// buffered and matched by key. Array elements are compared by position.
+// Numbers are equal if their values are, so 1.0 equals 1.
+//
+// Only the first top-level value of each input is compared. Diff stops at
+// the first error returned by emit and returns it.
+func Diff(a, b io.Reader, emit func(Operation) error) error {
+	da, db := newValueReader(a), newValueReader(b)
+	ta, err := da.next()
+	if err != nil {
+		return err
+	}
+	tb, err := db.next()
+	if err != nil {
+		return err
+	}
+	return (&differ{emit: emit}).value(da, db, ta, tb, nil)
+}
+
+// differ 比较两个文档并输出操作
+type differ struct {
+	emit func(Operation) error
+}
+
+// value 比较以 ta 和 tb 开头的两个值，ptr 为其 JSON Pointer
+func (df *differ) value(a, b *valueReader, ta, tb Token, ptr []byte) error {
+	switch {
+	case ta.Type == TokenObjectStart && tb.Type == TokenObjectStart:
+		return df.object(a, b, ptr)
+	case ta.Type == TokenArrayStart && tb.Type == TokenArrayStart:
+		return df.array(a, b, ptr)
+	case ta.Type == tb.Type && scalarEqual(ta, tb):
+		return nil
+	}
+	old, err := a.rest(ta)
+	if err != nil {
+		return err
+	}
+	val, err := b.rest(tb)
+	if err != nil {
+		return err
+	}
+	return df.emit(Operation{Op: "replace", Path: string(ptr), Value: val, Old: old})
+}
+
+// object 逐个比较两个对象的成员，键名顺序不同时缓冲剩余成员按键名匹配
+func (df *differ) object(a, b *valueReader, ptr []byte) error {
+	for {
+		ka, err := a.next()
+		if err != nil {
+			return err
+		}
+		kb, err := b.next()
+		if err != nil {
+			return err
+		}
+		switch {
+		case ka.Type == TokenObjectEnd && kb.Type == TokenObjectEnd:
+			return nil
+		case ka.Type == TokenKey && kb.Type == TokenKey && ka.Val == kb.Val:
+			ta, err := a.next()
+			if err != nil {
+				return err
+			}
+			tb, err := b.next()
+			if err != nil {
+				return err
+			}
+			if err := df.value(a, b, ta, tb, appendPointer(ptr, ka.Val)); err != nil {
+				return err
+			}
+		default:
+			return df.members(a, b, ka, kb, ptr)
+		}
+	}
+}
+
+// members 读取两个对象的剩余成员，ka 和 kb 是已读取的键名或 '}'，然后按键名比较
+func (df *differ) members(a, b *valueReader, ka, kb Token, ptr []byte) error {
+	keysA, valsA, err := a.members(ka)
+	if err != nil {
+		return err
+	}
+	keysB, valsB, err := b.members(kb)
+	if err != nil {
+		return err
+	}
+	for _, k := range keysA {
+		if _, ok := valsB[k]; !ok {
+			if err := df.emit(Operation{Op: "remove", Path: string(appendPointer(ptr, k)), Old: valsA[k]}); err != nil {
+				return err
+			}
+		}
+	}
+	for _, k := range keysB {
+		old, ok := valsA[k]
+		if !ok {
+			if err := df.emit(Operation{Op: "add", Path: string(appendPointer(ptr, k)), Value: valsB[k]}); err != nil {
+				return err
+			}
+			continue
+		}
+		if err := df.raw(old, valsB[k], appendPointer(ptr, k)); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// array 按位置比较两个数组的元素
+func (df *differ) array(a, b *valueReader, ptr []byte) error {
+	for i := 0; ; i++ {
+		ta, err := a.next()
+		if err != nil {
+			return err
+		}
+		tb, err := b.next()
+		if err != nil {
+			return err
+		}
+		switch {
+		case ta.Type == TokenArrayEnd && tb.Type == TokenArrayEnd:
+			return nil
+		case ta.Type == TokenArrayEnd:
+			// b 更长：依次在末尾添加剩余元素
+			for ; tb.Type != TokenArrayEnd; i++ {
+				val, err := b.rest(tb)
+				if err != nil {
+					return err
+				}
+				if err := df.emit(Operation{Op: "add", Path: string(appendIndexPointer(ptr, i)), Value: val}); err != nil {
+					return err
+				}
+				if tb, err = b.next(); err != nil {
+					return err
+				}
+			}
+			return nil
+		case tb.Type == TokenArrayEnd:
+			// a 更长：从后向前删除剩余元素，使下标在应用时保持有效
+			var olds []json.RawMessage
+			for ta.Type != TokenArrayEnd {
+				old, err := a.rest(ta)
+				if err != nil {
+					return err
+				}
+				olds = append(olds, old)
+				if ta, err = a.next(); err != nil {
+					return err
+				}
+			}
+			for j := len(olds) - 1; j >= 0; j-- {
+				if err := df.emit(Operation{Op: "remove", Path: string(appendIndexPointer(ptr, i+j)), Old: olds[j]}); err != nil {
+					return err
+				}
+			}
+			return nil
+		default:
+			if err := df.value(a, b, ta, tb, appendIndexPointer(ptr, i)); err != nil {
+				return err
+			}
+		}
+	}
+}
+
+// raw 比较两个已缓冲的值
+func (df *differ) raw(a, b json.RawMessage, ptr []byte) error {
+	da, db := newValueReader(bytes.NewReader(a)), newValueReader(bytes.NewReader(b))
+	ta, err := da.next()
+	if err != nil {
+		return err
+	}
+	tb, err := db.next()
+	if err != nil {
+		return err
+	}
+	return df.value(da, db, ta, tb, ptr)
+}
+
+// scalarEqual 比较两个同类型的标量，字符串比较解码后的值，数字比较数值
+func scalarEqual(a, b Token) bool {
+	switch a.Type {
+	case TokenString:
+		return a.Val == b.Val
+	case TokenNumber:
+		return numberEqual(a.Val, b.Val)
+	case TokenBoolean, TokenNull:
+		return a.Val == b.Val
+	case TokenUnknown, TokenStringEscape, TokenObjectStart, TokenObjectEnd, TokenArrayStart, TokenArrayEnd, TokenKey,
+		TokenKeyEscape, TokenComma, TokenColon, TokenQuote, TokenWhitespace, TokenStringChunk:
+	}
+	return false
+}
+
+// numberEqual 判断两个JSON数字的值是否相等
+// 比较规范化后的有效数字和指数，不做浮点运算，指数再大也只需线性时间
+func numberEqual(a, b string) bool {
+	if a == b {
+		return true
+	}
+	da, ea, okA := normalizeNumber(a)
+	db, eb, okB := normalizeNumber(b)
+	return okA && okB && da == db && ea.Cmp(eb) == 0
+}
+
+// normalizeNumber 将数字分解为带符号的有效数字和指数，值为 0.digits × 10^exp
+// 有效数字不含首尾的零，零的有效数字为 "0"、指数为 0
+func normalizeNumber(s string) (digits string, exp *big.Int, ok bool) {
+	mant, e, hasExp := strings.Cut(strings.ToLower(s), "e")
+	sign := ""
+	if strings.HasPrefix(mant, "-") {
+		sign, mant = "-", mant[1:]
+	}
+	intPart, frac, _ := strings.Cut(mant, ".")
+	if intPart == "" || !allDigits(intPart) || !allDigits(frac) {
+		return "", nil, false
+	}
+	exp = new(big.Int)
+	if hasExp {
+		if _, ok := exp.SetString(e, 10); !ok {
+			return "", nil, false
+		}
+	}
+	mant = intPart + frac
+	point := len(intPart)
+	trimmed := strings.TrimLeft(mant, "0")
+	point -= len(mant) - len(trimmed)
+	trimmed = strings.TrimRight(trimmed, "0")
+	if trimmed == "" {
+		return "0", exp.SetInt64(0), true
+	}
+	return sign + trimmed, exp.Add(exp, big.NewInt(int64(point))), true
+}
+
+// allDigits 判断 s 是否只包含十进制数字
+func allDigits(s string) bool {
+	for i := 0; i < len(s); i++ {
+		if s[i] < '0' || s[i] > '9' {
+			return false
+		}
+	}
+	return true
+}
+
+// pointerEscaper 按 RFC 6901 转义 JSON Pointer 中的键名
+var pointerEscaper = strings.NewReplacer("~", "~0", "/", "~1")
+
+// appendPointer 向 JSON Pointer 追加一个键名
+func appendPointer(ptr []byte, key string) []byte {
+	p := make([]byte, 0, len(ptr)+len(key)+1)
+	p = append(append(p, ptr...), '/')
+	return append(p, pointerEscaper.Replace(key)...)
+}
+
+// appendIndexPointer 向 JSON Pointer 追加一个数组下标
+func appendIndexPointer(ptr []byte, i int) []byte {
+	p := make([]byte, 0, len(ptr)+8)
+	p = append(append(p, ptr...), '/')
+	return strconv.AppendInt(p, int64(i), 10)
+}
@@ -0,0 +1,107 @@
+package jsontokenizer
+
+import (
+	"errors"
+	"io"
+	"strings"
+	"testing"
+	"testing/iotest"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func diffOps(t *testing.T, a, b string) []Operation {
+	t.Helper()
+	var ops []Operation
+	err := Diff(iotest.OneByteReader(strings.NewReader(a)), strings.NewReader(b), func(op Operation) error {
+		ops = append(ops, op)
+		return nil
+	})
+	require.NoError(t, err)
+	return ops
+}
+
+func TestDiff(t *testing.T) {
+	a := `{"id": 1, "name": "x", "tags": ["a", "b", "c"], "meta": {"v": 1.0, "k/~": true}, "gone": null}`
+	b := `{"id":1,"name":"y","tags":["a","B"],"meta":{"v":1,"k/~":false},"gone":null}`
+	assert.Equal(t, []Operation{
+		{Op: "replace", Path: "/name", Value: []byte(`"y"`), Old: []byte(`"x"`)},
+		{Op: "replace", Path: "/tags/1", Value: []byte(`"B"`), Old: []byte(`"b"`)},
+		{Op: "remove", Path: "/tags/2", Old: []byte(`"c"`)},
+		{Op: "replace", Path: "/meta/k~1~0", Value: []byte(`false`), Old: []byte(`true`)},
+	}, diffOps(t, a, b))
+}
+
+func TestDiff_Equal(t *testing.T) {
+	doc := `{"a":[1,{"b":"é"}],"c":1e2}`
+	assert.Empty(t, diffOps(t, doc, `{"a": [1, {"b": "é"}], "c": 100}`))
+}
+
+func TestDiff_ReorderedKeys(t *testing.T) {
+	a := `{"a":1,"b":{"x":1},"c":2,"d":3}`
+	b := `{"a":1,"c":2,"b":{"x":2},"e":[]}`
+	assert.Equal(t, []Operation{
+		{Op: "remove", Path: "/d", Old: []byte(`3`)},
+		{Op: "replace", Path: "/b/x", Value: []byte(`2`), Old: []byte(`1`)},
+		{Op: "add", Path: "/e", Value: []byte(`[]`)},
+	}, diffOps(t, a, b))
+}
+
+func TestDiff_Arrays(t *testing.T) {
+	assert.Equal(t, []Operation{
+		{Op: "add", Path: "/1", Value: []byte(`{"a":[2]}`)},
+		{Op: "add", Path: "/2", Value: []byte(`3`)},
+	}, diffOps(t, `[1]`, `[1, {"a": [2]}, 3]`))
+
+	assert.Equal(t, []Operation{
+		{Op: "remove", Path: "/2", Old: []byte(`[3]`)},
+		{Op: "remove", Path: "/1", Old: []byte(`2`)},
+	}, diffOps(t, `[1,2,[3]]`, `[1]`))
+}
+
+func TestDiff_TypeChange(t *testing.T) {
+	assert.Equal(t, []Operation{
+		{Op: "replace", Path: "", Value: []byte(`{"a":1}`), Old: []byte(`[1,2]`)},
+	}, diffOps(t, `[1, 2]`, `{"a": 1}`))
+	assert.Equal(t, []Operation{
+		{Op: "replace", Path: "/a", Value: []byte(`"1"`), Old: []byte(`1`)},
+	}, diffOps(t, `{"a":1}`, `{"a":"1"}`))
+}
+
+func TestNumberEqual(t *testing.T) {
+	tests := []struct {
+		a, b  string
+		equal bool
+	}{
+		{"1", "1.0", true},
+		{"100", "1e2", true},
+		{"0.0125", "125E-4", true},
+		{"-1.50", "-15e-1", true},
+		{"0", "-0.0e7", true},
+		{"007", "7", true},
+		{"1", "-1", false},
+		{"1.5", "15", false},
+		{"1e999999999", "10e999999998", true},
+		{"1e999999999", "1e999999998", false},
+		{"1e99999999999999999999", "1e99999999999999999998", false},
+		{"1", "1e", false},
+		{"1.2.3", "1.23", false},
+	}
+	for _, tt := range tests {
+		assert.Equal(t, tt.equal, numberEqual(tt.a, tt.b), "%s == %s", tt.a, tt.b)
+	}
+}
+
+func TestDiff_Errors(t *testing.T) {
+	emit := func(Operation) error { return nil }
+	assert.ErrorIs(t, Diff(strings.NewReader(`{"a":`), strings.NewReader(`{"a":1}`), emit), io.ErrUnexpectedEOF)
+	assert.ErrorIs(t, Diff(strings.NewReader(``), strings.NewReader(`1`), emit), io.ErrUnexpectedEOF)
+
+	var se *SyntaxError
+	assert.ErrorAs(t, Diff(strings.NewReader(`[1]`), strings.NewReader(`[1,]`), emit), &se)
+
+	stop := errors.New("stop")
+	err := Diff(strings.NewReader(`[1,2]`), strings.NewReader(`[3,4]`), func(Operation) error { return stop })
+	assert.ErrorIs(t, err, stop)
+}
@@ -0,0 +1,99 @@
+package jsontokenizer
+
+import "strings"
+
+// EmbeddedPathSeparator separates the path of a string value holding JSON
+// from the paths inside it, as in $.arguments#$.city.
+const EmbeddedPathSeparator = "#"
+
+// EmbeddedJSON makes coalesced mode tokenize string values at the given paths
+// as nested JSON documents, for APIs that return JSON encoded in a string,
+// such as "arguments": "{\"city\":\"Paris\"}". Paths are written as in
+// Token.Path, and a [*] segment matches any array index, as in
+// $.tool_calls[*].function.arguments. EmbeddedJSON enables coalesced mode.
+//
+// The content of such a string is unescaped as it arrives and fed to a child
+// Tokenizer in coalesced mode, which also reports string chunks if
+// StringChunks is enabled. The child's tokens are reported with the string's
+// path, EmbeddedPathSeparator and their own path, such as
+// $.arguments#$.city, before the tokens of the string itself, which are
+// reported unchanged. Strings that do not hold valid JSON produce whatever
+// tokens their content yields.
+func (p *Tokenizer) EmbeddedJSON(paths ...string) {
+	p.coalesce = true
+	p.embedded = append(p.embedded, paths...)
+}
+
+// embeddedDoc 是正在分词的字符串中嵌入的 JSON 文档
+type embeddedDoc struct {
+	t      *Tokenizer
+	prefix string // 字符串的路径加分隔符
+	fed    int    // 已交给子分词器的解码字节数
+}
+
+// startEmbedded 在路径匹配的字符串值开始时创建子分词器
+func (p *Tokenizer) startEmbedded(path string) {
+	for _, pattern := range p.embedded {
+		if matchPathPattern(pattern, path) {
+			t := NewTokenizer()
+			t.Coalesce()
+			p.nested = &embeddedDoc{t: t, prefix: path + EmbeddedPathSeparator}
+			return
+		}
+	}
+}
+
+// feedEmbedded 将字符串新解码的内容交给子分词器，end 表示字符串已结束
+func (p *Tokenizer) feedEmbedded(end bool) {
+	n := p.nested
+	var tokens []Token
+	if n.fed < len(p.elem.val) {
+		tokens = n.t.Feed(p.elem.val[n.fed:])
+		n.fed = len(p.elem.val)
+	}
+	if end {
+		tokens = append(tokens, n.t.Flush()...)
+	}
+	p.appendEmbedded(tokens)
+	if end {
+		p.nested = nil
+	}
+}
+
+// flushEmbeddedChunk 在 Feed 结束时输出嵌入文档中未结束字符串的分片
+func (p *Tokenizer) flushEmbeddedChunk() {
+	t := p.nested.t
+	t.out = t.out[:0]
+	t.flushStringChunk()
+	p.appendEmbedded(t.out)
+}
+
+// appendEmbedded 以组合后的路径输出子分词器的Token
+func (p *Tokenizer) appendEmbedded(tokens []Token) {
+	for _, tk := range tokens {
+		tk.Path = p.nested.prefix + tk.Path
+		p.out = append(p.out, tk)
+	}
+}
+
+// matchPathPattern 判断路径是否匹配模式，模式中的 [*] 匹配任意数组下标
+func matchPathPattern(pattern, path string) bool {
+	for {
+		before, after, ok := strings.Cut(pattern, "[*]")
+		if !ok {
+			return path == pattern
+		}
+		if !strings.HasPrefix(path, before+"[") {
+			return false
+		}
+		path = path[len(before)+1:]
+		i := 0
+		for i < len(path) && path[i] >= '0' && path[i] <= '9' {
+			i++
+		}
+		if i == 0 || i == len(path) || path[i] != ']' {
+			return false
+		}
+		path, pattern = path[i+1:], after
+	}
+}
@@ -0,0 +1,97 @@
+package jsontokenizer
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// valueTokens 返回值级别的Token，格式为 "路径 类型 值"
+func valueTokens(tokens []Token) []string {
+	var out []string
+	for _, tk := range tokens {
+		if isValueToken(tk.Type) {
+			out = append(out, tk.Path+" "+tk.Type.String()+" "+tk.Val)
+		}
+	}
+	return out
+}
+
+func TestTokenizer_EmbeddedJSON(t *testing.T) {
+	input := `{"name":"get_weather","arguments":"{\"city\":\"Par\\u00efs\",\"days\":[1, 2],\"q\":\"a\\\"b\"}","other":"{\"x\":1}"}`
+	expected := []string{
+		"$.name String get_weather",
+		`$.arguments#$.city String Parïs`,
+		"$.arguments#$.days[0] Number 1",
+		"$.arguments#$.days[1] Number 2",
+		`$.arguments#$.q String a"b`,
+		`$.arguments String {"city":"Par\u00efs","days":[1, 2],"q":"a\"b"}`,
+		`$.other String {"x":1}`,
+	}
+	for _, size := range []int{1, 2, 7, len(input)} {
+		tk := NewTokenizer()
+		tk.EmbeddedJSON("$.arguments")
+		var tokens []Token
+		for b := []byte(input); len(b) > 0; {
+			n := min(size, len(b))
+			tokens = append(tokens, tk.Feed(b[:n])...)
+			b = b[n:]
+		}
+		tokens = append(tokens, tk.Flush()...)
+		assert.Equal(t, expected, valueTokens(tokens), "chunk size %d", size)
+	}
+}
+
+func TestTokenizer_EmbeddedJSONStreaming(t *testing.T) {
+	tk := NewTokenizer()
+	tk.StringChunks()
+	tk.EmbeddedJSON("$.calls[*].arguments")
+
+	// 外层字符串结束前，嵌入文档中已完成的值就已输出
+	got := valueTokens(tk.Feed([]byte(`{"calls":[{"arguments":"{\"city\":\"Pa`)))
+	assert.Equal(t, []string{
+		"$.calls[0].arguments#$.city StringChunk Pa",
+		`$.calls[0].arguments StringChunk {"city":"Pa`,
+	}, got)
+
+	got = valueTokens(tk.Feed([]byte(`ris\",\"n\":4`)))
+	assert.Equal(t, []string{
+		"$.calls[0].arguments#$.city String ris",
+		`$.calls[0].arguments StringChunk ris","n":4`,
+	}, got)
+
+	// 嵌入文档末尾的数字在外层字符串结束时输出
+	got = valueTokens(tk.Feed([]byte(`2}"},{"arguments":"7"}]}`)))
+	assert.Equal(t, []string{
+		"$.calls[0].arguments#$.n Number 42",
+		"$.calls[0].arguments String 2}",
+		"$.calls[1].arguments#$ Number 7",
+		"$.calls[1].arguments String 7",
+	}, got)
+}
+
+func TestTokenizer_EmbeddedJSONOnlyValues(t *testing.T) {
+	tk := NewTokenizer()
+	tk.EmbeddedJSON("$.a", "$[*]")
+	got := valueTokens(append(tk.Feed([]byte(`{"a":1,"b":"{\"x\":true}"}`)), tk.Flush()...))
+	assert.Equal(t, []string{"$.a Number 1", `$.b String {"x":true}`}, got)
+}
+
+func TestMatchPathPattern(t *testing.T) {
+	tests := []struct {
+		pattern, path string
+		match         bool
+	}{
+		{"$.a", "$.a", true},
+		{"$.a", "$.ab", false},
+		{"$.a[*].b", "$.a[12].b", true},
+		{"$.a[*].b", "$.a[].b", false},
+		{"$.a[*].b", "$.a[1].c", false},
+		{"$[*][*]", "$[0][3]", true},
+		{"$[*]", "$[0]x", false},
+		{"$[*]", "$.x", false},
+	}
+	for _, tt := range tests {
+		assert.Equal(t, tt.match, matchPathPattern(tt.pattern, tt.path), "%s %s", tt.pattern, tt.path)
+	}
+}
@@ -0,0 +1,248 @@
+package jsontokenizer
+
+import (
+	"encoding/binary"
+	"unicode/utf8"
+)
+
+// runSink 接收 feed 扫描出的字符片段
+// val 借用自输入，仅在调用期间有效
+type runSink interface {
+	emit(t TokenType, path string, val []byte)
+	// valueEnd 在结束数字或关键字的字符被处理之前调用，此时容器栈仍指向该值
+	valueEnd()
+}
+
+// feed 以字节为单位批量处理输入
+// 字符串、数字、关键字和空白等连续同类字符会被整段扫描后一次性交给 s，
+// 其余字符按 rune 逐个走状态机。调用方需保证 b 不以不完整的 UTF-8 字符结尾。
+func (p *innerTokenizer) feed(b []byte, s runSink) {
+	for len(b) > 0 {
+		if n, t := p.consumeRun(b); n > 0 {
+			s.emit(t, p.getPathCache(), b[:n])
+			b = b[n:]
+			continue
+		}
+		if p.state == stateNumber || p.state == stateBoolean || p.state == stateNull {
+			s.valueEnd()
+		}
+		r, size := utf8.DecodeRune(b)
+		e := p.Push(r)
+		s.emit(e.Type, e.Path, b[:size])
+		b = b[size:]
+	}
+}
+
+// consumeRun 在当前状态下批量消费一段同类字符
+// 返回消费的字节数及其事件类型，返回 0 表示当前字符需要逐个处理
+func (p *innerTokenizer) consumeRun(b []byte) (int, TokenType) {
+	switch p.state {
+	case stateKey:
+		if p.escapeNext {
+			return 0, TokenUnknown
+		}
+		n := indexStringSpecial(b)
+		p.buffer = append(p.buffer, b[:n]...)
+		return n, TokenKey
+	case stateString:
+		if p.escapeNext {
+			return 0, TokenUnknown
+		}
+		// 值字符串的内容不会被读取，无需写入缓冲区
+		return indexStringSpecial(b), TokenString
+	case stateNumber:
+		n := 0
+		for n < len(b) && isNumberChar(rune(b[n])) {
+			n++
+		}
+		return n, TokenNumber
+	case stateBoolean, stateNull:
+		n := 0
+		for n < len(b) && isKeywordChar(rune(b[n])) {
+			n++
+		}
+		p.buffer = append(p.buffer, b[:n]...)
+		if p.state == stateNull {
+			return n, TokenNull
+		}
+		return n, TokenBoolean
+	case stateIdle:
+		n := 0
+		for n < len(b) && isWhitespace(rune(b[n])) {
+			n++
+		}
+		return n, TokenWhitespace
+	}
+	return 0, TokenUnknown
+}
+
+// SWAR 常量：每个字节的最低位与最高位
+const (
+	swarLSB = 0x0101010101010101
+	swarMSB = 0x8080808080808080
+)
+
+// indexStringSpecial 返回 b 中第一个引号或反斜杠的位置，不存在时返回 len(b)
+// 每次检查 8 个字节，只有命中的字长才逐字节确认
+func indexStringSpecial(b []byte) int {
+	i := 0
+	for ; i+8 <= len(b); i += 8 {
+		w := binary.LittleEndian.Uint64(b[i:])
+		if hasByte(w, '"')|hasByte(w, '\\') != 0 {
+			break
+		}
+	}
+	for ; i < len(b); i++ {
+		if b[i] == '"' || b[i] == '\\' {
+			return i
+		}
+	}
+	return len(b)
+}
+
+// hasByte 判断字 w 中是否包含字节 c，包含时返回非零值
+func hasByte(w uint64, c byte) uint64 {
+	x := w ^ (swarLSB * uint64(c))
+	return (x - swarLSB) &^ x & swarMSB
+}
+
+// isNumberChar 检查字符是否可以出现在数字中
+func isNumberChar(r rune) bool {
+	return isDigit(r) || r == '.' || r == 'e' || r == 'E' || r == '+' || r == '-'
+}
+
+// isWhitespace 检查字符是否为JSON空白字符
+func isWhitespace(r rune) bool {
+	return r == ' ' || r == '\t' || r == '\n' || r == '\r'
+}
+
+// coalescable reports whether consecutive tokens of this type can be merged.
+func (t TokenType) coalescable() bool {
+	switch t {
+	case TokenString, TokenKey, TokenNumber, TokenBoolean, TokenNull, TokenWhitespace:
+		return true
+	case TokenUnknown, TokenStringEscape, TokenObjectStart, TokenObjectEnd, TokenArrayStart, TokenArrayEnd,
+		TokenKeyEscape, TokenComma, TokenColon, TokenQuote, TokenStringChunk:
+		return false
+	}
+	return false
+}
+
+// Feed pushes a chunk of UTF-8 encoded input through the tokenizer and returns
+// the tokens it produced.
+//
+// Unlike Push, Feed scans runs of string content, keys, numbers, literals and
+// whitespace in bulk, and consecutive characters sharing a type and path are
+// coalesced into a single token with a multi-character Val. Concatenating the
+// Vals of the returned tokens reproduces the input (or its unescaped form when
+// AutoEscape is enabled). A multi-byte character split across two calls is
+// held back until the call that completes it. See Coalesce for reporting whole
+// lexical elements instead.
+//
+// The returned slice is reused by the next call to Feed; copy it to retain
+// the tokens beyond that.
+func (p *Tokenizer) Feed(b []byte) []Token {
+	// 复用上一次调用的输出缓冲区，按需扩容
+	p.out = p.out[:0]
+	p.pending = p.inner.feedUTF8(p.pending, b, p)
+
+	if p.stringChunks {
+		if p.nested != nil {
+			p.flushEmbeddedChunk()
+		}
+		p.flushStringChunk()
+	}
+	p.closeToken()
+	return p.out
+}
+
+// emit implements runSink.
+func (p *Tokenizer) emit(t TokenType, path string, val []byte) {
+	if p.coalesce {
+		p.coalesceRun(t, path, val)
+		return
+	}
+	if p.autoEscape {
+		switch {
+		case t == TokenStringEscape:
+			p.escaping = true
+			p.buf = append(p.buf, '\\')
+			return
+		case t == TokenString && p.escaping:
+			for p.escaping && len(val) > 0 {
+				r, size := utf8.DecodeRune(val)
+				val = val[size:]
+				if unescaped, ok := p.unescape(r); ok {
+					p.appendToken(t, path, []byte(unescaped))
+				}
+			}
+			if len(val) == 0 {
+				return
+			}
+		}
+	}
+	p.appendToken(t, path, val)
+}
+
+// valueEnd implements runSink. Feed ends numbers and literals when the next
+// run arrives, so there is nothing to do here.
+func (p *Tokenizer) valueEnd() {}
+
+// appendToken adds a token to the output of the current Feed call, merging it
+// into the previous token when both share a coalescable type and path.
+func (p *Tokenizer) appendToken(t TokenType, path string, val []byte) {
+	if n := len(p.out); n > 0 && p.accOpen && t.coalescable() &&
+		p.out[n-1].Type == t && p.out[n-1].Path == path {
+		p.acc = append(p.acc, val...)
+		return
+	}
+	p.closeToken()
+	p.out = append(p.out, Token{Type: t, Path: path})
+	p.acc = append(p.acc[:0], val...)
+	p.accOpen = true
+}
+
+// closeToken finalizes the Val of the last token in the output.
+func (p *Tokenizer) closeToken() {
+	if !p.accOpen {
+		return
+	}
+	p.out[len(p.out)-1].Val = string(p.acc)
+	p.accOpen = false
+}
+
+// feedUTF8 处理可能在任意字节处被截断的输入
+// pending 是上一次调用留下的不完整字符，返回本次末尾留下的不完整字符
+func (p *innerTokenizer) feedUTF8(pending, b []byte, s runSink) []byte {
+	if len(pending) > 0 {
+		for len(b) > 0 && !utf8.FullRune(pending) {
+			pending = append(pending, b[0])
+			b = b[1:]
+		}
+		if !utf8.FullRune(pending) {
+			return pending
+		}
+		p.feed(pending, s)
+		pending = pending[:0]
+	}
+	n := len(b) - incompleteSuffix(b)
+	p.feed(b[:n], s)
+	return append(pending, b[n:]...)
+}
+
+// incompleteSuffix 返回 b 末尾不完整 UTF-8 字符的字节数
+func incompleteSuffix(b []byte) int {
+	for i := 1; i < utf8.UTFMax && i <= len(b); i++ {
+		c := b[len(b)-i]
+		if c < utf8.RuneSelf {
+			return 0
+		}
+		if utf8.RuneStart(c) {
+			if utf8.FullRune(b[len(b)-i:]) {
+				return 0
+			}
+			return i
+		}
+	}
+	return 0
+}
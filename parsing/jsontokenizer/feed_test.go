@@ -0,0 +1,206 @@
+package jsontokenizer
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// mergeTokens 合并相邻的同类型同路径Token，用于比较Push与Feed的输出
+func mergeTokens(tokens []Token) []Token {
+	var merged []Token
+	for _, tk := range tokens {
+		if n := len(merged); n > 0 && tk.Type.coalescable() &&
+			merged[n-1].Type == tk.Type && merged[n-1].Path == tk.Path {
+			merged[n-1].Val += tk.Val
+			continue
+		}
+		merged = append(merged, tk)
+	}
+	return merged
+}
+
+func pushAll(t *Tokenizer, s string) []Token {
+	var tokens []Token
+	for _, r := range s {
+		if tk := t.Push(r); tk != nil {
+			tokens = append(tokens, *tk)
+		}
+	}
+	return tokens
+}
+
+func feedChunks(t *Tokenizer, b []byte, size int) []Token {
+	var tokens []Token
+	for len(b) > 0 {
+		n := min(size, len(b))
+		tokens = append(tokens, t.Feed(b[:n])...)
+		b = b[n:]
+	}
+	return tokens
+}
+
+var feedInputs = []string{
+	`{"a":"te\n\"st", "b":42}`,
+	`{"a":{"b":[1,2,"3"],"c":true,"d":{"e":null}},"fake":-1.1}`,
+	`{"key_with_e\n\"":"value_with_escapeA"}`,
+	`{"users":[{"id":1,"profile":{"name":"张三"}},{"id":2,"profile":{"name":"李四 🚀"}}]}`,
+	"[\n  1.5e10 ,\t-0.25,\r\n  false, null ,\"\\\\\"\n]",
+	`"a long root string that spans more than a single eight byte word"`,
+	`-12.5`,
+}
+
+func TestFeed_MatchesPush(t *testing.T) {
+	for _, input := range feedInputs {
+		want := mergeTokens(pushAll(NewTokenizer(), input))
+		for _, size := range []int{1, 2, 3, 7, 64, len(input)} {
+			got := mergeTokens(feedChunks(NewTokenizer(), []byte(input), size))
+			assert.Equal(t, want, got, "input %q, chunk size %d", input, size)
+		}
+	}
+}
+
+func TestFeed_AutoEscapeMatchesPush(t *testing.T) {
+	for _, input := range feedInputs {
+		pt := NewTokenizer()
+		pt.AutoEscape()
+		want := mergeTokens(pushAll(pt, input))
+		for _, size := range []int{1, 5, len(input)} {
+			ft := NewTokenizer()
+			ft.AutoEscape()
+			got := mergeTokens(feedChunks(ft, []byte(input), size))
+			assert.Equal(t, want, got, "input %q, chunk size %d", input, size)
+		}
+	}
+}
+
+func TestFeed_Coalesces(t *testing.T) {
+	tokens := NewTokenizer().Feed([]byte(`{"name": "hello", "n": 123}`))
+
+	expected := []Token{
+		{Type: TokenObjectStart, Path: "$", Val: "{"},
+		{Type: TokenQuote, Path: "$", Val: `"`},
+		{Type: TokenKey, Path: "$", Val: "name"},
+		{Type: TokenQuote, Path: "$", Val: `"`},
+		{Type: TokenColon, Path: "$.name", Val: ":"},
+		{Type: TokenWhitespace, Path: "$.name", Val: " "},
+		{Type: TokenQuote, Path: "$.name", Val: `"`},
+		{Type: TokenString, Path: "$.name", Val: "hello"},
+		{Type: TokenQuote, Path: "$.name", Val: `"`},
+		{Type: TokenComma, Path: "$", Val: ","},
+		{Type: TokenWhitespace, Path: "$", Val: " "},
+		{Type: TokenQuote, Path: "$", Val: `"`},
+		{Type: TokenKey, Path: "$", Val: "n"},
+		{Type: TokenQuote, Path: "$", Val: `"`},
+		{Type: TokenColon, Path: "$.n", Val: ":"},
+		{Type: TokenWhitespace, Path: "$.n", Val: " "},
+		{Type: TokenNumber, Path: "$.n", Val: "123"},
+		{Type: TokenObjectEnd, Path: "$", Val: "}"},
+	}
+	assert.Equal(t, expected, tokens)
+}
+
+func TestFeed_SplitMultiByteRune(t *testing.T) {
+	input := []byte(`["张三"]`)
+	z := NewTokenizer()
+
+	var got strings.Builder
+	for i := range input {
+		for _, tk := range z.Feed(input[i : i+1]) {
+			got.WriteString(tk.Val)
+		}
+	}
+	require.Equal(t, string(input), got.String())
+}
+
+func TestIndexStringSpecial(t *testing.T) {
+	cases := map[string]int{
+		``:                   0,
+		`abc`:                3,
+		`"`:                  0,
+		`abcdefgh"`:          8,
+		`abcdefghijklmno\`:   15,
+		`abc"defghijklmnop\`: 3,
+		`中文字符串中文字符串"`:        len(`中文字符串中文字符串`),
+	}
+	for in, want := range cases {
+		assert.Equal(t, want, indexStringSpecial([]byte(in)), "input %q", in)
+	}
+}
+
+// benchmarkDocument 生成一个包含常见JSON结构的测试文档
+func benchmarkDocument(n int) []byte {
+	var buf bytes.Buffer
+	buf.WriteString(`{"users":[`)
+	for i := range n {
+		if i > 0 {
+			buf.WriteString(",")
+		}
+		fmt.Fprintf(&buf, `{"id":%d,"name":"user %d","email":"user%d@example.com",`+
+			`"active":%t,"score":%d.%d,"tags":["alpha","beta","gamma"],"bio":"line one\nline \"two\"",`+
+			`"address":{"city":"Springfield","zip":"%05d"},"manager":null}`,
+			i, i, i, i%2 == 0, i*7, i%10, i)
+	}
+	buf.WriteString(`]}`)
+	return buf.Bytes()
+}
+
+func BenchmarkTokenizer_Push(b *testing.B) {
+	data := string(benchmarkDocument(1000))
+	b.SetBytes(int64(len(data)))
+	b.ReportAllocs()
+	for b.Loop() {
+		z := NewTokenizer()
+		for _, r := range data {
+			z.Push(r)
+		}
+	}
+}
+
+func BenchmarkTokenizer_Feed(b *testing.B) {
+	data := benchmarkDocument(1000)
+	b.SetBytes(int64(len(data)))
+	b.ReportAllocs()
+	for b.Loop() {
+		z := NewTokenizer()
+		for chunk := data; len(chunk) > 0; {
+			n := min(32<<10, len(chunk))
+			z.Feed(chunk[:n])
+			chunk = chunk[n:]
+		}
+	}
+}
+
+func BenchmarkEncodingJSON_Unmarshal(b *testing.B) {
+	data := benchmarkDocument(1000)
+	b.SetBytes(int64(len(data)))
+	b.ReportAllocs()
+	for b.Loop() {
+		var v any
+		if err := json.Unmarshal(data, &v); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+func BenchmarkEncodingJSON_DecoderToken(b *testing.B) {
+	data := benchmarkDocument(1000)
+	b.SetBytes(int64(len(data)))
+	b.ReportAllocs()
+	for b.Loop() {
+		dec := json.NewDecoder(bytes.NewReader(data))
+		for {
+			if _, err := dec.Token(); err == io.EOF {
+				break
+			} else if err != nil {
+				b.Fatal(err)
+			}
+		}
+	}
+}
@@ -0,0 +1,310 @@
+package jsontokenizer
+
+import (
+	"bufio"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+
+	"github.com/libxyz/gizmo/parsing/jsontokenizer/internal/jsonstr"
+)
+
+// Flatten writes one "path = value" line per leaf of the JSON read from r,
+// in document order:
+//
+//	$.users[0].name = "x"
+//	$.users[0].tags = []
+//
+// Leaves are scalars and empty objects or arrays. Values keep their original
+// text. Keys that are not identifiers are written in bracket notation, as in
+// $["a.b"], so every line can be parsed back by Unflatten. If r holds several
+// top-level values, each is flattened in turn.
+func Flatten(r io.Reader, w io.Writer) error {
+	type frame struct {
+		base  int // 容器自身路径的长度
+		array bool
+		index int
+	}
+	d := NewDecoder(r)
+	bw := bufio.NewWriter(w)
+	path := []byte("$")
+	var stack []frame
+	empty := false // 刚刚进入一个容器，尚未遇到成员
+
+	// enter 在数组中开始一个新元素时追加下标
+	enter := func() {
+		if n := len(stack); n > 0 && stack[n-1].array {
+			f := &stack[n-1]
+			path = appendIndexSegment(path[:f.base], f.index)
+			f.index++
+		}
+	}
+	line := func(value string) {
+		bw.Write(path)
+		bw.WriteString(" = ")
+		bw.WriteString(value)
+		bw.WriteByte('\n')
+	}
+
+	for {
+		tk, err := d.step()
+		if errors.Is(err, io.EOF) {
+			return bw.Flush()
+		}
+		if err != nil {
+			return err
+		}
+		switch tk.Type {
+		case TokenObjectStart, TokenArrayStart:
+			enter()
+			stack = append(stack, frame{base: len(path), array: tk.Type == TokenArrayStart})
+			empty = true
+			continue
+		case TokenObjectEnd, TokenArrayEnd:
+			f := stack[len(stack)-1]
+			stack = stack[:len(stack)-1]
+			path = path[:f.base]
+			if empty {
+				if f.array {
+					line("[]")
+				} else {
+					line("{}")
+				}
+			}
+		case TokenKey:
+			path = appendKeySegment(path[:stack[len(stack)-1].base], tk.Val)
+		case TokenString, TokenNumber, TokenBoolean, TokenNull:
+			enter()
+			line(tk.Raw)
+		case TokenUnknown, TokenStringEscape, TokenKeyEscape, TokenComma, TokenColon, TokenQuote, TokenWhitespace,
+			TokenStringChunk:
+		}
+		empty = false
+	}
+}
+
+// Unflatten reads lines in the format written by Flatten and writes the JSON
+// document they describe to w. Lines may come in any order, so output that
+// has been filtered or sorted can be rebuilt; keys keep the order in which
+// they first appear and missing array elements become null. Empty lines are
+// ignored.
+func Unflatten(r io.Reader, w io.Writer) error {
+	var root *flatNode
+	br := bufio.NewReader(r)
+	for n := 1; ; n++ {
+		line, err := br.ReadString('\n')
+		if err != nil && !errors.Is(err, io.EOF) {
+			return fmt.Errorf("jsontokenizer: read: %w", err)
+		}
+		if s := strings.TrimRight(line, "\r\n"); strings.TrimSpace(s) != "" {
+			if root == nil {
+				root = &flatNode{}
+			}
+			if perr := root.set(s); perr != nil {
+				return fmt.Errorf("jsontokenizer: unflatten line %d: %w", n, perr)
+			}
+		}
+		if err != nil {
+			break
+		}
+	}
+	if root == nil {
+		return nil
+	}
+	bw := bufio.NewWriter(w)
+	root.writeTo(bw)
+	bw.WriteByte('\n')
+	return bw.Flush()
+}
+
+// flatNode 是 Unflatten 重建的文档树中的一个节点
+type flatNode struct {
+	raw   []byte               // 叶子值的原始文本
+	keys  []string             // 对象的键名，按首次出现的顺序
+	props map[string]*flatNode // 对象的成员
+	items []*flatNode          // 数组的元素，缺失的元素为 nil
+	array bool
+}
+
+// set 解析一行 "path = value" 并将值放入树中
+func (n *flatNode) set(line string) error {
+	segs, rest, err := parsePath(line)
+	if err != nil {
+		return err
+	}
+	value, ok := strings.CutPrefix(rest, " = ")
+	if !ok {
+		return fmt.Errorf("expected \" = \" after %s", line[:len(line)-len(rest)])
+	}
+	value = strings.TrimSpace(value)
+	if !json.Valid([]byte(value)) {
+		return fmt.Errorf("invalid value %s", value)
+	}
+	for _, seg := range segs {
+		if n, err = n.child(seg); err != nil {
+			return err
+		}
+	}
+	if value == "{}" && n.props != nil || value == "[]" && n.array {
+		return nil // 容器已由成员的行创建
+	}
+	if n.raw != nil || n.props != nil || n.array {
+		return fmt.Errorf("duplicate value for %s", line[:len(line)-len(rest)])
+	}
+	n.raw = []byte(value)
+	return nil
+}
+
+// child 返回 seg 对应的子节点，必要时创建。空对象和空数组可以继续添加成员
+func (n *flatNode) child(seg pathSegment) (*flatNode, error) {
+	if n.raw != nil {
+		if string(n.raw) != "{}" && string(n.raw) != "[]" || (string(n.raw) == "[]") != seg.isIndex {
+			return nil, fmt.Errorf("%s conflicts with value %s", seg, n.raw)
+		}
+		n.raw = nil
+	}
+
+	if seg.isIndex {
+		if n.props != nil {
+			return nil, fmt.Errorf("index %s in object", seg)
+		}
+		n.array = true
+		for len(n.items) <= seg.index {
+			n.items = append(n.items, nil)
+		}
+		if n.items[seg.index] == nil {
+			n.items[seg.index] = &flatNode{}
+		}
+		return n.items[seg.index], nil
+	}
+	if n.array {
+		return nil, fmt.Errorf("key %s in array", seg)
+	}
+	if n.props == nil {
+		n.props = make(map[string]*flatNode)
+	}
+	c, ok := n.props[seg.key]
+	if !ok {
+		c = &flatNode{}
+		n.props[seg.key] = c
+		n.keys = append(n.keys, seg.key)
+	}
+	return c, nil
+}
+
+// writeTo 以紧凑格式输出节点
+func (n *flatNode) writeTo(w *bufio.Writer) {
+	switch {
+	case n == nil:
+		w.WriteString("null")
+	case n.raw != nil:
+		w.Write(n.raw)
+	case n.array:
+		w.WriteByte('[')
+		for i, item := range n.items {
+			if i > 0 {
+				w.WriteByte(',')
+			}
+			item.writeTo(w)
+		}
+		w.WriteByte(']')
+	default:
+		w.WriteByte('{')
+		for i, k := range n.keys {
+			if i > 0 {
+				w.WriteByte(',')
+			}
+			w.Write(jsonstr.AppendQuote(nil, k))
+			w.WriteByte(':')
+			n.props[k].writeTo(w)
+		}
+		w.WriteByte('}')
+	}
+}
+
+// pathSegment 是路径中的一段：对象的键名或数组下标
+type pathSegment struct {
+	key     string
+	index   int
+	isIndex bool
+}
+
+func (s pathSegment) String() string {
+	if s.isIndex {
+		return string(appendIndexSegment(nil, s.index))
+	}
+	return string(appendKeySegment(nil, s.key))
+}
+
+// parsePath 解析以 $ 开头的路径，如 $.a[0]["b.c"]，返回各段和路径之后的剩余文本
+func parsePath(s string) ([]pathSegment, string, error) {
+	if !strings.HasPrefix(s, "$") {
+		return nil, s, errors.New("path must start with $")
+	}
+	var segs []pathSegment
+	rest := s[1:]
+	for rest != "" {
+		switch {
+		case rest[0] == '.':
+			end := 1
+			for end < len(rest) && isIdentByte(rest[end], end > 1) {
+				end++
+			}
+			if end == 1 {
+				return nil, rest, fmt.Errorf("invalid key at %q", rest)
+			}
+			segs = append(segs, pathSegment{key: rest[1:end]})
+			rest = rest[end:]
+		case strings.HasPrefix(rest, `["`):
+			end := 2
+			for end < len(rest) && rest[end] != '"' {
+				if rest[end] == '\\' {
+					end++
+				}
+				end++
+			}
+			var key string
+			if end+1 >= len(rest) || rest[end+1] != ']' || json.Unmarshal([]byte(rest[1:end+1]), &key) != nil {
+				return nil, rest, fmt.Errorf("invalid quoted key at %q", rest)
+			}
+			segs = append(segs, pathSegment{key: key})
+			rest = rest[end+2:]
+		case rest[0] == '[':
+			end := strings.IndexByte(rest, ']')
+			index, err := strconv.Atoi(rest[1:max(end, 1)])
+			if end < 0 || err != nil || index < 0 || rest[1] == '+' {
+				return nil, rest, fmt.Errorf("invalid index at %q", rest)
+			}
+			segs = append(segs, pathSegment{index: index, isIndex: true})
+			rest = rest[end+1:]
+		default:
+			return segs, rest, nil
+		}
+	}
+	return segs, rest, nil
+}
+
+// appendKeySegment 追加键名段，非标识符的键名使用 ["..."] 形式
+func appendKeySegment(dst []byte, key string) []byte {
+	ident := key != ""
+	for i := 0; i < len(key) && ident; i++ {
+		ident = isIdentByte(key[i], i > 0)
+	}
+	if ident {
+		return append(append(dst, '.'), key...)
+	}
+	return append(jsonstr.AppendQuote(append(dst, '['), key), ']')
+}
+
+// appendIndexSegment 追加数组下标段
+func appendIndexSegment(dst []byte, index int) []byte {
+	return append(strconv.AppendInt(append(dst, '['), int64(index), 10), ']')
+}
+
+// isIdentByte 判断字节能否出现在标识符中，digit 表示是否允许数字
+func isIdentByte(c byte, digit bool) bool {
+	return c == '_' || c >= 'a' && c <= 'z' || c >= 'A' && c <= 'Z' || digit && c >= '0' && c <= '9'
+}
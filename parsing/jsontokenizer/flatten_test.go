@@ -0,0 +1,87 @@
+package jsontokenizer
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+	"testing/iotest"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestFlatten(t *testing.T) {
+	input := `{"users":[{"name":"x","tags":[]},{"name":"y\n","age":1.50}],
+		"a.b":{},"":null,"n":{"":"e"},"k1":true,"$":"é"}`
+	expected := strings.Join([]string{
+		`$.users[0].name = "x"`,
+		`$.users[0].tags = []`,
+		`$.users[1].name = "y\n"`,
+		`$.users[1].age = 1.50`,
+		`$["a.b"] = {}`,
+		`$[""] = null`,
+		`$.n[""] = "e"`,
+		`$.k1 = true`,
+		`$["$"] = "é"`,
+	}, "\n") + "\n"
+
+	var out bytes.Buffer
+	require.NoError(t, Flatten(iotest.OneByteReader(strings.NewReader(input)), &out))
+	assert.Equal(t, expected, out.String())
+
+	out.Reset()
+	require.NoError(t, Flatten(strings.NewReader(`42 []`), &out))
+	assert.Equal(t, "$ = 42\n$ = []\n", out.String())
+}
+
+func TestFlatten_SyntaxError(t *testing.T) {
+	var out bytes.Buffer
+	err := Flatten(strings.NewReader(`{"a":1,}`), &out)
+	var se *SyntaxError
+	assert.ErrorAs(t, err, &se)
+}
+
+func TestUnflatten_RoundTrip(t *testing.T) {
+	input := `{"users":[{"name":"x","tags":[]},{"name":"y\n","age":1.50}],"a.b":{},"":null,"n":{"":"v"},"e":[[],[1,{"\"":"q"}]]}`
+	var flat, out bytes.Buffer
+	require.NoError(t, Flatten(strings.NewReader(input), &flat))
+	require.NoError(t, Unflatten(&flat, &out))
+	assert.Equal(t, input+"\n", out.String())
+}
+
+func TestUnflatten_AnyOrder(t *testing.T) {
+	lines := "$.a[2] = 3\n\n$.a = []\n$.b.c = \"x\"\r\n$.a[0] = 1\n$.b = {}\n"
+	var out bytes.Buffer
+	require.NoError(t, Unflatten(strings.NewReader(lines), &out))
+	assert.JSONEq(t, `{"a":[1,null,3],"b":{"c":"x"}}`, out.String())
+
+	out.Reset()
+	require.NoError(t, Unflatten(strings.NewReader(""), &out))
+	assert.Empty(t, out.String())
+}
+
+func TestUnflatten_Errors(t *testing.T) {
+	for _, lines := range []string{
+		"a = 1",
+		"$.a 1",
+		"$.a = nope",
+		"$.a = 1\n$.a = 2",
+		"$.a = 1\n$.a.b = 2",
+		"$.a[0] = 1\n$.a.b = 2",
+		"$.a.b = 1\n$.a[0] = 2",
+		`$["a = 1`,
+		"$[x] = 1",
+	} {
+		err := Unflatten(strings.NewReader(lines), &bytes.Buffer{})
+		assert.Error(t, err, lines)
+	}
+}
+
+func TestParsePath(t *testing.T) {
+	segs, rest, err := parsePath(`$.a_1[12]["x.y\"z"][0] = 1`)
+	require.NoError(t, err)
+	assert.Equal(t, []pathSegment{
+		{key: "a_1"}, {index: 12, isIndex: true}, {key: `x.y"z`}, {isIndex: true},
+	}, segs)
+	assert.Equal(t, " = 1", rest)
+}
@@ -0,0 +1,259 @@
+package jsontokenizer
+
+import (
+	"bufio"
+	"bytes"
+	"cmp"
+	"errors"
+	"fmt"
+	"io"
+	"slices"
+	"strings"
+)
+
+// FormatOptions configures Format.
+type FormatOptions struct {
+	// Indent is the number of spaces per nesting level, 2 if zero. Format
+	// rejects a negative Indent; use Minify for output without indentation.
+	Indent int
+	// Tabs indents with one tab per level instead of spaces.
+	Tabs bool
+	// SortKeys orders object members by key. Each object is buffered until
+	// its end to sort it, so memory grows with the largest object.
+	SortKeys bool
+	// CompactArrays prints arrays that contain only scalars on one line, as
+	// in [1, 2, 3]. Such arrays are buffered until their end.
+	CompactArrays bool
+}
+
+// Format pretty-prints the JSON values read from r to w, one per line. The
+// text of strings and numbers is copied unchanged, so 1.0 stays 1.0 and
+// escapes are kept. Apart from sorted objects and compact arrays the input
+// is streamed; on a syntax error the output written so far is flushed and
+// the error returned.
+func Format(r io.Reader, w io.Writer, opts FormatOptions) error {
+	if opts.Indent < 0 {
+		return fmt.Errorf("jsontokenizer: negative indent %d", opts.Indent)
+	}
+	f := &formatter{in: newValueReader(r), w: bufio.NewWriter(w), opts: opts}
+	f.unit = strings.Repeat(" ", cmp.Or(opts.Indent, 2))
+	if opts.Tabs {
+		f.unit = "\t"
+	}
+	err := topLevel(f.in, func(first Token) error {
+		if err := f.value(first, 0); err != nil {
+			return err
+		}
+		return f.w.WriteByte('\n')
+	})
+	return errors.Join(err, f.w.Flush())
+}
+
+// Minify writes the JSON values read from r to w without whitespace between
+// tokens, separating top-level values with a newline. The text of strings
+// and numbers is copied unchanged.
+func Minify(r io.Reader, w io.Writer) error {
+	d := NewDecoder(r)
+	bw := bufio.NewWriter(w)
+	values := 0
+	var err error
+	for {
+		var tk Token
+		if tk, err = d.step(); err != nil {
+			break
+		}
+		container := tk.Type == TokenObjectStart || tk.Type == TokenArrayStart
+		scalar := !container && tk.Type != TokenObjectEnd && tk.Type != TokenArrayEnd
+		if scalar && len(d.stack) == 0 || container && len(d.stack) == 1 {
+			// 新的顶层值
+			if values > 0 {
+				bw.WriteByte('\n')
+			}
+			values++
+		}
+		bw.WriteString(tk.Raw)
+	}
+	if errors.Is(err, io.EOF) {
+		err = nil
+	}
+	return errors.Join(err, bw.Flush())
+}
+
+// topLevel 依次对每个顶层值的第一个Token调用 fn
+func topLevel(in *valueReader, fn func(first Token) error) error {
+	for {
+		first, err := in.d.step()
+		if errors.Is(err, io.EOF) {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		if err := fn(first); err != nil {
+			return err
+		}
+	}
+}
+
+// formatter 边读取边输出缩进后的文档
+type formatter struct {
+	in   *valueReader
+	w    *bufio.Writer
+	opts FormatOptions
+	unit string // 一层缩进
+}
+
+// value 输出以 first 开头的值，depth 为其嵌套层级
+func (f *formatter) value(first Token, depth int) error {
+	switch first.Type {
+	case TokenObjectStart:
+		if f.opts.SortKeys {
+			return f.sortedObject(depth)
+		}
+		return f.object(depth)
+	case TokenArrayStart:
+		return f.array(depth)
+	case TokenUnknown, TokenString, TokenStringEscape, TokenNumber, TokenBoolean, TokenNull, TokenObjectEnd,
+		TokenArrayEnd, TokenKey, TokenKeyEscape, TokenComma, TokenColon, TokenQuote, TokenWhitespace, TokenStringChunk:
+	}
+	f.w.WriteString(first.Raw)
+	return nil
+}
+
+// newline 换行并缩进到 depth 层
+func (f *formatter) newline(depth int) {
+	f.w.WriteByte('\n')
+	for range depth {
+		f.w.WriteString(f.unit)
+	}
+}
+
+// object 按原顺序输出对象的成员
+func (f *formatter) object(depth int) error {
+	f.w.WriteByte('{')
+	for n := 0; ; n++ {
+		key, err := f.in.next()
+		if err != nil {
+			return err
+		}
+		if key.Type == TokenObjectEnd {
+			if n > 0 {
+				f.newline(depth)
+			}
+			return f.w.WriteByte('}')
+		}
+		first, err := f.in.next()
+		if err != nil {
+			return err
+		}
+		f.member(n, key.Raw, depth)
+		if err := f.value(first, depth+1); err != nil {
+			return err
+		}
+	}
+}
+
+// member 输出成员的键名，必要时先输出逗号
+func (f *formatter) member(n int, rawKey string, depth int) {
+	if n > 0 {
+		f.w.WriteByte(',')
+	}
+	f.newline(depth + 1)
+	f.w.WriteString(rawKey)
+	f.w.WriteString(": ")
+}
+
+// sortedObject 缓冲对象的全部成员，按键名排序后输出
+func (f *formatter) sortedObject(depth int) error {
+	type member struct {
+		key Token
+		raw []byte
+	}
+	var members []member
+	for {
+		key, err := f.in.next()
+		if err != nil {
+			return err
+		}
+		if key.Type == TokenObjectEnd {
+			break
+		}
+		first, err := f.in.next()
+		if err != nil {
+			return err
+		}
+		raw, err := f.in.rest(first)
+		if err != nil {
+			return err
+		}
+		members = append(members, member{key: key, raw: raw})
+	}
+	slices.SortStableFunc(members, func(a, b member) int { return strings.Compare(a.key.Val, b.key.Val) })
+
+	f.w.WriteByte('{')
+	for i, m := range members {
+		f.member(i, m.key.Raw, depth)
+		sub := &formatter{in: newValueReader(bytes.NewReader(m.raw)), w: f.w, opts: f.opts, unit: f.unit}
+		first, err := sub.in.next()
+		if err != nil {
+			return err
+		}
+		if err := sub.value(first, depth+1); err != nil {
+			return err
+		}
+	}
+	if len(members) > 0 {
+		f.newline(depth)
+	}
+	return f.w.WriteByte('}')
+}
+
+// array 输出数组。CompactArrays 时先缓冲标量元素，遇到容器后改为逐行输出
+func (f *formatter) array(depth int) error {
+	var scalars []Token
+	n := 0
+	f.w.WriteByte('[')
+	for {
+		first, err := f.in.next()
+		if err != nil {
+			return err
+		}
+		if first.Type == TokenArrayEnd {
+			break
+		}
+		if f.opts.CompactArrays && n == 0 && first.Type != TokenObjectStart && first.Type != TokenArrayStart {
+			scalars = append(scalars, first)
+			continue
+		}
+		for _, s := range scalars {
+			f.element(n, depth)
+			f.w.WriteString(s.Raw)
+			n++
+		}
+		scalars = nil
+		f.element(n, depth)
+		if err := f.value(first, depth+1); err != nil {
+			return err
+		}
+		n++
+	}
+
+	for i, s := range scalars {
+		if i > 0 {
+			f.w.WriteString(", ")
+		}
+		f.w.WriteString(s.Raw)
+	}
+	if n > 0 {
+		f.newline(depth)
+	}
+	return f.w.WriteByte(']')
+}
+
+// element 在数组元素之前输出逗号和缩进
+func (f *formatter) element(n, depth int) {
+	if n > 0 {
+		f.w.WriteByte(',')
+	}
+	f.newline(depth + 1)
+}
@@ -0,0 +1,77 @@
+package jsontokenizer
+
+import (
+	"bytes"
+	"io"
+	"strings"
+	"testing"
+	"testing/iotest"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+const formatInput = `{"b":1.0,"a":[1,"xA",[],{}],"c":{"z":[{"k":true}],"y":null},"d":[]}`
+
+func format(t *testing.T, input string, opts FormatOptions) string {
+	t.Helper()
+	var out bytes.Buffer
+	require.NoError(t, Format(iotest.OneByteReader(strings.NewReader(input)), &out, opts))
+	return out.String()
+}
+
+func TestFormat(t *testing.T) {
+	assert.Equal(t, `{
+  "b": 1.0,
+  "a": [
+    1,
+    "xA",
+    [],
+    {}
+  ],
+  "c": {
+    "z": [
+      {
+        "k": true
+      }
+    ],
+    "y": null
+  },
+  "d": []
+}
+`, format(t, formatInput, FormatOptions{}))
+}
+
+func TestFormat_Options(t *testing.T) {
+	assert.Equal(t, "{\n\t\"a\": [\n\t\t1,\n\t\t\"xA\",\n\t\t[],\n\t\t{}\n\t],\n\t\"b\": 1.0,\n\t\"c\": {\n\t\t\"y\": null,\n\t\t\"z\": [\n\t\t\t{\n\t\t\t\t\"k\": true\n\t\t\t}\n\t\t]\n\t},\n\t\"d\": []\n}\n",
+		format(t, formatInput, FormatOptions{Tabs: true, SortKeys: true, CompactArrays: true}))
+
+	assert.Equal(t, "[\n    [1, 2],\n    [\n        3,\n        [4]\n    ]\n]\n",
+		format(t, `[[1,2],[3,[4]]]`, FormatOptions{Indent: 4, CompactArrays: true}))
+	assert.Equal(t, "{\n  \"a\": [\"x\\u0041\", 2.50],\n  \"z\": 1,\n  \"é\": 2\n}\n",
+		format(t, `{"é":2,"z":1,"a":["x\u0041",2.50]}`, FormatOptions{SortKeys: true, CompactArrays: true}))
+	assert.Equal(t, "1\n\"x\"\n{}\n", format(t, " 1 \"x\"\n{ }", FormatOptions{}))
+}
+
+func TestFormat_Error(t *testing.T) {
+	var out bytes.Buffer
+	err := Format(strings.NewReader(`{"a":[1,}`), &out, FormatOptions{})
+	var se *SyntaxError
+	assert.ErrorAs(t, err, &se)
+	assert.Equal(t, "{\n  \"a\": [\n    1", out.String())
+
+	out.Reset()
+	err = Format(strings.NewReader(`[1]`), &out, FormatOptions{Indent: -1})
+	assert.EqualError(t, err, "jsontokenizer: negative indent -1")
+	assert.Empty(t, out.String())
+}
+
+func TestMinify(t *testing.T) {
+	var out bytes.Buffer
+	require.NoError(t, Minify(strings.NewReader("{ \"a b\" : [ 1.50 , -0e1 ,\n\"x y\" ] }\n 2 [ ]\t\"s\""), &out))
+	assert.Equal(t, "{\"a b\":[1.50,-0e1,\"x y\"]}\n2\n[]\n\"s\"", out.String())
+
+	out.Reset()
+	assert.ErrorIs(t, Minify(strings.NewReader(`[1, 2`), &out), io.ErrUnexpectedEOF)
+	assert.Equal(t, "[1,2", out.String())
+}
@@ -0,0 +1,253 @@
+package jsontokenizer
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"slices"
+	"strconv"
+)
+
+// Result is the value found at a path by Get or GetMany.
+type Result struct {
+	// Path is the requested path.
+	Path string
+	// Type is TokenString, TokenNumber, TokenBoolean, TokenNull,
+	// TokenObjectStart for objects or TokenArrayStart for arrays, and
+	// TokenUnknown if nothing is at the path.
+	Type TokenType
+	// Raw is the JSON text of the value without whitespace.
+	Raw json.RawMessage
+
+	str string // 字符串解码后的值
+}
+
+// Exists reports whether a value was found at the path.
+func (r Result) Exists() bool {
+	return r.Type != TokenUnknown
+}
+
+// String returns the decoded value of a string and the JSON text of any other
+// value, or "" if nothing was found.
+func (r Result) String() string {
+	if r.Type == TokenString {
+		return r.str
+	}
+	return string(r.Raw)
+}
+
+// Int returns the value of a number truncated to an integer, or 0 if the
+// value is not a number or out of range.
+func (r Result) Int() int64 {
+	if r.Type != TokenNumber {
+		return 0
+	}
+	if i, err := strconv.ParseInt(string(r.Raw), 10, 64); err == nil {
+		return i
+	}
+	f, err := strconv.ParseFloat(string(r.Raw), 64)
+	if err != nil || f < -(1<<63) || f >= 1<<63 {
+		return 0
+	}
+	return int64(f)
+}
+
+// Float returns the value of a number, or 0 if the value is not a number.
+func (r Result) Float() float64 {
+	if r.Type != TokenNumber {
+		return 0
+	}
+	f, _ := strconv.ParseFloat(string(r.Raw), 64)
+	return f
+}
+
+// Bool reports whether the value is true.
+func (r Result) Bool() bool {
+	return r.Type == TokenBoolean && string(r.Raw) == "true"
+}
+
+// Decode stores the value in v as json.Unmarshal would.
+func (r Result) Decode(v any) error {
+	if !r.Exists() {
+		return fmt.Errorf("jsontokenizer: decode %s: %w", r.Path, ErrPathNotFound)
+	}
+	return json.Unmarshal(r.Raw, v)
+}
+
+// Get returns the value at path in the JSON document read from r, such as
+// $.id or $.data.items[0]["content-type"]. See GetMany.
+func Get(r io.Reader, path string) (Result, error) {
+	results, err := GetMany(r, path)
+	if err != nil {
+		return Result{}, err
+	}
+	return results[0], nil
+}
+
+// GetMany returns the values at the given paths in the JSON document read
+// from r, in the order of the paths. A path that does not exist yields a
+// Result whose Type is TokenUnknown.
+//
+// The document is read only as far as needed: GetMany returns as soon as
+// every path has either been found or can no longer appear, because the
+// object or array that would contain it has ended or, for an index, the
+// array has passed it. Members outside the requested paths are skipped
+// without being buffered, so extracting a few fields from the head of a
+// large body reads little more than that head. The first occurrence of a
+// duplicate key wins, and input after the point where GetMany stops is not
+// validated.
+func GetMany(r io.Reader, paths ...string) ([]Result, error) {
+	g := &getter{results: make([]Result, len(paths)), done: make([]bool, len(paths)), pending: len(paths)}
+	targets := make([]int, len(paths))
+	for i, p := range paths {
+		segs, rest, err := parsePath(p)
+		if err == nil && rest != "" {
+			err = fmt.Errorf("invalid segment %q", rest)
+		}
+		if err != nil {
+			return nil, fmt.Errorf("jsontokenizer: path %q: %w", p, err)
+		}
+		g.paths = append(g.paths, segs)
+		g.results[i].Path = p
+		targets[i] = i
+	}
+	if len(paths) == 0 {
+		return g.results, nil
+	}
+
+	in := newValueReader(r)
+	first, err := in.next()
+	if err == nil {
+		err = g.value(in, first, 0, targets)
+	}
+	if err != nil && !errors.Is(err, errGetDone) {
+		return nil, err
+	}
+	return g.results, nil
+}
+
+// errGetDone 表示所有路径都已确定，可以停止读取
+var errGetDone = errors.New("get done")
+
+// getter 在文档中查找多个路径
+type getter struct {
+	paths   [][]pathSegment
+	results []Result
+	done    []bool // 路径已找到或已不可能出现
+	pending int
+}
+
+// value 处理以 first 开头、位于第 depth 层的值，targets 是经过它的路径
+func (g *getter) value(in *valueReader, first Token, depth int, targets []int) error {
+	var inner []int
+	var raw json.RawMessage
+	for _, i := range targets {
+		if len(g.paths[i]) > depth {
+			inner = append(inner, i)
+			continue
+		}
+		if raw == nil {
+			var err error
+			if raw, err = in.rest(first); err != nil {
+				return err
+			}
+		}
+		g.results[i].Type, g.results[i].Raw = first.Type, raw
+		if first.Type == TokenString {
+			g.results[i].str = first.Val
+		}
+		g.resolve(i)
+	}
+	if g.pending == 0 {
+		return errGetDone
+	}
+	if raw != nil {
+		if len(inner) == 0 {
+			return nil
+		}
+		// 值已被完整读取，在其文本中继续查找更深的路径
+		in = newValueReader(bytes.NewReader(raw))
+		var err error
+		if first, err = in.next(); err != nil {
+			return err
+		}
+	}
+
+	switch first.Type {
+	case TokenObjectStart:
+		for {
+			key, err := in.next()
+			if err != nil {
+				return err
+			}
+			if key.Type == TokenObjectEnd {
+				break
+			}
+			vfirst, err := in.next()
+			if err != nil {
+				return err
+			}
+			if err := g.child(in, vfirst, depth, inner, func(seg pathSegment) bool {
+				return !seg.isIndex && seg.key == key.Val
+			}); err != nil {
+				return err
+			}
+		}
+	case TokenArrayStart:
+		for n := 0; ; n++ {
+			efirst, err := in.next()
+			if err != nil {
+				return err
+			}
+			if efirst.Type == TokenArrayEnd {
+				break
+			}
+			if err := g.child(in, efirst, depth, inner, func(seg pathSegment) bool {
+				return seg.isIndex && seg.index == n
+			}); err != nil {
+				return err
+			}
+			// 下标已经超过的路径不会再出现
+			for _, i := range inner {
+				if seg := g.paths[i][depth]; !g.done[i] && seg.isIndex && seg.index <= n {
+					g.resolve(i)
+				}
+			}
+			if g.pending == 0 {
+				return errGetDone
+			}
+		}
+	case TokenUnknown, TokenString, TokenStringEscape, TokenNumber, TokenBoolean, TokenNull, TokenObjectEnd,
+		TokenArrayEnd, TokenKey, TokenKeyEscape, TokenComma, TokenColon, TokenQuote, TokenWhitespace, TokenStringChunk:
+	}
+
+	// 容器已结束，其中没有找到的路径不会再出现
+	for _, i := range inner {
+		if !g.done[i] {
+			g.resolve(i)
+		}
+	}
+	if g.pending == 0 {
+		return errGetDone
+	}
+	return nil
+}
+
+// child 处理容器中的一个成员或元素，match 判断路径的下一段是否选中它
+func (g *getter) child(in *valueReader, first Token, depth int, targets []int, match func(pathSegment) bool) error {
+	next := slices.DeleteFunc(slices.Clone(targets), func(i int) bool {
+		return g.done[i] || !match(g.paths[i][depth])
+	})
+	if len(next) == 0 {
+		return in.skip(first)
+	}
+	return g.value(in, first, depth+1, next)
+}
+
+// resolve 标记路径已经确定
+func (g *getter) resolve(i int) {
+	g.done[i] = true
+	g.pending--
+}
@@ -0,0 +1,128 @@
+package jsontokenizer
+
+import (
+	"encoding/json"
+	"errors"
+	"io"
+	"strings"
+	"testing"
+	"testing/iotest"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestGet(t *testing.T) {
+	input := `{"id":"evt_1","type":"push","n":-12.5,"ok":true,"none":null,
+		"data":{"items":[{"name":"a"},{"name":"bé","tags":["x", "y"]}],"content-type":"json"}}`
+	tests := []struct {
+		path string
+		typ  TokenType
+		raw  string
+		str  string
+	}{
+		{"$.id", TokenString, `"evt_1"`, "evt_1"},
+		{"$.n", TokenNumber, `-12.5`, "-12.5"},
+		{"$.ok", TokenBoolean, `true`, "true"},
+		{"$.none", TokenNull, `null`, "null"},
+		{"$.data.items[1].name", TokenString, `"bé"`, "bé"},
+		{"$.data.items[1].tags", TokenArrayStart, `["x","y"]`, `["x","y"]`},
+		{`$.data["content-type"]`, TokenString, `"json"`, "json"},
+		{"$", TokenObjectStart, "", ""},
+		{"$.missing", TokenUnknown, "", ""},
+		{"$.id.x", TokenUnknown, "", ""},
+		{"$.data.items[2]", TokenUnknown, "", ""},
+		{"$.data[0]", TokenUnknown, "", ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.path, func(t *testing.T) {
+			r, err := Get(iotest.OneByteReader(strings.NewReader(input)), tt.path)
+			require.NoError(t, err)
+			assert.Equal(t, tt.path, r.Path)
+			assert.Equal(t, tt.typ, r.Type)
+			assert.Equal(t, tt.typ != TokenUnknown, r.Exists())
+			if tt.path == "$" {
+				assert.True(t, json.Valid(r.Raw))
+				return
+			}
+			assert.Equal(t, tt.raw, string(r.Raw))
+			assert.Equal(t, tt.str, r.String())
+		})
+	}
+}
+
+func TestGetMany(t *testing.T) {
+	input := `{"a":{"b":[1,2,{"c":3}]},"a":{"b":0},"d":"e"}`
+	results, err := GetMany(strings.NewReader(input), "$.a.b[2].c", "$.a", "$.d", "$.a.b", "$.a.x", "$.d")
+	require.NoError(t, err)
+	require.Len(t, results, 6)
+	assert.Equal(t, `3`, string(results[0].Raw))
+	assert.Equal(t, `{"b":[1,2,{"c":3}]}`, string(results[1].Raw), "第一个重复的键生效")
+	assert.Equal(t, "e", results[2].String())
+	assert.Equal(t, `[1,2,{"c":3}]`, string(results[3].Raw))
+	assert.False(t, results[4].Exists())
+	assert.Equal(t, "e", results[5].String())
+
+	results, err = GetMany(strings.NewReader(input))
+	require.NoError(t, err)
+	assert.Empty(t, results)
+}
+
+// failAfter 在读完 head 之后返回错误，用于检查提前结束
+func failAfter(head string) io.Reader {
+	return io.MultiReader(strings.NewReader(head), iotest.ErrReader(errors.New("read past head")))
+}
+
+func TestGetStopsEarly(t *testing.T) {
+	// 路径找到后不再读取
+	results, err := GetMany(failAfter(`{"id":42,"type":"push","payload":{"huge":[`), "$.type", "$.id")
+	require.NoError(t, err)
+	assert.Equal(t, "push", results[0].String())
+	assert.EqualValues(t, 42, results[1].Int())
+
+	// 父对象结束后路径不会再出现
+	r, err := Get(failAfter(`{"meta":{"id":1},"body":[`), "$.meta.type")
+	require.NoError(t, err)
+	assert.False(t, r.Exists())
+
+	// 数组越过下标后路径不会再出现
+	r, err = Get(failAfter(`[[0,1],[2,3],[`), "$[1][5]")
+	require.NoError(t, err)
+	assert.False(t, r.Exists())
+
+	// 没有结束时需要继续读取
+	_, err = Get(failAfter(`{"meta":{"id":1},"body":[`), "$.type")
+	assert.ErrorContains(t, err, "read past head")
+}
+
+func TestGetErrors(t *testing.T) {
+	_, err := Get(strings.NewReader(`{}`), "a.b")
+	assert.Error(t, err)
+	_, err = Get(strings.NewReader(`{}`), "$.a[-1]")
+	assert.Error(t, err)
+	_, err = Get(strings.NewReader(`{"a":[1,}`), "$.b")
+	var syntaxErr *SyntaxError
+	assert.ErrorAs(t, err, &syntaxErr)
+	_, err = Get(strings.NewReader(`{"a":`), "$.b")
+	assert.ErrorIs(t, err, io.ErrUnexpectedEOF)
+}
+
+func TestResult(t *testing.T) {
+	results, err := GetMany(strings.NewReader(`{"i":7,"f":2.5e1,"big":1e30,"s":"7","b":false,"o":{"x":[1]}}`),
+		"$.i", "$.f", "$.big", "$.s", "$.b", "$.o", "$.z")
+	require.NoError(t, err)
+	i, f, big, s, b, o, z := results[0], results[1], results[2], results[3], results[4], results[5], results[6]
+	assert.EqualValues(t, 7, i.Int())
+	assert.EqualValues(t, 25, f.Int())
+	assert.EqualValues(t, 25, f.Float())
+	assert.Zero(t, big.Int())
+	assert.Zero(t, s.Int())
+	assert.False(t, b.Bool())
+	assert.Equal(t, "false", b.String())
+	assert.Empty(t, z.String())
+
+	var v struct{ X []int }
+	require.NoError(t, o.Decode(&v))
+	assert.Equal(t, []int{1}, v.X)
+	assert.ErrorIs(t, z.Decode(&v), ErrPathNotFound)
+}
@@ -0,0 +1,219 @@
+// Package highlight renders JSON as colored terminal output or HTML markup,
+// driven by the token types reported by jsontokenizer.
+//
+// A Writer colors its input as it arrives, so a document that is still being
+// generated, such as model output streamed into a terminal, can be shown
+// highlighted without waiting for it to complete:
+//
+//	w := highlight.NewANSI(os.Stdout, highlight.DefaultANSI())
+//	io.Copy(w, resp.Body)
+package highlight
+
+import (
+	"html"
+	"io"
+
+	"github.com/libxyz/gizmo/parsing/jsontokenizer"
+)
+
+// Theme maps token types to styles. For NewANSI a style is a list of SGR
+// parameters such as "1;34"; for NewHTML it is a class attribute value.
+// Types without a style, or with an empty one, are written unstyled.
+//
+// Quotes use the TokenQuote style if the theme has one, and otherwise the
+// style of the key or string they delimit.
+type Theme map[jsontokenizer.TokenType]string
+
+// DefaultANSI returns a theme for terminals: blue keys, green strings, cyan
+// numbers, yellow booleans, gray null and red for unexpected characters.
+func DefaultANSI() Theme {
+	return Theme{
+		jsontokenizer.TokenKey:          "1;34",
+		jsontokenizer.TokenKeyEscape:    "1;34",
+		jsontokenizer.TokenString:       "32",
+		jsontokenizer.TokenStringEscape: "1;32",
+		jsontokenizer.TokenNumber:       "36",
+		jsontokenizer.TokenBoolean:      "33",
+		jsontokenizer.TokenNull:         "90",
+		jsontokenizer.TokenUnknown:      "31",
+	}
+}
+
+// DefaultHTML returns a theme of CSS classes named "json-key",
+// "json-string", "json-escape", "json-number", "json-boolean", "json-null",
+// "json-punct" and "json-error".
+func DefaultHTML() Theme {
+	return Theme{
+		jsontokenizer.TokenKey:          "json-key",
+		jsontokenizer.TokenKeyEscape:    "json-key json-escape",
+		jsontokenizer.TokenString:       "json-string",
+		jsontokenizer.TokenStringEscape: "json-string json-escape",
+		jsontokenizer.TokenNumber:       "json-number",
+		jsontokenizer.TokenBoolean:      "json-boolean",
+		jsontokenizer.TokenNull:         "json-null",
+		jsontokenizer.TokenObjectStart:  "json-punct",
+		jsontokenizer.TokenObjectEnd:    "json-punct",
+		jsontokenizer.TokenArrayStart:   "json-punct",
+		jsontokenizer.TokenArrayEnd:     "json-punct",
+		jsontokenizer.TokenComma:        "json-punct",
+		jsontokenizer.TokenColon:        "json-punct",
+		jsontokenizer.TokenUnknown:      "json-error",
+	}
+}
+
+// Writer highlights the JSON written to it and writes the result to an
+// underlying writer. Each Write is rendered completely before it returns,
+// with every style closed, so output from different writes can be
+// interleaved with other text.
+type Writer struct {
+	w     io.Writer
+	t     *jsontokenizer.Tokenizer
+	theme Theme
+	html  bool
+	buf   []byte
+	style string // 当前已打开的样式
+
+	objects   []bool // 容器栈，true 表示对象
+	expectKey bool   // 下一个引号开始的是键名
+	inString  bool   // 位于键名或字符串的引号之间
+	inKey     bool   // 当前的引号属于键名
+	escape    int    // 转义序列中尚未输出的字符数，-1 表示刚遇到反斜杠
+}
+
+// NewANSI returns a Writer that colors JSON with ANSI escape sequences.
+func NewANSI(w io.Writer, theme Theme) *Writer {
+	return &Writer{w: w, t: jsontokenizer.NewTokenizer(), theme: theme}
+}
+
+// NewHTML returns a Writer that wraps JSON in <span class="..."> elements
+// and escapes it for inclusion in HTML, typically inside a <pre> element.
+func NewHTML(w io.Writer, theme Theme) *Writer {
+	return &Writer{w: w, t: jsontokenizer.NewTokenizer(), theme: theme, html: true}
+}
+
+// Write highlights p and writes it to the underlying writer. A multi-byte
+// character split across writes is rendered by the write that completes it.
+func (h *Writer) Write(p []byte) (int, error) {
+	for _, tk := range h.t.Feed(p) {
+		h.token(tk)
+	}
+	h.setStyle("")
+	_, err := h.w.Write(h.buf)
+	h.buf = h.buf[:0]
+	if err != nil {
+		return 0, err
+	}
+	return len(p), nil
+}
+
+// Reset discards the tokenizer state so that a new document can be written.
+func (h *Writer) Reset() {
+	h.t = jsontokenizer.NewTokenizer()
+	h.objects = h.objects[:0]
+	h.expectKey, h.inString, h.inKey = false, false, false
+	h.escape = 0
+}
+
+// token 输出一个Token并更新用于区分键名引号的语法状态
+func (h *Writer) token(tk jsontokenizer.Token) {
+	switch tk.Type {
+	case jsontokenizer.TokenQuote:
+		// 每个引号都是单独的Token，依次为字符串的开始和结束
+		if !h.inString {
+			h.inString, h.inKey, h.expectKey = true, h.expectKey, false
+		} else {
+			h.inString = false
+		}
+		h.text(h.quoteStyle(), tk.Val)
+		return
+	case jsontokenizer.TokenObjectStart:
+		h.objects = append(h.objects, true)
+		h.expectKey = true
+	case jsontokenizer.TokenArrayStart:
+		h.objects = append(h.objects, false)
+	case jsontokenizer.TokenObjectEnd, jsontokenizer.TokenArrayEnd:
+		if len(h.objects) > 0 {
+			h.objects = h.objects[:len(h.objects)-1]
+		}
+		h.expectKey = false
+	case jsontokenizer.TokenComma:
+		h.expectKey = len(h.objects) > 0 && h.objects[len(h.objects)-1]
+	case jsontokenizer.TokenStringEscape, jsontokenizer.TokenKeyEscape:
+		h.escape = -1
+	case jsontokenizer.TokenString:
+		h.content(tk.Val, h.theme[tk.Type], h.theme[jsontokenizer.TokenStringEscape])
+		return
+	case jsontokenizer.TokenKey:
+		h.content(tk.Val, h.theme[tk.Type], h.theme[jsontokenizer.TokenKeyEscape])
+		return
+	case jsontokenizer.TokenUnknown, jsontokenizer.TokenNumber, jsontokenizer.TokenBoolean, jsontokenizer.TokenNull,
+		jsontokenizer.TokenColon, jsontokenizer.TokenWhitespace, jsontokenizer.TokenStringChunk:
+	}
+	h.text(h.theme[tk.Type], tk.Val)
+}
+
+// content 输出键名或字符串的内容。分词器只将反斜杠标记为转义字符，
+// 这里让其后的转义序列（如 n 或 u0041）也使用转义样式
+func (h *Writer) content(s, style, escStyle string) {
+	if h.escape == -1 {
+		h.escape = 1
+		if s[0] == 'u' {
+			h.escape = 5
+		}
+	}
+	if n := min(h.escape, len(s)); n > 0 {
+		h.text(escStyle, s[:n])
+		h.escape -= n
+		s = s[n:]
+	}
+	if s != "" {
+		h.text(style, s)
+	}
+}
+
+// quoteStyle 返回引号的样式
+func (h *Writer) quoteStyle() string {
+	if s, ok := h.theme[jsontokenizer.TokenQuote]; ok {
+		return s
+	}
+	if h.inKey {
+		return h.theme[jsontokenizer.TokenKey]
+	}
+	return h.theme[jsontokenizer.TokenString]
+}
+
+// text 以指定样式输出文本，相邻的同样式文本共用一组标记
+func (h *Writer) text(style, s string) {
+	h.setStyle(style)
+	if h.html {
+		h.buf = append(h.buf, html.EscapeString(s)...)
+	} else {
+		h.buf = append(h.buf, s...)
+	}
+}
+
+// setStyle 关闭当前样式并打开新的样式
+func (h *Writer) setStyle(style string) {
+	if style == h.style {
+		return
+	}
+	if h.style != "" {
+		if h.html {
+			h.buf = append(h.buf, "</span>"...)
+		} else {
+			h.buf = append(h.buf, "\x1b[0m"...)
+		}
+	}
+	if style != "" {
+		if h.html {
+			h.buf = append(h.buf, `<span class="`...)
+			h.buf = append(h.buf, html.EscapeString(style)...)
+			h.buf = append(h.buf, `">`...)
+		} else {
+			h.buf = append(h.buf, "\x1b["...)
+			h.buf = append(h.buf, style...)
+			h.buf = append(h.buf, 'm')
+		}
+	}
+	h.style = style
+}
@@ -0,0 +1,102 @@
+package highlight
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/libxyz/gizmo/parsing/jsontokenizer"
+)
+
+var testTheme = Theme{
+	jsontokenizer.TokenKey:          "k",
+	jsontokenizer.TokenString:       "s",
+	jsontokenizer.TokenStringEscape: "e",
+	jsontokenizer.TokenNumber:       "n",
+	jsontokenizer.TokenNull:         "z",
+}
+
+const testInput = `{"a": "x\n<", "b": [1, "", null]}`
+
+func writeChunks(t *testing.T, w *Writer, input string, size int) {
+	t.Helper()
+	for b := []byte(input); len(b) > 0; {
+		n := min(size, len(b))
+		_, err := w.Write(b[:n])
+		require.NoError(t, err)
+		b = b[n:]
+	}
+}
+
+func TestANSI(t *testing.T) {
+	var out bytes.Buffer
+	writeChunks(t, NewANSI(&out, testTheme), testInput, len(testInput))
+	assert.Equal(t,
+		"{\x1b[km\"a\"\x1b[0m: \x1b[sm\"x\x1b[0m\x1b[em\\n\x1b[0m\x1b[sm<\"\x1b[0m, \x1b[km\"b\"\x1b[0m: [\x1b[nm1\x1b[0m, \x1b[sm\"\"\x1b[0m, \x1b[zmnull\x1b[0m]}",
+		out.String())
+}
+
+func TestHTML(t *testing.T) {
+	var out bytes.Buffer
+	writeChunks(t, NewHTML(&out, testTheme), testInput, len(testInput))
+	assert.Equal(t,
+		`{<span class="k">&#34;a&#34;</span>: <span class="s">&#34;x</span><span class="e">\n</span><span class="s">&lt;&#34;</span>, `+
+			`<span class="k">&#34;b&#34;</span>: [<span class="n">1</span>, <span class="s">&#34;&#34;</span>, <span class="z">null</span>]}`,
+		out.String())
+}
+
+func TestWriter_Incremental(t *testing.T) {
+	var out bytes.Buffer
+	w := NewANSI(&out, testTheme)
+	_, _ = w.Write([]byte(`{"ab`))
+	// 每次写入结束时样式都已关闭
+	assert.Equal(t, "{\x1b[km\"ab\x1b[0m", out.String())
+
+	out.Reset()
+	_, _ = w.Write([]byte(`c": 12`))
+	assert.Equal(t, "\x1b[kmc\"\x1b[0m: \x1b[nm12\x1b[0m", out.String())
+
+	// 逐字节写入时可见文本与一次写入相同
+	var whole, chunked bytes.Buffer
+	writeChunks(t, NewHTML(&whole, DefaultHTML()), testInput, len(testInput))
+	writeChunks(t, NewHTML(&chunked, DefaultHTML()), testInput, 1)
+	assert.Equal(t, stripTags(whole.String()), stripTags(chunked.String()))
+}
+
+func TestWriter_QuoteStyleAndReset(t *testing.T) {
+	var out bytes.Buffer
+	theme := Theme{jsontokenizer.TokenQuote: "q", jsontokenizer.TokenKey: "k"}
+	w := NewANSI(&out, theme)
+	_, _ = w.Write([]byte(`{"a":1}`))
+	assert.Equal(t, "{\x1b[qm\"\x1b[0m\x1b[kma\x1b[0m\x1b[qm\"\x1b[0m:1}", out.String())
+
+	out.Reset()
+	w.Reset()
+	_, _ = w.Write([]byte(`"s"`))
+	assert.Equal(t, "\x1b[qm\"\x1b[0ms\x1b[qm\"\x1b[0m", out.String())
+}
+
+func stripTags(s string) string {
+	var b bytes.Buffer
+	in := false
+	for _, c := range s {
+		switch {
+		case c == '<':
+			in = true
+		case c == '>':
+			in = false
+		case !in:
+			b.WriteRune(c)
+		}
+	}
+	return b.String()
+}
+
+func TestWriter_UnicodeEscapeAcrossWrites(t *testing.T) {
+	var out bytes.Buffer
+	w := NewHTML(&out, testTheme)
+	writeChunks(t, w, `"a\u00e9b"`, 4)
+	assert.Equal(t, `<span class="s">&#34;a</span><span class="e">\u</span><span class="e">00e9</span><span class="s">b&#34;</span>`, out.String())
+}
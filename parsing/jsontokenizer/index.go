@@ -0,0 +1,594 @@
+package jsontokenizer
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"sort"
+)
+
+const (
+	// DefaultIndexStride is the distance between indexed array elements if
+	// IndexOptions.Stride is zero.
+	DefaultIndexStride = 64
+	// DefaultIndexDepth is the nesting depth indexed if
+	// IndexOptions.MaxDepth is zero.
+	DefaultIndexDepth = 2
+
+	// indexVersion 是索引格式的版本，格式改变时递增
+	indexVersion = 1
+)
+
+// indexMagic 是索引文件的开头
+var indexMagic = []byte("JTIX")
+
+// ErrBadIndex is returned by ReadIndex for data that is not an index or has
+// an unsupported version.
+var ErrBadIndex = errors.New("jsontokenizer: bad index")
+
+// IndexOptions configures BuildIndex.
+type IndexOptions struct {
+	// Stride is the distance between the array elements whose offsets are
+	// recorded, DefaultIndexStride if zero. Elements in between are reached
+	// by scanning forward from the closest recorded one, so a smaller stride
+	// makes lookups faster and the index larger.
+	Stride int
+	// MaxDepth is the deepest level of values whose offsets are recorded,
+	// DefaultIndexDepth if zero; the members of the root object are at depth
+	// 1. Deeper values are found by scanning their closest recorded ancestor.
+	MaxDepth int
+}
+
+// Index is a structural index of a JSON document: the byte offsets of the
+// values near its root, such as every member of the root object and every
+// 64th element of an array inside it. It lets Find start tokenizing at a
+// deeply nested value of a large file without reading what comes before.
+//
+// An index is built once with BuildIndex, saved next to the document with
+// WriteTo and loaded with ReadIndex. It is only valid for the exact document
+// it was built from.
+type Index struct {
+	size     int64 // 文档的字节数
+	stride   int
+	maxDepth int
+	entries  []indexEntry
+
+	// 以下在加载时生成
+	members  map[indexMember]int // 对象成员的条目
+	elements map[int][]int       // 数组中记录的元素条目，按下标排列
+}
+
+// indexEntry 记录一个值的起始偏移量
+type indexEntry struct {
+	parent int    // 所在容器的条目，根为 -1
+	index  int    // 数组元素的下标，对象成员和根为 -1
+	key    []byte // 对象成员的键名原文，不含引号，保留转义
+	offset int64
+}
+
+// indexMember 是对象成员的查找键
+type indexMember struct {
+	parent int
+	name   string // 解码后的键名
+}
+
+// BuildIndex reads the JSON document in r, which must hold a single value,
+// and returns its index.
+func BuildIndex(r io.Reader, opts IndexOptions) (*Index, error) {
+	if opts.Stride <= 0 {
+		opts.Stride = DefaultIndexStride
+	}
+	if opts.MaxDepth <= 0 {
+		opts.MaxDepth = DefaultIndexDepth
+	}
+	b := &indexBuilder{ix: &Index{stride: opts.Stride, maxDepth: opts.MaxDepth}}
+	t := NewTokenizer()
+	t.Coalesce()
+	buf := make([]byte, 64<<10)
+	for {
+		n, err := r.Read(buf)
+		tokens := t.Feed(buf[:n])
+		if errors.Is(err, io.EOF) {
+			tokens = append(tokens, t.Flush()...)
+		}
+		for _, tk := range tokens {
+			if err := b.token(tk); err != nil {
+				return nil, err
+			}
+		}
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		if err != nil {
+			return nil, err
+		}
+	}
+	if !b.done || t.incomplete() {
+		return nil, fmt.Errorf("jsontokenizer: index: %w", io.ErrUnexpectedEOF)
+	}
+	b.ix.size = b.offset
+	b.ix.load()
+	return b.ix, nil
+}
+
+// indexBuilder 在扫描文档时记录条目
+type indexBuilder struct {
+	ix     *Index
+	frames []indexFrame // 未结束的容器
+	offset int64
+	done   bool // 根值已经结束
+}
+
+// indexFrame 是一个未结束的容器
+type indexFrame struct {
+	entry int // 容器的条目，没有记录时为 -1
+	array bool
+	index int    // 当前元素的下标
+	key   []byte // 当前成员的键名原文
+}
+
+// token 处理一个合并模式的Token
+func (b *indexBuilder) token(tk Token) error {
+	start := b.offset
+	b.offset += int64(len(tk.Raw))
+	switch tk.Type {
+	case TokenWhitespace, TokenColon:
+		return nil
+	case TokenComma:
+		if n := len(b.frames); n > 0 && b.frames[n-1].array {
+			b.frames[n-1].index++
+		}
+		return nil
+	case TokenKey:
+		if n := len(b.frames); n > 0 {
+			b.frames[n-1].key = []byte(tk.Raw[1 : len(tk.Raw)-1])
+		}
+		return nil
+	case TokenObjectEnd, TokenArrayEnd:
+		n := len(b.frames)
+		if n == 0 || b.frames[n-1].array != (tk.Type == TokenArrayEnd) {
+			break
+		}
+		b.frames = b.frames[:n-1]
+		b.done = n == 1
+		return nil
+	case TokenString, TokenNumber, TokenBoolean, TokenNull, TokenObjectStart, TokenArrayStart:
+		if b.done {
+			break
+		}
+		entry := b.add(start)
+		if tk.Type == TokenObjectStart || tk.Type == TokenArrayStart {
+			b.frames = append(b.frames, indexFrame{entry: entry, array: tk.Type == TokenArrayStart})
+		} else {
+			b.done = len(b.frames) == 0
+		}
+		return nil
+	case TokenUnknown, TokenStringEscape, TokenKeyEscape, TokenQuote, TokenStringChunk:
+	}
+	return fmt.Errorf("jsontokenizer: index: unexpected %q at offset %d", tk.Raw, start)
+}
+
+// add 在需要时为从 offset 开始的值添加条目，返回条目或 -1
+func (b *indexBuilder) add(offset int64) int {
+	ix := b.ix
+	depth := len(b.frames)
+	if depth == 0 {
+		ix.entries = append(ix.entries, indexEntry{parent: -1, index: -1, offset: offset})
+		return 0
+	}
+	top := b.frames[depth-1]
+	if top.entry < 0 || depth > ix.maxDepth || top.array && top.index%ix.stride != 0 {
+		return -1
+	}
+	e := indexEntry{parent: top.entry, index: -1, offset: offset}
+	if top.array {
+		e.index = top.index
+	} else {
+		e.key = top.key
+	}
+	ix.entries = append(ix.entries, e)
+	return len(ix.entries) - 1
+}
+
+// load 生成查找用的表
+func (ix *Index) load() {
+	ix.members = make(map[indexMember]int)
+	ix.elements = make(map[int][]int)
+	for i, e := range ix.entries {
+		switch {
+		case e.parent < 0:
+		case e.index >= 0:
+			ix.elements[e.parent] = append(ix.elements[e.parent], i)
+		default:
+			var u unescaper
+			name := string(u.finish(u.append(nil, e.key)))
+			if _, ok := ix.members[indexMember{e.parent, name}]; !ok {
+				ix.members[indexMember{e.parent, name}] = i
+			}
+		}
+	}
+}
+
+// Size returns the length in bytes of the indexed document.
+func (ix *Index) Size() int64 {
+	return ix.size
+}
+
+// WriteTo writes the index to w in a compact, versioned binary form.
+func (ix *Index) WriteTo(w io.Writer) (int64, error) {
+	b := append([]byte(nil), indexMagic...)
+	b = binary.AppendUvarint(b, indexVersion)
+	b = binary.AppendUvarint(b, uint64(ix.size))
+	b = binary.AppendUvarint(b, uint64(ix.stride))
+	b = binary.AppendUvarint(b, uint64(ix.maxDepth))
+	b = binary.AppendUvarint(b, uint64(len(ix.entries)))
+	for _, e := range ix.entries {
+		// 父条目和下标加 1 以便用 0 表示没有；偏移量记录为与父条目的差
+		b = binary.AppendUvarint(b, uint64(e.parent+1))
+		b = binary.AppendUvarint(b, uint64(e.index+1))
+		offset := e.offset
+		if e.parent >= 0 {
+			offset -= ix.entries[e.parent].offset
+		}
+		b = binary.AppendUvarint(b, uint64(offset))
+		if e.index < 0 && e.parent >= 0 {
+			b = binary.AppendUvarint(b, uint64(len(e.key)))
+			b = append(b, e.key...)
+		}
+	}
+	n, err := w.Write(b)
+	return int64(n), err
+}
+
+// ReadIndex reads an index written by Index.WriteTo.
+func ReadIndex(r io.Reader) (*Index, error) {
+	data, err := io.ReadAll(r)
+	if err != nil {
+		return nil, err
+	}
+	if !bytes.HasPrefix(data, indexMagic) {
+		return nil, ErrBadIndex
+	}
+	d := &indexDecoder{data: data[len(indexMagic):]}
+	if v := d.uvarint(); v != indexVersion && d.err == nil {
+		return nil, fmt.Errorf("%w: version %d, want %d", ErrBadIndex, v, indexVersion)
+	}
+	ix := &Index{size: int64(d.uvarint()), stride: int(d.uvarint()), maxDepth: int(d.uvarint())}
+	n := d.uvarint()
+	for i := uint64(0); i < n && d.err == nil; i++ {
+		e := indexEntry{parent: int(d.uvarint()) - 1, index: int(d.uvarint()) - 1, offset: int64(d.uvarint())}
+		if e.parent >= len(ix.entries) || e.parent < 0 && i > 0 {
+			d.err = fmt.Errorf("%w: entry %d has parent %d", ErrBadIndex, i, e.parent)
+			break
+		}
+		if e.parent >= 0 {
+			e.offset += ix.entries[e.parent].offset
+			if e.index < 0 {
+				e.key = d.bytes(d.uvarint())
+			}
+		}
+		ix.entries = append(ix.entries, e)
+	}
+	if d.err == nil && (len(d.data) > 0 || n == 0) {
+		d.err = ErrBadIndex
+	}
+	if d.err != nil {
+		return nil, d.err
+	}
+	ix.load()
+	return ix, nil
+}
+
+// indexDecoder 读取索引的二进制形式，出错后返回零值
+type indexDecoder struct {
+	data []byte
+	err  error
+}
+
+func (d *indexDecoder) uvarint() uint64 {
+	if d.err != nil {
+		return 0
+	}
+	v, n := binary.Uvarint(d.data)
+	if n <= 0 {
+		d.err = fmt.Errorf("%w: truncated", ErrBadIndex)
+		return 0
+	}
+	d.data = d.data[n:]
+	return v
+}
+
+func (d *indexDecoder) bytes(n uint64) []byte {
+	if d.err != nil {
+		return nil
+	}
+	if n > uint64(len(d.data)) {
+		d.err = fmt.Errorf("%w: truncated", ErrBadIndex)
+		return nil
+	}
+	b := d.data[:n:n]
+	d.data = d.data[n:]
+	return b
+}
+
+// Find locates the value at path, such as $.records[1000000].name, in the
+// indexed document read from ra. It returns the offset of the value's first
+// byte and a Tokenizer in the state a scan from the start of the document
+// would be in at that offset: fed the document from there on, the Tokenizer
+// reports the same tokens with the same paths as a full scan. Modes such as
+// Coalesce can be set on it before feeding it.
+//
+// Find reads from ra only between the closest recorded ancestor or
+// preceding array element and the value. It returns an error wrapping
+// ErrPathNotFound if there is no value at path.
+func (ix *Index) Find(ra io.ReaderAt, path string) (int64, *Tokenizer, error) {
+	segs, rest, err := parsePath(path)
+	if err == nil && rest != "" {
+		err = fmt.Errorf("invalid segment %q", rest)
+	}
+	if err != nil {
+		return 0, nil, fmt.Errorf("jsontokenizer: path %q: %w", path, err)
+	}
+	notFound := fmt.Errorf("jsontokenizer: find %s: %w", path, ErrPathNotFound)
+
+	// 沿索引下降到最近的已记录的值，skip 是之后需要跳过的元素个数
+	id, i, skip := 0, 0, 0
+	for ; i < len(segs); i++ {
+		seg := segs[i]
+		if !seg.isIndex {
+			child, ok := ix.members[indexMember{id, seg.key}]
+			if !ok {
+				if ix.depth(id) < ix.maxDepth {
+					// 这一层的成员都有记录
+					return 0, nil, notFound
+				}
+				break
+			}
+			id = child
+			continue
+		}
+		elems := ix.elements[id]
+		k := sort.Search(len(elems), func(k int) bool { return ix.entries[elems[k]].index > seg.index }) - 1
+		if k < 0 {
+			break
+		}
+		id = elems[k]
+		if skip = seg.index - ix.entries[id].index; skip > 0 {
+			break
+		}
+	}
+
+	s := &structScanner{offset: ix.entries[id].offset}
+	s.r = bufio.NewReaderSize(io.NewSectionReader(ra, s.offset, ix.size-s.offset), 32<<10)
+	stack := ix.stack(id)
+	if skip > 0 {
+		// 从之前记录的元素跳到目标元素
+		if err := s.skipElements(stack, skip); err != nil {
+			return 0, nil, s.notFound(err, notFound)
+		}
+		i++
+	}
+	for ; i < len(segs); i++ {
+		if stack, err = s.enter(stack, segs[i]); err != nil {
+			return 0, nil, s.notFound(err, notFound)
+		}
+	}
+	if _, err := s.peek(); err != nil {
+		return 0, nil, s.notFound(err, notFound)
+	}
+
+	t := NewTokenizer()
+	t.inner.stack = stack
+	t.inner.pathCacheDirty = true
+	return s.offset, t, nil
+}
+
+// depth 返回条目的嵌套深度
+func (ix *Index) depth(id int) int {
+	d := 0
+	for ; ix.entries[id].parent >= 0; id = ix.entries[id].parent {
+		d++
+	}
+	return d
+}
+
+// stack 返回扫描到条目开头时的容器栈
+func (ix *Index) stack(id int) []container {
+	var stack []container
+	for e := ix.entries[id]; e.parent >= 0; e = ix.entries[e.parent] {
+		if e.index >= 0 {
+			stack = append(stack, container{Type: containerTypeArray, ArrayIndex: e.index})
+		} else {
+			stack = append(stack, container{Type: containerTypeObject, Key: e.key})
+		}
+	}
+	// 从内到外收集，反转为从外到内
+	for l, r := 0, len(stack)-1; l < r; l, r = l+1, r-1 {
+		stack[l], stack[r] = stack[r], stack[l]
+	}
+	return stack
+}
+
+// errNoValue 表示扫描时发现路径上没有值
+var errNoValue = errors.New("no value")
+
+// structScanner 按结构扫描JSON文本，只识别字符串和括号，不做完整校验
+type structScanner struct {
+	r      *bufio.Reader
+	offset int64
+}
+
+// notFound 将 errNoValue 和输入提前结束转换为 notFound
+func (s *structScanner) notFound(err, notFound error) error {
+	if errors.Is(err, errNoValue) || errors.Is(err, io.EOF) {
+		return notFound
+	}
+	return err
+}
+
+// peek 跳过空白并返回下一个字节
+func (s *structScanner) peek() (byte, error) {
+	for {
+		c, err := s.r.ReadByte()
+		if err != nil {
+			return 0, err
+		}
+		if c != ' ' && c != '\t' && c != '\n' && c != '\r' {
+			return c, s.r.UnreadByte()
+		}
+		s.offset++
+	}
+}
+
+// next 跳过空白并读取下一个字节
+func (s *structScanner) next() (byte, error) {
+	c, err := s.peek()
+	if err == nil {
+		s.r.ReadByte()
+		s.offset++
+	}
+	return c, err
+}
+
+// str 读取引号之后的字符串内容，返回不含引号的原文
+func (s *structScanner) str() ([]byte, error) {
+	var raw []byte
+	for escaped := false; ; {
+		c, err := s.r.ReadByte()
+		if err != nil {
+			return nil, err
+		}
+		s.offset++
+		switch {
+		case escaped:
+			escaped = false
+		case c == '\\':
+			escaped = true
+		case c == '"':
+			return raw, nil
+		}
+		raw = append(raw, c)
+	}
+}
+
+// skipValue 跳过一个值
+func (s *structScanner) skipValue() error {
+	for depth := 0; ; {
+		c, err := s.next()
+		if err != nil {
+			return err
+		}
+		switch c {
+		case '"':
+			if _, err := s.str(); err != nil {
+				return err
+			}
+		case '{', '[':
+			depth++
+		case '}', ']':
+			depth--
+		default:
+			if depth == 0 {
+				return s.scalar()
+			}
+		}
+		if depth == 0 {
+			return nil
+		}
+	}
+}
+
+// scalar 跳过数字或关键字的剩余部分
+func (s *structScanner) scalar() error {
+	for {
+		c, err := s.r.ReadByte()
+		if errors.Is(err, io.EOF) {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		switch c {
+		case ',', ':', '}', ']', ' ', '\t', '\n', '\r':
+			return s.r.UnreadByte()
+		}
+		s.offset++
+	}
+}
+
+// skipElements 跳过数组中的 n 个元素，更新栈顶的下标
+func (s *structScanner) skipElements(stack []container, n int) error {
+	top := &stack[len(stack)-1]
+	for ; n > 0; n-- {
+		if err := s.skipValue(); err != nil {
+			return err
+		}
+		c, err := s.next()
+		if err != nil {
+			return err
+		}
+		if c != ',' {
+			return errNoValue
+		}
+		top.ArrayIndex++
+	}
+	return nil
+}
+
+// enter 从当前值的开头进入 seg 选中的子值的开头，返回新的容器栈
+func (s *structScanner) enter(stack []container, seg pathSegment) ([]container, error) {
+	c, err := s.next()
+	if err != nil {
+		return nil, err
+	}
+	if seg.isIndex {
+		if c != '[' {
+			return nil, errNoValue
+		}
+		if c, err := s.peek(); err != nil || c == ']' {
+			return nil, orErr(err, errNoValue)
+		}
+		stack = append(stack, container{Type: containerTypeArray})
+		return stack, s.skipElements(stack, seg.index)
+	}
+	if c != '{' {
+		return nil, errNoValue
+	}
+	for {
+		c, err := s.next()
+		if err != nil {
+			return nil, err
+		}
+		if c != '"' {
+			return nil, errNoValue
+		}
+		raw, err := s.str()
+		if err != nil {
+			return nil, err
+		}
+		if c, err := s.next(); err != nil || c != ':' {
+			return nil, orErr(err, errNoValue)
+		}
+		var u unescaper
+		if string(u.finish(u.append(nil, raw))) == seg.key {
+			return append(stack, container{Type: containerTypeObject, Key: raw}), nil
+		}
+		if err := s.skipValue(); err != nil {
+			return nil, err
+		}
+		if c, err := s.next(); err != nil || c != ',' {
+			return nil, orErr(err, errNoValue)
+		}
+	}
+}
+
+// orErr 返回 err，err 为 nil 时返回 fallback
+func orErr(err, fallback error) error {
+	if err != nil {
+		return err
+	}
+	return fallback
+}
@@ -0,0 +1,154 @@
+package jsontokenizer
+
+import (
+	"bytes"
+	"encoding/binary"
+	"fmt"
+	"strings"
+	"sync/atomic"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// indexedDoc 生成用于索引测试的文档
+func indexedDoc(records int) []byte {
+	var b strings.Builder
+	b.WriteString("{\n  \"meta\": {\"count\": 3, \"a\\\"b\": [true, null]},\n  \"records\": [")
+	for i := range records {
+		if i > 0 {
+			b.WriteString(", ")
+		}
+		fmt.Fprintf(&b, `{"id": %d, "name": "r\"%d", "tags": ["x", {"deep": [%d, "}]"]}], "kéy": {}}`, i, i, i)
+	}
+	b.WriteString("],\n  \"tail\": 1.5\n}\n")
+	return []byte(b.String())
+}
+
+// countingReaderAt 统计读取的字节数
+type countingReaderAt struct {
+	r *bytes.Reader
+	n atomic.Int64
+}
+
+func (c *countingReaderAt) ReadAt(p []byte, off int64) (int, error) {
+	n, err := c.r.ReadAt(p, off)
+	c.n.Add(int64(n))
+	return n, err
+}
+
+func TestIndex_Find(t *testing.T) {
+	data := indexedDoc(300)
+	ix, err := BuildIndex(bytes.NewReader(data), IndexOptions{Stride: 7, MaxDepth: 2})
+	require.NoError(t, err)
+	assert.EqualValues(t, len(data), ix.Size())
+
+	// 完整扫描的Token及其起始偏移量
+	full := NewTokenizer()
+	full.Coalesce()
+	tokens := append(full.Feed(data), full.Flush()...)
+	starts := make(map[int64]int)
+	var offset int64
+	for i, tk := range tokens {
+		if _, ok := starts[offset]; !ok {
+			starts[offset] = i
+		}
+		offset += int64(len(tk.Raw))
+	}
+
+	for _, path := range []string{
+		"$", "$.meta", "$.meta.count", `$.meta["a\"b"][1]`, "$.records", "$.records[0]", "$.records[6].name",
+		"$.records[7]", "$.records[8].tags[1].deep[1]", "$.records[299]", `$.records[150]["kéy"]`, "$.tail",
+	} {
+		t.Run(path, func(t *testing.T) {
+			off, tz, err := ix.Find(bytes.NewReader(data), path)
+			require.NoError(t, err)
+			tz.Coalesce()
+			resumed := append(tz.Feed(data[off:]), tz.Flush()...)
+			i, ok := starts[off]
+			require.True(t, ok, "offset %d is not a token boundary", off)
+			assert.Equal(t, tokens[i:], resumed)
+		})
+	}
+
+	// 逐字符模式下的路径也与完整扫描相同
+	off, tz, err := ix.Find(bytes.NewReader(data), "$.records[200].tags[1]")
+	require.NoError(t, err)
+	assert.Equal(t, byte('{'), data[off])
+	assert.Equal(t, "$.records[200].tags[1]", tz.Push('{').Path)
+	for _, c := range ` "deep"` {
+		tz.Push(c)
+	}
+	assert.Equal(t, "$.records[200].tags[1].deep", tz.Push(':').Path)
+}
+
+func TestIndex_FindReadsLittle(t *testing.T) {
+	data := indexedDoc(5000)
+	ix, err := BuildIndex(bytes.NewReader(data), IndexOptions{})
+	require.NoError(t, err)
+	ra := &countingReaderAt{r: bytes.NewReader(data)}
+	off, _, err := ix.Find(ra, "$.records[4321].tags[1].deep[1]")
+	require.NoError(t, err)
+	assert.Equal(t, `"}]"`, string(data[off:off+4]))
+	// 只需读取最近记录的元素之后的一个缓冲区
+	assert.LessOrEqual(t, ra.n.Load(), int64(32<<10))
+	assert.Greater(t, int64(len(data)), 10*ra.n.Load())
+}
+
+func TestIndex_NotFound(t *testing.T) {
+	data := indexedDoc(20)
+	ix, err := BuildIndex(bytes.NewReader(data), IndexOptions{Stride: 4, MaxDepth: 1})
+	require.NoError(t, err)
+	for _, path := range []string{
+		"$.missing", "$.records[20]", "$.records[3].nope", "$.meta.count[0]", "$.meta.count.x",
+		"$.records[2].tags[2]", `$.records[1]["kéy"].x`, "$[0]",
+	} {
+		_, _, err := ix.Find(bytes.NewReader(data), path)
+		assert.ErrorIs(t, err, ErrPathNotFound, path)
+	}
+	_, _, err = ix.Find(bytes.NewReader(data), "records")
+	assert.Error(t, err)
+}
+
+func TestIndex_RoundTrip(t *testing.T) {
+	data := indexedDoc(100)
+	ix, err := BuildIndex(bytes.NewReader(data), IndexOptions{Stride: 10})
+	require.NoError(t, err)
+	var buf bytes.Buffer
+	n, err := ix.WriteTo(&buf)
+	require.NoError(t, err)
+	assert.EqualValues(t, buf.Len(), n)
+
+	loaded, err := ReadIndex(bytes.NewReader(buf.Bytes()))
+	require.NoError(t, err)
+	assert.Equal(t, ix.entries, loaded.entries)
+	assert.Equal(t, ix.Size(), loaded.Size())
+	off1, _, err := ix.Find(bytes.NewReader(data), "$.records[55].tags")
+	require.NoError(t, err)
+	off2, _, err := loaded.Find(bytes.NewReader(data), "$.records[55].tags")
+	require.NoError(t, err)
+	assert.Equal(t, off1, off2)
+
+	// 错误的格式和版本
+	_, err = ReadIndex(strings.NewReader("JSON"))
+	assert.ErrorIs(t, err, ErrBadIndex)
+	_, err = ReadIndex(bytes.NewReader(buf.Bytes()[:buf.Len()-1]))
+	assert.ErrorIs(t, err, ErrBadIndex)
+	future := binary.AppendUvarint([]byte("JTIX"), indexVersion+1)
+	_, err = ReadIndex(bytes.NewReader(append(future, buf.Bytes()[5:]...)))
+	assert.ErrorIs(t, err, ErrBadIndex)
+	assert.ErrorContains(t, err, "version 2")
+}
+
+func TestBuildIndex_Errors(t *testing.T) {
+	for _, input := range []string{`{"a": [1}`, `{"a": 1} 2`, `[x]`, `{"a": [1]`, `"abc`, ``} {
+		_, err := BuildIndex(strings.NewReader(input), IndexOptions{})
+		assert.Error(t, err, input)
+	}
+	ix, err := BuildIndex(strings.NewReader(" 42 "), IndexOptions{})
+	require.NoError(t, err)
+	off, _, err := ix.Find(strings.NewReader(" 42 "), "$")
+	require.NoError(t, err)
+	assert.EqualValues(t, 1, off)
+}
@@ -0,0 +1,19 @@
+package jsonstr
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestAppendQuote(t *testing.T) {
+	for _, s := range []string{"", "plain", "q\"b\\", "\x00\x1f\n\t", "é🚀", "<&>"} {
+		var got string
+		require.NoError(t, json.Unmarshal(AppendQuote(nil, s), &got))
+		assert.Equal(t, s, got)
+	}
+	assert.Equal(t, `"\u001f<>"`, string(AppendQuote(nil, "\x1f<>")))
+	assert.Equal(t, `"a�"`, string(AppendQuote(nil, "a\xff")))
+}
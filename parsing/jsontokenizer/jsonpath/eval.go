@@ -0,0 +1,623 @@
+package jsonpath
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"regexp"
+	"strconv"
+	"unicode/utf8"
+
+	"github.com/libxyz/gizmo/parsing/jsontokenizer"
+)
+
+// node 是缓冲在内存中的JSON值
+type node struct {
+	raw     json.RawMessage // 紧凑形式的原文
+	scalar  any             // 标量的值：nil、bool、json.Number 或 string
+	object  bool
+	array   bool
+	keys    []string // 对象成员的键名，按原顺序
+	members map[string]*node
+	elems   []*node
+}
+
+// parseTree 将紧凑形式的JSON解析为树，每个节点引用 raw 中对应的原文
+func parseTree(raw json.RawMessage) (*node, error) {
+	dec := jsontokenizer.NewDecoder(bytes.NewReader(raw))
+	dec.UseNumber()
+	return build(dec, raw)
+}
+
+// build 读取下一个值。raw 不含空白，值的起点是上一个Token之后，跳过分隔符
+func build(dec *jsontokenizer.Decoder, raw json.RawMessage) (*node, error) {
+	start := dec.InputOffset()
+	if start < int64(len(raw)) && (raw[start] == ',' || raw[start] == ':') {
+		start++
+	}
+	tk, err := dec.Token()
+	if err != nil {
+		return nil, err
+	}
+	n := &node{}
+	switch tk {
+	case json.Delim('{'):
+		n.object = true
+		n.members = make(map[string]*node)
+		for dec.More() {
+			key, err := dec.Token()
+			if err != nil {
+				return nil, err
+			}
+			child, err := build(dec, raw)
+			if err != nil {
+				return nil, err
+			}
+			k := key.(string)
+			if _, ok := n.members[k]; !ok {
+				n.keys = append(n.keys, k)
+			}
+			n.members[k] = child
+		}
+		_, err = dec.Token()
+	case json.Delim('['):
+		n.array = true
+		for dec.More() {
+			child, err := build(dec, raw)
+			if err != nil {
+				return nil, err
+			}
+			n.elems = append(n.elems, child)
+		}
+		_, err = dec.Token()
+	default:
+		n.scalar = tk
+	}
+	n.raw = raw[start:dec.InputOffset()]
+	return n, err
+}
+
+// located 是节点及其规范化路径
+type located struct {
+	n    *node
+	path string
+}
+
+// segment 是查询中的一个段
+type segment struct {
+	descendant bool
+	selectors  []selector
+}
+
+// selectorKind 是选择器的种类
+type selectorKind int
+
+const (
+	selectName selectorKind = iota
+	selectWildcard
+	selectIndex
+	selectSlice
+	selectFilter
+)
+
+// selector 是段中的一个选择器
+type selector struct {
+	kind                      selectorKind
+	name                      string
+	index                     int
+	start, end, step          int
+	hasStart, hasEnd, hasStep bool
+	filter                    logical
+}
+
+// apply 将段应用于一个节点，把选中的子节点追加到 out
+func (seg segment) apply(out []located, in located, root *node) []located {
+	for _, sel := range seg.selectors {
+		out = sel.apply(out, in, root)
+	}
+	return out
+}
+
+// apply 将选择器应用于一个节点
+func (sel selector) apply(out []located, in located, root *node) []located {
+	n := in.n
+	switch sel.kind {
+	case selectName:
+		if c, ok := n.members[sel.name]; ok {
+			out = append(out, located{c, in.path + nameSegment(sel.name)})
+		}
+	case selectWildcard:
+		for _, k := range n.keys {
+			out = append(out, located{n.members[k], in.path + nameSegment(k)})
+		}
+		for i, c := range n.elems {
+			out = append(out, located{c, in.path + indexSegment(i)})
+		}
+	case selectIndex:
+		i := sel.index
+		if i < 0 {
+			i += len(n.elems)
+		}
+		if 0 <= i && i < len(n.elems) {
+			out = append(out, located{n.elems[i], in.path + indexSegment(i)})
+		}
+	case selectSlice:
+		if !n.array {
+			break
+		}
+		lower, upper, step := sel.bounds(len(n.elems))
+		for i := lower; step > 0 && i < upper || step < 0 && upper < i; i += step {
+			out = append(out, located{n.elems[i], in.path + indexSegment(i)})
+		}
+	case selectFilter:
+		ctx := &context{root: root}
+		for _, k := range n.keys {
+			if ctx.current = n.members[k]; sel.filter.test(ctx) {
+				out = append(out, located{ctx.current, in.path + nameSegment(k)})
+			}
+		}
+		for i, c := range n.elems {
+			if ctx.current = c; sel.filter.test(ctx) {
+				out = append(out, located{c, in.path + indexSegment(i)})
+			}
+		}
+	}
+	return out
+}
+
+// bounds 按 RFC 9535 计算切片的遍历范围：步长为正时为 [lower, upper)，为负时为 (upper, lower]，
+// 返回的第一个值是遍历的起点
+func (sel selector) bounds(n int) (from, to, step int) {
+	step = 1
+	if sel.hasStep {
+		step = sel.step
+	}
+	if step == 0 {
+		return 0, 0, 0
+	}
+	norm := func(i int) int {
+		if i < 0 {
+			return n + i
+		}
+		return i
+	}
+	start, end := 0, n
+	if step < 0 {
+		start, end = n-1, -n-1
+	}
+	if sel.hasStart {
+		start = sel.start
+	}
+	if sel.hasEnd {
+		end = sel.end
+	}
+	if step > 0 {
+		return min(max(norm(start), 0), n), min(max(norm(end), 0), n), step
+	}
+	return min(max(norm(start), -1), n-1), min(max(norm(end), -1), n-1), step
+}
+
+// streamable 判断选择器能否在不知道数组长度的情况下在Token流中判定
+func (sel selector) streamable() bool {
+	switch sel.kind {
+	case selectName, selectWildcard:
+		return true
+	case selectIndex:
+		return sel.index >= 0
+	case selectSlice:
+		return sel.start >= 0 && sel.end >= 0 && (!sel.hasStep || sel.step > 0)
+	case selectFilter:
+		return !sel.filter.absolute()
+	}
+	return false
+}
+
+// matches 判断可流式处理的选择器是否选中对象成员 key 或数组下标 index（对象成员时为 -1）。
+// 过滤选择器需要子节点 child
+func (sel selector) matches(key string, index int, child *node) bool {
+	switch sel.kind {
+	case selectName:
+		return index < 0 && key == sel.name
+	case selectWildcard:
+		return true
+	case selectIndex:
+		return index == sel.index
+	case selectSlice:
+		step := 1
+		if sel.hasStep {
+			step = sel.step
+		}
+		return index >= sel.start && (!sel.hasEnd || index < sel.end) && (index-sel.start)%step == 0
+	case selectFilter:
+		return sel.filter.test(&context{current: child})
+	}
+	return false
+}
+
+// descend 按先序对节点及其全部后代调用 fn
+func descend(in located, fn func(located)) {
+	fn(in)
+	for _, k := range in.n.keys {
+		descend(located{in.n.members[k], in.path + nameSegment(k)}, fn)
+	}
+	for i, c := range in.n.elems {
+		descend(located{c, in.path + indexSegment(i)}, fn)
+	}
+}
+
+// evaluate 按 RFC 9535 的语义对节点依次应用各段
+func evaluate(nodes []located, segs []segment, root *node) []located {
+	for _, seg := range segs {
+		var out []located
+		for _, in := range nodes {
+			if !seg.descendant {
+				out = seg.apply(out, in, root)
+				continue
+			}
+			descend(in, func(d located) { out = seg.apply(out, d, root) })
+		}
+		nodes = out
+	}
+	return nodes
+}
+
+// nameSegment 返回对象成员的规范化路径段，如 ['name']
+func nameSegment(name string) string {
+	const hex = "0123456789abcdef"
+	b := []byte("['")
+	for i := 0; i < len(name); i++ {
+		switch c := name[i]; c {
+		case '\'', '\\':
+			b = append(b, '\\', c)
+		case '\b':
+			b = append(b, `\b`...)
+		case '\f':
+			b = append(b, `\f`...)
+		case '\n':
+			b = append(b, `\n`...)
+		case '\r':
+			b = append(b, `\r`...)
+		case '\t':
+			b = append(b, `\t`...)
+		default:
+			if c < 0x20 {
+				b = append(b, '\\', 'u', '0', '0', hex[c>>4], hex[c&0xf])
+			} else {
+				b = append(b, c)
+			}
+		}
+	}
+	return string(append(b, "']"...))
+}
+
+// indexSegment 返回数组元素的规范化路径段，如 [0]
+func indexSegment(i int) string {
+	return "[" + strconv.Itoa(i) + "]"
+}
+
+// context 是过滤表达式求值时的根节点和当前节点
+type context struct {
+	root, current *node
+}
+
+// logical 是过滤表达式中结果为逻辑值的表达式
+type logical interface {
+	test(ctx *context) bool
+	// absolute 判断表达式是否引用了根节点 $
+	absolute() bool
+}
+
+type orExpr struct{ l, r logical }
+
+func (e orExpr) test(ctx *context) bool { return e.l.test(ctx) || e.r.test(ctx) }
+func (e orExpr) absolute() bool         { return e.l.absolute() || e.r.absolute() }
+
+type andExpr struct{ l, r logical }
+
+func (e andExpr) test(ctx *context) bool { return e.l.test(ctx) && e.r.test(ctx) }
+func (e andExpr) absolute() bool         { return e.l.absolute() || e.r.absolute() }
+
+type notExpr struct{ x logical }
+
+func (e notExpr) test(ctx *context) bool { return !e.x.test(ctx) }
+func (e notExpr) absolute() bool         { return e.x.absolute() }
+
+// existsExpr 是以过滤查询作为测试表达式，结果非空时为真
+type existsExpr struct{ q *filterQuery }
+
+func (e existsExpr) test(ctx *context) bool { return len(e.q.nodes(ctx)) > 0 }
+func (e existsExpr) absolute() bool         { return e.q.absolute }
+
+// funcTest 是以逻辑类型函数作为测试表达式
+type funcTest struct{ f *funcCall }
+
+func (e funcTest) test(ctx *context) bool { return e.f.test(ctx) }
+func (e funcTest) absolute() bool         { return e.f.absolute() }
+
+// compareExpr 是比较表达式
+type compareExpr struct {
+	op   string
+	l, r comparable
+}
+
+func (e compareExpr) test(ctx *context) bool {
+	a, b := e.l.value(ctx), e.r.value(ctx)
+	switch e.op {
+	case "==":
+		return equal(a, b)
+	case "!=":
+		return !equal(a, b)
+	case "<":
+		return less(a, b)
+	case "<=":
+		return less(a, b) || equal(a, b)
+	case ">":
+		return less(b, a)
+	}
+	return less(b, a) || equal(a, b)
+}
+
+func (e compareExpr) absolute() bool { return e.l.absolute() || e.r.absolute() }
+
+// comparable 是结果为值的表达式，结果为 nil 表示 Nothing
+type comparable interface {
+	value(ctx *context) *node
+	absolute() bool
+}
+
+// literal 是字面量
+type literal struct{ n *node }
+
+func (l literal) value(*context) *node { return l.n }
+func (l literal) absolute() bool       { return false }
+
+// filterQuery 是过滤表达式中以 @ 或 $ 开头的查询
+type filterQuery struct {
+	absolute bool
+	segments []segment
+}
+
+// nodes 返回查询选中的节点
+func (q *filterQuery) nodes(ctx *context) []located {
+	start := ctx.current
+	if q.absolute {
+		start = ctx.root
+	}
+	if start == nil {
+		return nil
+	}
+	return evaluate([]located{{n: start}}, q.segments, ctx.root)
+}
+
+// singular 判断查询是否只由单个名称或下标组成的子段构成
+func (q *filterQuery) singular() bool {
+	for _, seg := range q.segments {
+		if seg.descendant || len(seg.selectors) != 1 ||
+			seg.selectors[0].kind != selectName && seg.selectors[0].kind != selectIndex {
+			return false
+		}
+	}
+	return true
+}
+
+// singularQuery 是作为比较操作数的单值查询
+type singularQuery struct{ q *filterQuery }
+
+func (s singularQuery) value(ctx *context) *node {
+	if nodes := s.q.nodes(ctx); len(nodes) == 1 {
+		return nodes[0].n
+	}
+	return nil
+}
+
+func (s singularQuery) absolute() bool { return s.q.absolute }
+
+// funcType 是函数参数和结果的类型
+type funcType int
+
+const (
+	valueType funcType = iota
+	logicalType
+	nodesType
+)
+
+// functions 是内置函数的参数类型
+var functions = map[string][]funcType{
+	"length": {valueType},
+	"count":  {nodesType},
+	"match":  {valueType, valueType},
+	"search": {valueType, valueType},
+	"value":  {nodesType},
+}
+
+// funcCall 是函数调用
+type funcCall struct {
+	name string
+	args []any // comparable 或 *filterQuery
+	re   *regexp.Regexp
+}
+
+// result 返回函数的结果类型
+func (f *funcCall) result() funcType {
+	if f.name == "match" || f.name == "search" {
+		return logicalType
+	}
+	return valueType
+}
+
+func (f *funcCall) absolute() bool {
+	for _, a := range f.args {
+		switch a := a.(type) {
+		case comparable:
+			if a.absolute() {
+				return true
+			}
+		case *filterQuery:
+			if a.absolute {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// value 求出值类型函数的结果
+func (f *funcCall) value(ctx *context) *node {
+	switch f.name {
+	case "length":
+		v := f.args[0].(comparable).value(ctx)
+		switch {
+		case v == nil:
+			return nil
+		case v.object:
+			return numberNode(len(v.keys))
+		case v.array:
+			return numberNode(len(v.elems))
+		}
+		if s, ok := v.scalar.(string); ok {
+			return numberNode(utf8.RuneCountInString(s))
+		}
+		return nil
+	case "count":
+		return numberNode(len(f.args[0].(*filterQuery).nodes(ctx)))
+	case "value":
+		if nodes := f.args[0].(*filterQuery).nodes(ctx); len(nodes) == 1 {
+			return nodes[0].n
+		}
+	}
+	return nil
+}
+
+// test 求出逻辑类型函数的结果
+func (f *funcCall) test(ctx *context) bool {
+	v, p := f.args[0].(comparable).value(ctx), f.args[1].(comparable).value(ctx)
+	if v == nil || p == nil {
+		return false
+	}
+	s, ok1 := v.scalar.(string)
+	pattern, ok2 := p.scalar.(string)
+	if !ok1 || !ok2 {
+		return false
+	}
+	re := f.re
+	if _, lit := f.args[1].(literal); !lit {
+		re = compileIRegexp(pattern, f.name == "match")
+	}
+	return re != nil && re.MatchString(s)
+}
+
+func numberNode(n int) *node {
+	s := strconv.Itoa(n)
+	return &node{raw: json.RawMessage(s), scalar: json.Number(s)}
+}
+
+// comparableOf 检查比较操作数的类型
+func comparableOf(x any) (comparable, error) {
+	switch x := x.(type) {
+	case literal:
+		return x, nil
+	case *filterQuery:
+		if !x.singular() {
+			return nil, fmt.Errorf("non-singular query in comparison")
+		}
+		return singularQuery{x}, nil
+	case *funcCall:
+		if x.result() == valueType {
+			return x, nil
+		}
+	}
+	return nil, fmt.Errorf("operand of comparison is not a value")
+}
+
+// testOf 检查测试表达式的类型
+func testOf(x any) (logical, error) {
+	switch x := x.(type) {
+	case *filterQuery:
+		return existsExpr{x}, nil
+	case *funcCall:
+		if x.result() == logicalType {
+			return funcTest{x}, nil
+		}
+		return nil, fmt.Errorf("result of %s must be compared", x.name)
+	}
+	return nil, fmt.Errorf("literal must be compared")
+}
+
+// convertArg 检查函数参数是否符合参数类型
+func convertArg(arg any, t funcType) (any, error) {
+	switch t {
+	case valueType:
+		return comparableOf(arg)
+	case nodesType:
+		if q, ok := arg.(*filterQuery); ok {
+			return q, nil
+		}
+		return nil, fmt.Errorf("argument must be a query")
+	}
+	return nil, fmt.Errorf("unsupported argument type")
+}
+
+// equal 判断两个值是否相等，两者都是 Nothing 时也相等
+func equal(a, b *node) bool {
+	if a == nil || b == nil {
+		return a == nil && b == nil
+	}
+	switch {
+	case a.object:
+		if !b.object || len(a.keys) != len(b.keys) {
+			return false
+		}
+		for _, k := range a.keys {
+			if c, ok := b.members[k]; !ok || !equal(a.members[k], c) {
+				return false
+			}
+		}
+		return true
+	case a.array:
+		if !b.array || len(a.elems) != len(b.elems) {
+			return false
+		}
+		for i := range a.elems {
+			if !equal(a.elems[i], b.elems[i]) {
+				return false
+			}
+		}
+		return true
+	case b.object || b.array:
+		return false
+	}
+	if x, ok := a.scalar.(json.Number); ok {
+		y, ok := b.scalar.(json.Number)
+		return ok && numberCompare(x, y) == 0
+	}
+	return a.scalar == b.scalar
+}
+
+// less 判断 a < b，只有两个数字或两个字符串可以比较大小
+func less(a, b *node) bool {
+	if a == nil || b == nil {
+		return false
+	}
+	switch x := a.scalar.(type) {
+	case json.Number:
+		y, ok := b.scalar.(json.Number)
+		return ok && numberCompare(x, y) < 0
+	case string:
+		y, ok := b.scalar.(string)
+		return ok && x < y
+	}
+	return false
+}
+
+// numberCompare 按数值比较两个数字
+func numberCompare(x, y json.Number) int {
+	a, _ := strconv.ParseFloat(string(x), 64)
+	b, _ := strconv.ParseFloat(string(y), 64)
+	switch {
+	case a < b:
+		return -1
+	case a > b:
+		return 1
+	}
+	return 0
+}
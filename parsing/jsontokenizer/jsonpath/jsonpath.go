@@ -0,0 +1,286 @@
+// Package jsonpath evaluates RFC 9535 JSONPath queries over JSON read with
+// jsontokenizer, including filter selectors such as
+//
+//	$.store.book[?@.price < 10].title
+//
+// as well as slices, unions, descendant segments and the functions length,
+// count, match, search and value.
+//
+// Most queries are evaluated while the input streams. Members and elements
+// that no segment can select are skipped without being buffered, and a value
+// is buffered only when it is selected, to report its text, or when it is the
+// candidate of a filter selector, to test the filter against it. A match is
+// therefore reported as soon as the selected value and, for filters, the
+// candidate element containing it have been read, and memory is bounded by
+// the largest such value rather than the document.
+//
+// Queries whose result depends on more than that are evaluated on the
+// buffered document instead: unions of several selectors, whose results follow
+// the order of the selectors; negative indexes and slices with a negative
+// bound or step, which depend on the length of the array; and filters that
+// refer to the root with $. Streaming and buffered evaluation select the same
+// nodes; streamed results are reported in document order, which for
+// descendant segments can differ from the order given by RFC 9535 when a
+// member nested in an earlier sibling matches as well.
+package jsonpath
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"io"
+	"slices"
+
+	"github.com/libxyz/gizmo/parsing/jsontokenizer"
+)
+
+// Query is a parsed JSONPath query.
+type Query struct {
+	src       string
+	segments  []segment
+	streaming bool
+}
+
+// Match is a node selected by a query.
+type Match struct {
+	// Path is the normalized path of the node, such as $['book'][0]['title'].
+	Path string
+	// Value is the node's value in compact form, with the original text of
+	// its strings and numbers.
+	Value json.RawMessage
+}
+
+// Parse parses a JSONPath query.
+func Parse(src string) (*Query, error) {
+	p := &parser{src: src}
+	if err := p.expect("$"); err != nil {
+		return nil, err
+	}
+	segs, err := p.segments()
+	if err != nil {
+		return nil, err
+	}
+	if p.pos < len(src) {
+		return nil, p.errorf("unexpected %q", src[p.pos:])
+	}
+	q := &Query{src: src, segments: segs, streaming: true}
+	for _, seg := range segs {
+		if len(seg.selectors) != 1 || !seg.selectors[0].streamable() {
+			q.streaming = false
+		}
+	}
+	return q, nil
+}
+
+// MustParse is like Parse but panics if the query is invalid.
+func MustParse(src string) *Query {
+	q, err := Parse(src)
+	if err != nil {
+		panic(err)
+	}
+	return q
+}
+
+// String returns the source of the query.
+func (q *Query) String() string {
+	return q.src
+}
+
+// Run evaluates the query on every JSON value read from r and calls fn with
+// each match. It stops at the first error of r or fn.
+func (q *Query) Run(r io.Reader, fn func(Match) error) error {
+	dec := jsontokenizer.NewDecoder(r)
+	dec.UseNumber()
+	e := &evaluator{q: q, dec: dec, fn: fn}
+	for dec.More() {
+		var err error
+		if q.streaming {
+			err = e.visit([]int{0}, "$")
+		} else {
+			err = e.buffered()
+		}
+		if err != nil {
+			return err
+		}
+	}
+	// More 在输入结束和出错时都返回 false
+	if _, err := dec.Token(); !errors.Is(err, io.EOF) {
+		return err
+	}
+	return nil
+}
+
+// Select returns the matches of the query in data.
+func (q *Query) Select(data []byte) ([]Match, error) {
+	var out []Match
+	err := q.Run(bytes.NewReader(data), func(m Match) error {
+		out = append(out, m)
+		return nil
+	})
+	return out, err
+}
+
+// evaluator 在一个输入上求值查询。
+// 流式求值时状态 i 表示当前节点是第 i 个段的输入，i 等于段数时节点被选中
+type evaluator struct {
+	q   *Query
+	dec *jsontokenizer.Decoder
+	fn  func(Match) error
+}
+
+// buffered 缓冲整个值并按 RFC 9535 的语义求值
+func (e *evaluator) buffered() error {
+	root, err := e.capture()
+	if err != nil {
+		return err
+	}
+	for _, m := range evaluate([]located{{n: root, path: "$"}}, e.q.segments, root) {
+		if err := e.fn(Match{Path: m.path, Value: m.n.raw}); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// capture 读取下一个值并解析为树
+func (e *evaluator) capture() (*node, error) {
+	var raw json.RawMessage
+	if err := e.dec.Decode(&raw); err != nil {
+		return nil, err
+	}
+	return parseTree(raw)
+}
+
+// visit 在Token流中处理下一个值，states 是它的状态集合
+func (e *evaluator) visit(states []int, path string) error {
+	if slices.Contains(states, len(e.q.segments)) {
+		// 选中的值需要完整的原文，缓冲后在内存中继续
+		n, err := e.capture()
+		if err != nil {
+			return err
+		}
+		return e.visitNode(n, states, path)
+	}
+	tk, err := e.dec.Token()
+	if err != nil {
+		return err
+	}
+	switch tk {
+	case json.Delim('{'):
+		for e.dec.More() {
+			key, err := e.dec.Token()
+			if err != nil {
+				return err
+			}
+			k := key.(string)
+			if err := e.child(states, k, -1, path+nameSegment(k)); err != nil {
+				return err
+			}
+		}
+	case json.Delim('['):
+		for i := 0; e.dec.More(); i++ {
+			if err := e.child(states, "", i, path+indexSegment(i)); err != nil {
+				return err
+			}
+		}
+	default:
+		return nil
+	}
+	_, err = e.dec.Token()
+	return err
+}
+
+// child 处理容器中的下一个成员或元素
+func (e *evaluator) child(states []int, key string, index int, path string) error {
+	if e.filtering(states) {
+		// 过滤选择器的候选值需要完整读取后才能判定
+		n, err := e.capture()
+		if err != nil {
+			return err
+		}
+		return e.visitNode(n, e.next(states, key, index, n), path)
+	}
+	next := e.next(states, key, index, nil)
+	if len(next) == 0 {
+		return e.skip()
+	}
+	return e.visit(next, path)
+}
+
+// visitNode 对已缓冲的值按与 visit 相同的顺序求值
+func (e *evaluator) visitNode(n *node, states []int, path string) error {
+	if len(states) == 0 {
+		return nil
+	}
+	if slices.Contains(states, len(e.q.segments)) {
+		if err := e.fn(Match{Path: path, Value: n.raw}); err != nil {
+			return err
+		}
+	}
+	for _, k := range n.keys {
+		c := n.members[k]
+		if err := e.visitNode(c, e.next(states, k, -1, c), path+nameSegment(k)); err != nil {
+			return err
+		}
+	}
+	for i, c := range n.elems {
+		if err := e.visitNode(c, e.next(states, "", i, c), path+indexSegment(i)); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// filtering 判断是否有状态的段使用过滤选择器
+func (e *evaluator) filtering(states []int) bool {
+	for _, i := range states {
+		if i < len(e.q.segments) && e.q.segments[i].selectors[0].kind == selectFilter {
+			return true
+		}
+	}
+	return false
+}
+
+// next 返回子节点的状态集合，child 仅在有过滤选择器时需要
+func (e *evaluator) next(states []int, key string, index int, child *node) []int {
+	var out []int
+	add := func(i int) {
+		if !slices.Contains(out, i) {
+			out = append(out, i)
+		}
+	}
+	for _, i := range states {
+		if i == len(e.q.segments) {
+			continue
+		}
+		seg := e.q.segments[i]
+		if seg.descendant {
+			add(i)
+		}
+		if seg.selectors[0].matches(key, index, child) {
+			add(i + 1)
+		}
+	}
+	return out
+}
+
+// skip 跳过下一个值
+func (e *evaluator) skip() error {
+	tk, err := e.dec.Token()
+	if err != nil || tk != json.Delim('{') && tk != json.Delim('[') {
+		return err
+	}
+	for depth := 1; depth > 0; {
+		tk, err := e.dec.Token()
+		if err != nil {
+			return err
+		}
+		switch tk {
+		case json.Delim('{'), json.Delim('['):
+			depth++
+		case json.Delim('}'), json.Delim(']'):
+			depth--
+		}
+	}
+	return nil
+}
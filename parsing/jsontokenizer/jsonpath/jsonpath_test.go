@@ -0,0 +1,298 @@
+package jsonpath
+
+import (
+	"errors"
+	"io"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// store 是 RFC 9535 第 1.5 节的示例文档
+const store = `{ "store": {
+    "book": [
+      { "category": "reference",
+        "author": "Nigel Rees",
+        "title": "Sayings of the Century",
+        "price": 8.95
+      },
+      { "category": "fiction",
+        "author": "Evelyn Waugh",
+        "title": "Sword of Honour",
+        "price": 12.99
+      },
+      { "category": "fiction",
+        "author": "Herman Melville",
+        "title": "Moby Dick",
+        "isbn": "0-553-21311-3",
+        "price": 8.99
+      },
+      { "category": "fiction",
+        "author": "J. R. R. Tolkien",
+        "title": "The Lord of the Rings",
+        "isbn": "0-395-19395-8",
+        "price": 22.99
+      }
+    ],
+    "bicycle": {
+      "color": "red",
+      "price": 399
+    }
+  }
+}`
+
+// selectPaths 返回匹配的规范化路径和值
+func selectPaths(t *testing.T, q *Query, doc string) []string {
+	t.Helper()
+	matches, err := q.Select([]byte(doc))
+	require.NoError(t, err)
+	var out []string
+	for _, m := range matches {
+		out = append(out, m.Path+" "+string(m.Value))
+	}
+	return out
+}
+
+func TestRun_Store(t *testing.T) {
+	tests := []struct {
+		query     string
+		streaming bool
+		want      []string
+	}{
+		{`$.store.book[*].author`, true, []string{
+			`$['store']['book'][0]['author'] "Nigel Rees"`,
+			`$['store']['book'][1]['author'] "Evelyn Waugh"`,
+			`$['store']['book'][2]['author'] "Herman Melville"`,
+			`$['store']['book'][3]['author'] "J. R. R. Tolkien"`,
+		}},
+		{`$..author`, true, []string{
+			`$['store']['book'][0]['author'] "Nigel Rees"`,
+			`$['store']['book'][1]['author'] "Evelyn Waugh"`,
+			`$['store']['book'][2]['author'] "Herman Melville"`,
+			`$['store']['book'][3]['author'] "J. R. R. Tolkien"`,
+		}},
+		{`$.store.*`, true, []string{
+			`$['store']['book'] [{"category":"reference","author":"Nigel Rees","title":"Sayings of the Century","price":8.95},{"category":"fiction","author":"Evelyn Waugh","title":"Sword of Honour","price":12.99},{"category":"fiction","author":"Herman Melville","title":"Moby Dick","isbn":"0-553-21311-3","price":8.99},{"category":"fiction","author":"J. R. R. Tolkien","title":"The Lord of the Rings","isbn":"0-395-19395-8","price":22.99}]`,
+			`$['store']['bicycle'] {"color":"red","price":399}`,
+		}},
+		{`$.store..price`, true, []string{
+			`$['store']['book'][0]['price'] 8.95`,
+			`$['store']['book'][1]['price'] 12.99`,
+			`$['store']['book'][2]['price'] 8.99`,
+			`$['store']['book'][3]['price'] 22.99`,
+			`$['store']['bicycle']['price'] 399`,
+		}},
+		{`$..book[2].title`, true, []string{`$['store']['book'][2]['title'] "Moby Dick"`}},
+		{`$..book[-1].title`, false, []string{`$['store']['book'][3]['title'] "The Lord of the Rings"`}},
+		{`$..book[0,1].title`, false, []string{
+			`$['store']['book'][0]['title'] "Sayings of the Century"`,
+			`$['store']['book'][1]['title'] "Sword of Honour"`,
+		}},
+		{`$..book[:2].title`, true, []string{
+			`$['store']['book'][0]['title'] "Sayings of the Century"`,
+			`$['store']['book'][1]['title'] "Sword of Honour"`,
+		}},
+		{`$..book[?@.isbn].title`, true, []string{
+			`$['store']['book'][2]['title'] "Moby Dick"`,
+			`$['store']['book'][3]['title'] "The Lord of the Rings"`,
+		}},
+		{`$..book[?@.price<10].title`, true, []string{
+			`$['store']['book'][0]['title'] "Sayings of the Century"`,
+			`$['store']['book'][2]['title'] "Moby Dick"`,
+		}},
+		{`$..book[?@.price > $.store.book[0].price].author`, false, []string{
+			`$['store']['book'][1]['author'] "Evelyn Waugh"`,
+			`$['store']['book'][2]['author'] "Herman Melville"`,
+			`$['store']['book'][3]['author'] "J. R. R. Tolkien"`,
+		}},
+		{`$..book[?match(@.author, 'J.*') || search(@.title, "[Mm]oby")].price`, true, []string{
+			`$['store']['book'][2]['price'] 8.99`,
+			`$['store']['book'][3]['price'] 22.99`,
+		}},
+		{`$.store.book[?count(@.*) == 5 && !(@.category != 'fiction')].title`, true, []string{
+			`$['store']['book'][2]['title'] "Moby Dick"`,
+			`$['store']['book'][3]['title'] "The Lord of the Rings"`,
+		}},
+		{`$.store.book[?length(@.title) > 15 && value(@..category) == "fiction"].author`, true, []string{
+			`$['store']['book'][3]['author'] "J. R. R. Tolkien"`,
+		}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.query, func(t *testing.T) {
+			q, err := Parse(tt.query)
+			require.NoError(t, err)
+			assert.Equal(t, tt.streaming, q.streaming)
+			assert.Equal(t, tt.want, selectPaths(t, q, store))
+
+			// 流式和缓冲求值选中相同的节点
+			buffered := *q
+			buffered.streaming = false
+			assert.ElementsMatch(t, tt.want, selectPaths(t, &buffered, store))
+		})
+	}
+}
+
+func TestRun_RFCExamples(t *testing.T) {
+	tests := []struct {
+		doc, query string
+		want       []string
+	}{
+		// 2.3.1.3 名称选择器
+		{`{"o": {"j j": {"k.k": 3}}, "'": {"@": 2}}`, `$.o['j j']['k.k']`, []string{`$['o']['j j']['k.k'] 3`}},
+		{`{"o": {"j j": {"k.k": 3}}, "'": {"@": 2}}`, `$["'"]["@"]`, []string{`$['\'']['@'] 2`}},
+		// 2.3.3.3 下标选择器
+		{`["a","b"]`, `$[1]`, []string{`$[1] "b"`}},
+		{`["a","b"]`, `$[-2]`, []string{`$[0] "a"`}},
+		// 2.3.4.3 切片选择器
+		{`["a", "b", "c", "d", "e", "f", "g"]`, `$[1:3]`, []string{`$[1] "b"`, `$[2] "c"`}},
+		{`["a", "b", "c", "d", "e", "f", "g"]`, `$[5:]`, []string{`$[5] "f"`, `$[6] "g"`}},
+		{`["a", "b", "c", "d", "e", "f", "g"]`, `$[1:5:2]`, []string{`$[1] "b"`, `$[3] "d"`}},
+		{`["a", "b", "c", "d", "e", "f", "g"]`, `$[5:1:-2]`, []string{`$[5] "f"`, `$[3] "d"`}},
+		{`["a", "b", "c", "d", "e", "f", "g"]`, `$[::-1]`, []string{
+			`$[6] "g"`, `$[5] "f"`, `$[4] "e"`, `$[3] "d"`, `$[2] "c"`, `$[1] "b"`, `$[0] "a"`,
+		}},
+		{`[1, 2]`, `$[0:2:0]`, nil},
+		// 2.3.2.3 通配符和并集
+		{`{"o": {"j": 1, "k": 2}, "a": [5, 3]}`, `$.o[*, *]`, []string{
+			`$['o']['j'] 1`, `$['o']['k'] 2`, `$['o']['j'] 1`, `$['o']['k'] 2`,
+		}},
+		{`{"o": {"j": 1, "k": 2}, "a": [5, 3]}`, `$[*]`, []string{
+			`$['o'] {"j":1,"k":2}`, `$['a'] [5,3]`,
+		}},
+		// 2.3.5.3 过滤选择器
+		{`{"a": [3, 5, 1, 2, 4, 6, {"b": "j"}, {"b": "k"}, {"b": {}}, {"b": "kilo"}], "o": {"p": 1, "q": 2, "r": 3, "s": 5, "t": {"u": 6}}, "e": "f"}`,
+			`$.a[?@.b == 'kilo']`, []string{`$['a'][9] {"b":"kilo"}`}},
+		{`{"a": [3, 5, 1, 2, 4, 6, {"b": "j"}, {"b": "k"}, {"b": {}}, {"b": "kilo"}]}`,
+			`$.a[?@>3.5]`, []string{`$['a'][1] 5`, `$['a'][4] 4`, `$['a'][5] 6`}},
+		{`{"a": [3, 5, 1, 2, 4, 6, {"b": "j"}, {"b": "k"}, {"b": {}}, {"b": "kilo"}]}`,
+			`$.a[?@.b]`, []string{`$['a'][6] {"b":"j"}`, `$['a'][7] {"b":"k"}`, `$['a'][8] {"b":{}}`, `$['a'][9] {"b":"kilo"}`}},
+		{`{"a": [3, 5, 1, 2, 4, 6, {"b": "j"}, {"b": "k"}, {"b": {}}, {"b": "kilo"}]}`,
+			`$.a[?@<2 || @.b == "k"]`, []string{`$['a'][2] 1`, `$['a'][7] {"b":"k"}`}},
+		{`{"a": [3, 5, 1, 2, 4, 6, {"b": "j"}, {"b": "k"}, {"b": {}}, {"b": "kilo"}]}`,
+			`$.a[?match(@.b, "[jk]")]`, []string{`$['a'][6] {"b":"j"}`, `$['a'][7] {"b":"k"}`}},
+		{`{"a": [3, 5, 1, 2, 4, 6, {"b": "j"}, {"b": "k"}, {"b": {}}, {"b": "kilo"}]}`,
+			`$.a[?search(@.b, "[jk]")]`, []string{`$['a'][6] {"b":"j"}`, `$['a'][7] {"b":"k"}`, `$['a'][9] {"b":"kilo"}`}},
+		{`{"o": {"p": 1, "q": 2, "r": 3, "s": 5, "t": {"u": 6}}}`,
+			`$.o[?@>1 && @<4]`, []string{`$['o']['q'] 2`, `$['o']['r'] 3`}},
+		{`{"o": {"p": 1, "q": 2, "r": 3, "s": 5, "t": {"u": 6}}}`,
+			`$.o[?@.u || @.x]`, []string{`$['o']['t'] {"u":6}`}},
+		{`{"a": [3, 5, 1, 2, 4, 6, {"b": "j"}, {"b": "k"}, {"b": {}}, {"b": "kilo"}]}`,
+			`$.a[?@.b == $.x]`, []string{`$['a'][0] 3`, `$['a'][1] 5`, `$['a'][2] 1`, `$['a'][3] 2`, `$['a'][4] 4`, `$['a'][5] 6`}},
+		{`{"a": [3, 5, 1, 2, 4, 6, {"b": "j"}, {"b": "k"}, {"b": {}}, {"b": "kilo"}]}`,
+			`$.a[?@ == @]`, []string{`$['a'][0] 3`, `$['a'][1] 5`, `$['a'][2] 1`, `$['a'][3] 2`, `$['a'][4] 4`, `$['a'][5] 6`,
+				`$['a'][6] {"b":"j"}`, `$['a'][7] {"b":"k"}`, `$['a'][8] {"b":{}}`, `$['a'][9] {"b":"kilo"}`}},
+		// 2.5.2.3 后代段
+		{`{"o": {"j": 1, "k": 2}, "a": [5, 3, [{"j": 4}, {"k": 6}]]}`, `$..j`, []string{`$['o']['j'] 1`, `$['a'][2][0]['j'] 4`}},
+		{`{"o": {"j": 1, "k": 2}, "a": [5, 3, [{"j": 4}, {"k": 6}]]}`, `$..[0]`, []string{`$['a'][0] 5`, `$['a'][2][0] {"j":4}`}},
+		{`{"o": {"j": 1, "k": 2}, "a": [5, 3, [{"j": 4}, {"k": 6}]]}`, `$..*`, []string{
+			`$['o'] {"j":1,"k":2}`, `$['o']['j'] 1`, `$['o']['k'] 2`,
+			`$['a'] [5,3,[{"j":4},{"k":6}]]`, `$['a'][0] 5`, `$['a'][1] 3`, `$['a'][2] [{"j":4},{"k":6}]`,
+			`$['a'][2][0] {"j":4}`, `$['a'][2][0]['j'] 4`, `$['a'][2][1] {"k":6}`, `$['a'][2][1]['k'] 6`,
+		}},
+		// 2.6.1 null 语义
+		{`{"a": null, "b": [null], "c": [{}], "null": 1}`, `$.a`, []string{`$['a'] null`}},
+		{`{"a": null, "b": [null], "c": [{}], "null": 1}`, `$.a[0]`, nil},
+		{`{"a": null, "b": [null], "c": [{}], "null": 1}`, `$.b[?@==null]`, []string{`$['b'][0] null`}},
+		{`{"a": null, "b": [null], "c": [{}], "null": 1}`, `$.c[?@.d==null]`, nil},
+		{`{"a": null, "b": [null], "c": [{}], "null": 1}`, `$.null`, []string{`$['null'] 1`}},
+		// 规范化路径中的转义
+		{"{\"a\\nb\": {\"\\u0001\\\\\": 1}}", `$.*.*`, []string{`$['a\nb']['\u0001\\'] 1`}},
+		{`{"π": {"1.0": 1.0}}`, `$.π["1.0"]`, []string{`$['π']['1.0'] 1.0`}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.query, func(t *testing.T) {
+			q, err := Parse(tt.query)
+			require.NoError(t, err)
+			assert.Equal(t, tt.want, selectPaths(t, q, tt.doc))
+		})
+	}
+}
+
+func TestParse_Errors(t *testing.T) {
+	tests := []struct {
+		query, err string
+	}{
+		{``, `jsonpath: expected "$", found end of query at offset 0`},
+		{` $`, `jsonpath: expected "$" at offset 0`},
+		{`$ `, `jsonpath: unexpected " " at offset 1`},
+		{`$.`, `jsonpath: expected member name or * at offset 2`},
+		{`$[01]`, `jsonpath: invalid integer at offset 2`},
+		{`$[-0]`, `jsonpath: invalid integer at offset 2`},
+		{`$[9007199254740992]`, `jsonpath: integer 9007199254740992 out of range at offset 2`},
+		{`$['a`, `jsonpath: unterminated string at offset 2`},
+		{`$["\'"]`, `jsonpath: invalid escape in string at offset 3`},
+		{`$['\uD800']`, `jsonpath: unpaired surrogate at offset 9`},
+		{`$[?@.a == 1 ]`, ``},
+		{`$[?@.*==1]`, `jsonpath: non-singular query in comparison at offset 3`},
+		{`$[?1]`, `jsonpath: literal must be compared at offset 3`},
+		{`$[?length(@)]`, `jsonpath: result of length must be compared at offset 3`},
+		{`$[?match(@.a, 'x') == true]`, `jsonpath: operand of comparison is not a value at offset 3`},
+		{`$[?count(1) > 0]`, `jsonpath: count: argument must be a query at offset 9`},
+		{`$[?foo(@)]`, `jsonpath: unknown function foo at offset 3`},
+		{`$[?length(@, @)]`, `jsonpath: length takes 1 arguments at offset 3`},
+		{`$[?@.a ==]`, `jsonpath: unexpected ']' at offset 9`},
+		{`$[?(@.a]`, `jsonpath: expected ")" at offset 7`},
+		{`$[1 2]`, `jsonpath: expected "," at offset 4`},
+	}
+	for _, tt := range tests {
+		t.Run(tt.query, func(t *testing.T) {
+			_, err := Parse(tt.query)
+			if tt.err == "" {
+				assert.NoError(t, err)
+				return
+			}
+			assert.EqualError(t, err, tt.err)
+		})
+	}
+}
+
+// TestRun_Streaming 检查匹配在输入结束之前就已报告，过滤在候选元素结束时判定
+func TestRun_Streaming(t *testing.T) {
+	q := MustParse(`$.events[?@.type == 'click'].id`)
+	require.True(t, q.streaming)
+
+	pr, pw := io.Pipe()
+	results := make(chan string)
+	done := make(chan error, 1)
+	go func() {
+		done <- q.Run(pr, func(m Match) error {
+			results <- m.Path + " " + string(m.Value)
+			return nil
+		})
+	}()
+	write := func(s string, last bool) {
+		go func() {
+			_, _ = io.WriteString(pw, s)
+			if last {
+				pw.Close()
+			}
+		}()
+	}
+
+	write(`{"meta": {"big": [1, 2, 3]}, "events": [{"id": 1, "type": "view"}, {"id": 2, "type": "click"}, `, false)
+	assert.Equal(t, `$['events'][1]['id'] 2`, <-results)
+	write(`{"type": "click", "id": 3}`, false)
+	assert.Equal(t, `$['events'][2]['id'] 3`, <-results)
+	write(`]}`, true)
+	require.NoError(t, <-done)
+}
+
+func TestRun_Errors(t *testing.T) {
+	q := MustParse(`$.a[*]`)
+	err := q.Run(strings.NewReader(`{"a": [1, }`), func(Match) error { return nil })
+	assert.Error(t, err)
+
+	stop := errors.New("stop")
+	err = q.Run(strings.NewReader(`{"a": [1, 2]}`), func(Match) error { return stop })
+	assert.ErrorIs(t, err, stop)
+
+	var got []string
+	err = q.Run(strings.NewReader(`{"a": [1]} {"a": [2]}`), func(m Match) error {
+		got = append(got, string(m.Value))
+		return nil
+	})
+	require.NoError(t, err)
+	assert.Equal(t, []string{"1", "2"}, got)
+}
@@ -0,0 +1,573 @@
+package jsonpath
+
+import (
+	"encoding/json"
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+	"unicode/utf16"
+	"unicode/utf8"
+)
+
+// maxInt 是 I-JSON 能精确表示的最大整数
+const maxInt = 1<<53 - 1
+
+// parser 是 RFC 9535 查询的递归下降解析器
+type parser struct {
+	src string
+	pos int
+}
+
+// errorf 返回带有当前位置的语法错误
+func (p *parser) errorf(format string, args ...any) error {
+	return fmt.Errorf("jsonpath: %s at offset %d", fmt.Sprintf(format, args...), p.pos)
+}
+
+func (p *parser) peek() byte {
+	if p.pos < len(p.src) {
+		return p.src[p.pos]
+	}
+	return 0
+}
+
+// space 跳过 RFC 9535 中的空白 S
+func (p *parser) space() {
+	for p.pos < len(p.src) && strings.IndexByte(" \t\n\r", p.src[p.pos]) >= 0 {
+		p.pos++
+	}
+}
+
+// consume 在当前位置为 s 时跳过它
+func (p *parser) consume(s string) bool {
+	if strings.HasPrefix(p.src[p.pos:], s) {
+		p.pos += len(s)
+		return true
+	}
+	return false
+}
+
+func (p *parser) expect(s string) error {
+	if !p.consume(s) {
+		if p.pos == len(p.src) {
+			return p.errorf("expected %q, found end of query", s)
+		}
+		return p.errorf("expected %q", s)
+	}
+	return nil
+}
+
+// query 解析 $ 或 @ 之后的段
+func (p *parser) segments() ([]segment, error) {
+	var segs []segment
+	for {
+		// 段之前可以有空白，但空白之后不是段时属于外层表达式
+		start := p.pos
+		p.space()
+		if p.peek() != '.' && p.peek() != '[' {
+			p.pos = start
+			return segs, nil
+		}
+		seg, err := p.segment()
+		if err != nil {
+			return nil, err
+		}
+		segs = append(segs, seg)
+	}
+}
+
+// segment 解析一个子段或后代段
+func (p *parser) segment() (segment, error) {
+	var seg segment
+	if p.consume("..") {
+		seg.descendant = true
+		if p.peek() == '[' {
+			return p.bracketed(seg)
+		}
+	} else if !p.consume(".") {
+		return p.bracketed(seg)
+	}
+	if p.consume("*") {
+		seg.selectors = []selector{{kind: selectWildcard}}
+		return seg, nil
+	}
+	name, ok := p.shorthand()
+	if !ok {
+		return seg, p.errorf("expected member name or *")
+	}
+	seg.selectors = []selector{{kind: selectName, name: name}}
+	return seg, nil
+}
+
+// shorthand 读取 .name 形式的成员名
+func (p *parser) shorthand() (string, bool) {
+	start := p.pos
+	for p.pos < len(p.src) {
+		r, size := utf8.DecodeRuneInString(p.src[p.pos:])
+		if !(r == '_' || 'a' <= r && r <= 'z' || 'A' <= r && r <= 'Z' || r >= 0x80 && r != utf8.RuneError ||
+			p.pos > start && '0' <= r && r <= '9') {
+			break
+		}
+		p.pos += size
+	}
+	return p.src[start:p.pos], p.pos > start
+}
+
+// bracketed 解析 [ 之后以逗号分隔的选择器
+func (p *parser) bracketed(seg segment) (segment, error) {
+	if err := p.expect("["); err != nil {
+		return seg, err
+	}
+	for {
+		p.space()
+		sel, err := p.selector()
+		if err != nil {
+			return seg, err
+		}
+		seg.selectors = append(seg.selectors, sel)
+		p.space()
+		if p.consume("]") {
+			return seg, nil
+		}
+		if err := p.expect(","); err != nil {
+			return seg, err
+		}
+	}
+}
+
+// selector 解析一个选择器
+func (p *parser) selector() (selector, error) {
+	switch c := p.peek(); {
+	case c == '\'' || c == '"':
+		name, err := p.string()
+		return selector{kind: selectName, name: name}, err
+	case c == '*':
+		p.pos++
+		return selector{kind: selectWildcard}, nil
+	case c == '?':
+		p.pos++
+		p.space()
+		f, err := p.logicalOr()
+		return selector{kind: selectFilter, filter: f}, err
+	}
+
+	var sel selector
+	if p.peek() != ':' {
+		i, err := p.integer()
+		if err != nil {
+			return sel, err
+		}
+		start := p.pos
+		p.space()
+		if p.peek() != ':' {
+			p.pos = start
+			return selector{kind: selectIndex, index: i}, nil
+		}
+		sel.start, sel.hasStart = i, true
+	}
+	sel.kind = selectSlice
+	p.pos++ // ':'
+	p.space()
+	if p.peek() == '-' || isDigit(p.peek()) {
+		i, err := p.integer()
+		if err != nil {
+			return sel, err
+		}
+		sel.end, sel.hasEnd = i, true
+		p.space()
+	}
+	if p.consume(":") {
+		p.space()
+		if p.peek() == '-' || isDigit(p.peek()) {
+			i, err := p.integer()
+			if err != nil {
+				return sel, err
+			}
+			sel.step, sel.hasStep = i, true
+		}
+	}
+	return sel, nil
+}
+
+// integer 读取 I-JSON 范围内没有前导零的整数
+func (p *parser) integer() (int, error) {
+	start := p.pos
+	p.consume("-")
+	digits := p.pos
+	for isDigit(p.peek()) {
+		p.pos++
+	}
+	s := p.src[start:p.pos]
+	if p.pos == digits || p.src[digits] == '0' && (p.pos-digits > 1 || digits > start) {
+		p.pos = start
+		return 0, p.errorf("invalid integer")
+	}
+	i, err := strconv.Atoi(s)
+	if err != nil || i > maxInt || i < -maxInt {
+		p.pos = start
+		return 0, p.errorf("integer %s out of range", s)
+	}
+	return i, nil
+}
+
+// string 读取单引号或双引号字符串字面量
+func (p *parser) string() (string, error) {
+	start := p.pos
+	quote := p.src[p.pos]
+	p.pos++
+	var b []byte
+	for {
+		if p.pos >= len(p.src) {
+			p.pos = start
+			return "", p.errorf("unterminated string")
+		}
+		c := p.src[p.pos]
+		switch {
+		case c == quote:
+			p.pos++
+			return string(b), nil
+		case c < 0x20:
+			return "", p.errorf("control character in string")
+		case c != '\\':
+			b = append(b, c)
+			p.pos++
+			continue
+		}
+
+		p.pos++
+		e := p.peek()
+		p.pos++
+		switch e {
+		case 'b':
+			b = append(b, '\b')
+		case 'f':
+			b = append(b, '\f')
+		case 'n':
+			b = append(b, '\n')
+		case 'r':
+			b = append(b, '\r')
+		case 't':
+			b = append(b, '\t')
+		case '/', '\\', quote:
+			b = append(b, e)
+		case 'u':
+			r, err := p.unicodeEscape()
+			if err != nil {
+				return "", err
+			}
+			b = utf8.AppendRune(b, r)
+		default:
+			p.pos -= 2
+			return "", p.errorf("invalid escape in string")
+		}
+	}
+}
+
+// unicodeEscape 读取 \u 之后的十六进制码元，包括代理对
+func (p *parser) unicodeEscape() (rune, error) {
+	hex := func() (rune, bool) {
+		if p.pos+4 > len(p.src) {
+			return 0, false
+		}
+		n, err := strconv.ParseUint(p.src[p.pos:p.pos+4], 16, 16)
+		if err != nil {
+			return 0, false
+		}
+		p.pos += 4
+		return rune(n), true
+	}
+	r, ok := hex()
+	if !ok {
+		return 0, p.errorf("invalid \\u escape")
+	}
+	if utf16.IsSurrogate(r) {
+		if r >= 0xdc00 || !p.consume(`\u`) {
+			return 0, p.errorf("unpaired surrogate")
+		}
+		r2, ok := hex()
+		if r = utf16.DecodeRune(r, r2); !ok || r == utf8.RuneError {
+			return 0, p.errorf("unpaired surrogate")
+		}
+	}
+	return r, nil
+}
+
+// logicalOr 解析过滤表达式
+func (p *parser) logicalOr() (logical, error) {
+	l, err := p.logicalAnd()
+	if err != nil {
+		return nil, err
+	}
+	for {
+		start := p.pos
+		p.space()
+		if !p.consume("||") {
+			p.pos = start
+			return l, nil
+		}
+		p.space()
+		r, err := p.logicalAnd()
+		if err != nil {
+			return nil, err
+		}
+		l = orExpr{l, r}
+	}
+}
+
+func (p *parser) logicalAnd() (logical, error) {
+	l, err := p.basic()
+	if err != nil {
+		return nil, err
+	}
+	for {
+		start := p.pos
+		p.space()
+		if !p.consume("&&") {
+			p.pos = start
+			return l, nil
+		}
+		p.space()
+		r, err := p.basic()
+		if err != nil {
+			return nil, err
+		}
+		l = andExpr{l, r}
+	}
+}
+
+// basic 解析括号表达式、比较表达式或测试表达式
+func (p *parser) basic() (logical, error) {
+	if p.consume("!") {
+		p.space()
+		x, err := p.negatable()
+		if err != nil {
+			return nil, err
+		}
+		return notExpr{x}, nil
+	}
+	if p.peek() == '(' {
+		return p.negatable()
+	}
+
+	start := p.pos
+	l, err := p.operand()
+	if err != nil {
+		return nil, err
+	}
+	end := p.pos
+	p.space()
+	for _, op := range []string{"==", "!=", "<=", ">=", "<", ">"} {
+		if !p.consume(op) {
+			continue
+		}
+		p.space()
+		opStart := p.pos
+		r, err := p.operand()
+		if err != nil {
+			return nil, err
+		}
+		lc, err := comparableOf(l)
+		if err != nil {
+			p.pos = start
+			return nil, p.errorf("%v", err)
+		}
+		rc, err := comparableOf(r)
+		if err != nil {
+			p.pos = opStart
+			return nil, p.errorf("%v", err)
+		}
+		return compareExpr{op, lc, rc}, nil
+	}
+	p.pos = end
+	t, err := testOf(l)
+	if err != nil {
+		p.pos = start
+		return nil, p.errorf("%v", err)
+	}
+	return t, nil
+}
+
+// negatable 解析可以跟在 ! 之后的括号表达式或测试表达式
+func (p *parser) negatable() (logical, error) {
+	if p.consume("(") {
+		p.space()
+		x, err := p.logicalOr()
+		if err != nil {
+			return nil, err
+		}
+		p.space()
+		return x, p.expect(")")
+	}
+	start := p.pos
+	x, err := p.operand()
+	if err != nil {
+		return nil, err
+	}
+	t, err := testOf(x)
+	if err != nil {
+		p.pos = start
+		return nil, p.errorf("%v", err)
+	}
+	return t, nil
+}
+
+// operand 解析字面量、过滤查询或函数调用
+func (p *parser) operand() (any, error) {
+	switch c := p.peek(); {
+	case c == '@' || c == '$':
+		p.pos++
+		segs, err := p.segments()
+		if err != nil {
+			return nil, err
+		}
+		return &filterQuery{absolute: c == '$', segments: segs}, nil
+	case c == '\'' || c == '"':
+		s, err := p.string()
+		if err != nil {
+			return nil, err
+		}
+		b, _ := json.Marshal(s)
+		return literal{&node{raw: b, scalar: s}}, nil
+	case c == '-' || isDigit(c):
+		return p.number()
+	case 'a' <= c && c <= 'z':
+		start := p.pos
+		for p.pos < len(p.src) && (p.peek() == '_' || 'a' <= p.peek() && p.peek() <= 'z' || isDigit(p.peek())) {
+			p.pos++
+		}
+		name := p.src[start:p.pos]
+		if p.peek() == '(' {
+			return p.function(name, start)
+		}
+		switch name {
+		case "true", "false", "null":
+			var v any
+			if name != "null" {
+				v = name == "true"
+			}
+			return literal{&node{raw: json.RawMessage(name), scalar: v}}, nil
+		}
+		p.pos = start
+		return nil, p.errorf("unexpected %q", name)
+	case c == 0:
+		return nil, p.errorf("unexpected end of query")
+	}
+	return nil, p.errorf("unexpected %q", p.peek())
+}
+
+// number 读取数字字面量
+func (p *parser) number() (any, error) {
+	start := p.pos
+	for p.pos < len(p.src) && strings.IndexByte("+-.eE0123456789", p.src[p.pos]) >= 0 {
+		p.pos++
+	}
+	text := p.src[start:p.pos]
+	if !json.Valid([]byte(text)) {
+		p.pos = start
+		return nil, p.errorf("invalid number %s", text)
+	}
+	return literal{&node{raw: json.RawMessage(text), scalar: json.Number(text)}}, nil
+}
+
+// function 解析函数调用并检查参数类型
+func (p *parser) function(name string, start int) (any, error) {
+	params, ok := functions[name]
+	if !ok {
+		p.pos = start
+		return nil, p.errorf("unknown function %s", name)
+	}
+	p.pos++ // '('
+	f := &funcCall{name: name}
+	for {
+		p.space()
+		if p.consume(")") {
+			break
+		}
+		if len(f.args) > 0 {
+			if err := p.expect(","); err != nil {
+				return nil, err
+			}
+			p.space()
+		}
+		argStart := p.pos
+		arg, err := p.argument()
+		if err != nil {
+			return nil, err
+		}
+		if len(f.args) < len(params) {
+			if arg, err = convertArg(arg, params[len(f.args)]); err != nil {
+				p.pos = argStart
+				return nil, p.errorf("%s: %v", name, err)
+			}
+		}
+		f.args = append(f.args, arg)
+	}
+	if len(f.args) != len(params) {
+		p.pos = start
+		return nil, p.errorf("%s takes %d arguments", name, len(params))
+	}
+	if name == "match" || name == "search" {
+		// 正则表达式为字面量时预先编译
+		if lit, ok := f.args[1].(literal); ok {
+			if s, ok := lit.n.scalar.(string); ok {
+				f.re = compileIRegexp(s, name == "match")
+			}
+		}
+	}
+	return f, nil
+}
+
+// argument 解析函数参数：字面量、过滤查询、函数调用或逻辑表达式
+func (p *parser) argument() (any, error) {
+	start := p.pos
+	if p.peek() != '!' && p.peek() != '(' {
+		x, err := p.operand()
+		if err == nil {
+			end := p.pos
+			p.space()
+			if p.peek() == ',' || p.peek() == ')' {
+				return x, nil
+			}
+			p.pos = end
+		}
+	}
+	p.pos = start
+	return p.logicalOr()
+}
+
+// compileIRegexp 将 RFC 9485 I-Regexp 转换为 Go 正则表达式，无效时返回 nil
+func compileIRegexp(pattern string, full bool) *regexp.Regexp {
+	// I-Regexp 中字符类之外的 . 不匹配 \n 和 \r
+	var b strings.Builder
+	inClass := false
+	for i := 0; i < len(pattern); i++ {
+		c := pattern[i]
+		switch {
+		case c == '\\' && i+1 < len(pattern):
+			b.WriteByte(c)
+			i++
+			c = pattern[i]
+		case c == '[':
+			inClass = true
+		case c == ']':
+			inClass = false
+		case c == '.' && !inClass:
+			b.WriteString(`[^\n\r]`)
+			continue
+		}
+		b.WriteByte(c)
+	}
+	s := b.String()
+	if full {
+		s = `^(?:` + s + `)$`
+	}
+	re, err := regexp.Compile(s)
+	if err != nil {
+		return nil
+	}
+	return re
+}
+
+func isDigit(c byte) bool {
+	return '0' <= c && c <= '9'
+}
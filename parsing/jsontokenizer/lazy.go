@@ -0,0 +1,374 @@
+package jsontokenizer
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+)
+
+// LazyValue is a value in a document opened with Lazy. Methods that navigate
+// to a child return a LazyValue for which Exists is false when nothing is
+// there, so lookups can be chained, as in
+//
+//	doc.Get("users").Index(3).Get("name").String()
+//
+// A LazyValue and the values derived from it share a cache and are not safe
+// for concurrent use.
+type LazyValue struct {
+	doc  *lazyDoc
+	off  int    // 值第一个字节的偏移量
+	path string // 用于错误信息
+	err  error
+}
+
+// lazyDoc 保存输入和已经扫描过的容器
+type lazyDoc struct {
+	data  []byte
+	nodes map[int]*lazyNode // 以容器开头括号的偏移量为键
+}
+
+// lazyNode 记录一个容器中已经发现的子值，再次查找时从上次停止的地方继续扫描
+type lazyNode struct {
+	keys [][]byte // 对象中已发现成员的键名原文，不含引号
+	offs []int    // 已发现子值的偏移量
+	next int      // 尚未发现子值时继续扫描的位置
+	done bool     // 已扫描到结尾括号
+	end  int      // 结尾括号之后的位置，0 表示未知
+}
+
+// Lazy returns the top-level value of the JSON document in data without
+// parsing it. Navigating with Get and Index scans data only as far as the
+// requested value, skipping the values before it by counting brackets, and
+// remembers the offsets it found so later lookups in the same containers do
+// not scan again. Values that are never reached are not validated.
+//
+// data must not be modified while values from it are in use.
+func Lazy(data []byte) LazyValue {
+	d := &lazyDoc{data: data, nodes: map[int]*lazyNode{}}
+	off := d.space(0)
+	if off == len(data) {
+		return LazyValue{path: "$", err: io.ErrUnexpectedEOF}
+	}
+	return LazyValue{doc: d, off: off, path: "$"}
+}
+
+// Exists reports whether v is a value of the document.
+func (v LazyValue) Exists() bool {
+	return v.doc != nil
+}
+
+// Err returns the error that stopped the lookup of v, such as a
+// *SyntaxError, or nil if v was found or simply does not exist.
+func (v LazyValue) Err() error {
+	return v.err
+}
+
+// Path returns the path used to reach v, such as $.users[3].name.
+func (v LazyValue) Path() string {
+	return v.path
+}
+
+// Type returns TokenObjectStart for objects, TokenArrayStart for arrays,
+// TokenString, TokenNumber, TokenBoolean or TokenNull, and TokenUnknown for a
+// missing value.
+func (v LazyValue) Type() TokenType {
+	if v.doc == nil {
+		return TokenUnknown
+	}
+	switch v.doc.data[v.off] {
+	case '{':
+		return TokenObjectStart
+	case '[':
+		return TokenArrayStart
+	case '"':
+		return TokenString
+	case 't', 'f':
+		return TokenBoolean
+	case 'n':
+		return TokenNull
+	}
+	return TokenNumber
+}
+
+// Get returns the member of an object with the given key, the first one if
+// the key is repeated.
+func (v LazyValue) Get(key string) LazyValue {
+	child := LazyValue{path: string(appendKeySegment([]byte(v.path), key)), err: v.err}
+	if v.Type() != TokenObjectStart {
+		return child
+	}
+	n := v.doc.node(v.off)
+	var u unescaper
+	var buf []byte
+	for i := 0; ; i++ {
+		if i == len(n.offs) {
+			ok, err := v.doc.advance(v, n)
+			if err != nil || !ok {
+				child.err = err
+				return child
+			}
+		}
+		raw := n.keys[i]
+		if bytes.IndexByte(raw, '\\') >= 0 {
+			buf = u.finish(u.append(buf[:0], raw))
+			raw = buf
+		}
+		if string(raw) == key {
+			child.doc, child.off = v.doc, n.offs[i]
+			return child
+		}
+	}
+}
+
+// Index returns the element of an array at index i.
+func (v LazyValue) Index(i int) LazyValue {
+	child := LazyValue{path: string(appendIndexSegment([]byte(v.path), i)), err: v.err}
+	if v.Type() != TokenArrayStart || i < 0 {
+		return child
+	}
+	n := v.doc.node(v.off)
+	for i >= len(n.offs) {
+		ok, err := v.doc.advance(v, n)
+		if err != nil || !ok {
+			child.err = err
+			return child
+		}
+	}
+	child.doc, child.off = v.doc, n.offs[i]
+	return child
+}
+
+// Len returns the number of members of an object or elements of an array,
+// scanning it to the end, and 0 for other values.
+func (v LazyValue) Len() int {
+	if t := v.Type(); t != TokenObjectStart && t != TokenArrayStart {
+		return 0
+	}
+	n := v.doc.node(v.off)
+	for {
+		if ok, err := v.doc.advance(v, n); err != nil || !ok {
+			return len(n.offs)
+		}
+	}
+}
+
+// Raw returns the JSON text of the value as it appears in the document,
+// whitespace inside containers included, or nil for a missing or malformed
+// value. It shares memory with the document.
+func (v LazyValue) Raw() json.RawMessage {
+	if v.doc == nil {
+		return nil
+	}
+	end, err := v.doc.valueEnd(v)
+	if err != nil {
+		return nil
+	}
+	return v.doc.data[v.off:end:end]
+}
+
+// String returns the decoded value of a string and the JSON text of any other
+// value, or "" for a missing value.
+func (v LazyValue) String() string {
+	raw := v.Raw()
+	if v.Type() != TokenString || raw == nil {
+		return string(raw)
+	}
+	var u unescaper
+	return string(u.finish(u.append(nil, raw[1:len(raw)-1])))
+}
+
+// Int returns the value of a number truncated to an integer, or 0 if the
+// value is not a number or out of range.
+func (v LazyValue) Int() int64 {
+	return v.result().Int()
+}
+
+// Float returns the value of a number, or 0 if the value is not a number.
+func (v LazyValue) Float() float64 {
+	return v.result().Float()
+}
+
+// Bool reports whether the value is true.
+func (v LazyValue) Bool() bool {
+	return v.result().Bool()
+}
+
+// Decode stores the value in v as json.Unmarshal would.
+func (v LazyValue) Decode(dst any) error {
+	if v.err != nil {
+		return v.err
+	}
+	if v.doc == nil {
+		return fmt.Errorf("jsontokenizer: decode %s: %w", v.path, ErrPathNotFound)
+	}
+	return json.Unmarshal(v.Raw(), dst)
+}
+
+// result 转换为标量的 Result
+func (v LazyValue) result() Result {
+	switch t := v.Type(); t {
+	case TokenNumber, TokenBoolean:
+		return Result{Path: v.path, Type: t, Raw: v.Raw()}
+	case TokenUnknown, TokenObjectStart, TokenObjectEnd, TokenArrayStart, TokenArrayEnd, TokenString,
+		TokenStringEscape, TokenStringChunk, TokenKey, TokenKeyEscape, TokenNull, TokenComma, TokenColon,
+		TokenQuote, TokenWhitespace:
+	}
+	return Result{Path: v.path}
+}
+
+// node 返回 off 处容器的扫描记录
+func (d *lazyDoc) node(off int) *lazyNode {
+	n := d.nodes[off]
+	if n == nil {
+		n = &lazyNode{next: off + 1}
+		d.nodes[off] = n
+	}
+	return n
+}
+
+// advance 发现容器 v 的下一个子值，到达结尾括号时返回 false
+func (d *lazyDoc) advance(v LazyValue, n *lazyNode) (bool, error) {
+	if n.done {
+		return false, nil
+	}
+	closer := byte(']')
+	if d.data[v.off] == '{' {
+		closer = '}'
+	}
+	i := d.space(n.next)
+	first := len(n.offs) == 0
+	if !first {
+		end, err := d.valueEnd(LazyValue{doc: d, off: n.offs[len(n.offs)-1], path: v.path})
+		if err != nil {
+			return false, err
+		}
+		i = d.space(end)
+	}
+	if i == len(d.data) {
+		return false, io.ErrUnexpectedEOF
+	}
+	if d.data[i] == closer {
+		n.done, n.end = true, i+1
+		return false, nil
+	}
+	if !first {
+		if d.data[i] != ',' {
+			return false, d.syntaxError(v, i, "expected comma")
+		}
+		if i = d.space(i + 1); i == len(d.data) {
+			return false, io.ErrUnexpectedEOF
+		}
+	}
+	var key []byte
+	if closer == '}' {
+		if d.data[i] != '"' {
+			return false, d.syntaxError(v, i, "expected key")
+		}
+		end, err := d.stringEnd(i)
+		if err != nil {
+			return false, err
+		}
+		key = d.data[i+1 : end-1]
+		i = d.space(end)
+		if i == len(d.data) {
+			return false, io.ErrUnexpectedEOF
+		}
+		if d.data[i] != ':' {
+			return false, d.syntaxError(v, i, "expected colon")
+		}
+		i = d.space(i + 1)
+		if i == len(d.data) {
+			return false, io.ErrUnexpectedEOF
+		}
+	}
+	switch d.data[i] {
+	case ',', ':', ']', '}':
+		return false, d.syntaxError(v, i, "expected value")
+	}
+	if closer == '}' {
+		n.keys = append(n.keys, key)
+	}
+	n.offs = append(n.offs, i)
+	return true, nil
+}
+
+// valueEnd 返回值之后的位置，未扫描过的容器通过计算括号深度跳过
+func (d *lazyDoc) valueEnd(v LazyValue) (int, error) {
+	if n := d.nodes[v.off]; n != nil && n.end > 0 {
+		return n.end, nil
+	}
+	data := d.data
+	switch data[v.off] {
+	case '"':
+		return d.stringEnd(v.off)
+	case '{', '[':
+		depth := 0
+		for i := v.off; i < len(data); i++ {
+			switch data[i] {
+			case '"':
+				end, err := d.stringEnd(i)
+				if err != nil {
+					return 0, err
+				}
+				i = end - 1
+			case '{', '[':
+				depth++
+			case '}', ']':
+				depth--
+				if depth == 0 {
+					if n := d.nodes[v.off]; n != nil {
+						n.end = i + 1
+					}
+					return i + 1, nil
+				}
+			}
+		}
+		return 0, io.ErrUnexpectedEOF
+	}
+	i := v.off
+	for i < len(data) && !isLazyDelim(data[i]) {
+		i++
+	}
+	if i == v.off {
+		return 0, d.syntaxError(v, i, "expected value")
+	}
+	return i, nil
+}
+
+// stringEnd 返回 off 处字符串结尾引号之后的位置
+func (d *lazyDoc) stringEnd(off int) (int, error) {
+	for i := off + 1; i < len(d.data); {
+		j := bytes.IndexAny(d.data[i:], `"\`)
+		if j < 0 {
+			break
+		}
+		i += j
+		if d.data[i] == '"' {
+			return i + 1, nil
+		}
+		i += 2
+	}
+	return 0, io.ErrUnexpectedEOF
+}
+
+// space 跳过空白，返回下一个非空白字节的位置
+func (d *lazyDoc) space(i int) int {
+	for i < len(d.data) && isWhitespace(rune(d.data[i])) {
+		i++
+	}
+	return i
+}
+
+// syntaxError 返回容器 v 中 off 处的语法错误
+func (d *lazyDoc) syntaxError(v LazyValue, off int, msg string) error {
+	if off < len(d.data) {
+		msg = fmt.Sprintf("%s, found %q", msg, d.data[off])
+	}
+	return &SyntaxError{Msg: msg, Offset: int64(off), Path: v.path}
+}
+
+// isLazyDelim 报告字节是否结束一个数字或关键字
+func isLazyDelim(c byte) bool {
+	return c == ',' || c == ']' || c == '}' || c == ':' || isWhitespace(rune(c))
+}
@@ -0,0 +1,173 @@
+package jsontokenizer
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestLazy(t *testing.T) {
+	doc := Lazy([]byte(` {"id":"evt_1", "n" : -12.5,"ok":true,"none":null,"a\"b":"x\ty",
+		"users":[{"name":"a"},{"name":"bé","tags":["x", "y"]},[],{}],"id":"dup"} `))
+	require.NoError(t, doc.Err())
+	assert.Equal(t, TokenObjectStart, doc.Type())
+	assert.Equal(t, "evt_1", doc.Get("id").String())
+	assert.Equal(t, -12.5, doc.Get("n").Float())
+	assert.Equal(t, int64(-12), doc.Get("n").Int())
+	assert.True(t, doc.Get("ok").Bool())
+	assert.Equal(t, TokenNull, doc.Get("none").Type())
+	assert.Equal(t, "x\ty", doc.Get(`a"b`).String())
+
+	name := doc.Get("users").Index(1).Get("name")
+	assert.Equal(t, "bé", name.String())
+	assert.Equal(t, "$.users[1].name", name.Path())
+	assert.Equal(t, `["x", "y"]`, string(doc.Get("users").Index(1).Get("tags").Raw()))
+	assert.Equal(t, 4, doc.Get("users").Len())
+	assert.Equal(t, 0, doc.Get("users").Index(2).Len())
+	assert.Equal(t, 7, doc.Len())
+
+	var tags []string
+	require.NoError(t, doc.Get("users").Index(1).Get("tags").Decode(&tags))
+	assert.Equal(t, []string{"x", "y"}, tags)
+
+	for _, missing := range []LazyValue{
+		doc.Get("missing"),
+		doc.Get("users").Index(4),
+		doc.Get("users").Index(-1),
+		doc.Get("id").Get("x"),
+		doc.Index(0),
+		doc.Get("missing").Index(0).Get("x"),
+	} {
+		assert.False(t, missing.Exists(), missing.Path())
+		assert.NoError(t, missing.Err())
+		assert.Equal(t, TokenUnknown, missing.Type())
+		assert.Empty(t, missing.String())
+		assert.ErrorIs(t, missing.Decode(new(any)), ErrPathNotFound)
+	}
+}
+
+func TestLazy_ScansOnlyAsFarAsNeeded(t *testing.T) {
+	// 第一个成员之后是无效的 JSON，只要不需要扫描到那里就不会出错
+	doc := Lazy([]byte(`{"skip":{"deep":[1,{"x":"]}"}]},"want":[10,20,30],"rest" oops}`))
+	assert.Equal(t, int64(20), doc.Get("want").Index(1).Int())
+	assert.Equal(t, "[10,20,30]", doc.Get("want").String())
+
+	node := doc.doc.nodes[doc.off]
+	assert.Len(t, node.offs, 2)
+	assert.False(t, node.done)
+	// 跳过的子树不建立扫描记录
+	assert.Len(t, doc.doc.nodes, 2)
+
+	// 再次查找使用已记录的偏移量
+	assert.Equal(t, "1", doc.Get("skip").Get("deep").Index(0).String())
+	assert.Len(t, node.offs, 2)
+
+	other := doc.Get("other")
+	assert.False(t, other.Exists())
+	var syntax *SyntaxError
+	require.ErrorAs(t, other.Err(), &syntax)
+	assert.Equal(t, "$", syntax.Path)
+	assert.Equal(t, other.Err(), other.Get("x").Err())
+	assert.Equal(t, other.Err(), other.Decode(new(any)))
+}
+
+func TestLazy_Errors(t *testing.T) {
+	tests := []struct {
+		input string
+		eof   bool
+	}{
+		{``, true},
+		{`  `, true},
+		{`{"a":1`, true},
+		{`{"a":1,`, true},
+		{`{"a"`, true},
+		{`{"a":"x`, true},
+		{`[1 2]`, false},
+		{`{"a" 1}`, false},
+		{`{1:2}`, false},
+		{`[1,]`, false},
+		{`{"a":1,}`, false},
+		{`[,]`, false},
+		{`[1,,2]`, false},
+		{`{"a":,"b":1}`, false},
+		{`{"a":}`, false},
+		{`{"a"::1}`, false},
+	}
+	for _, tt := range tests {
+		doc := Lazy([]byte(tt.input))
+		err := doc.Index(5).Err()
+		if doc.Type() == TokenObjectStart {
+			err = doc.Get("z").Err()
+		}
+		if tt.eof {
+			assert.ErrorIs(t, err, io.ErrUnexpectedEOF, tt.input)
+		} else {
+			var syntax *SyntaxError
+			assert.ErrorAs(t, err, &syntax, tt.input)
+		}
+	}
+}
+
+func TestLazy_MissingValue(t *testing.T) {
+	for _, v := range []LazyValue{
+		Lazy([]byte(`[,]`)).Index(0),
+		Lazy([]byte(`[1,,2]`)).Index(1),
+		Lazy([]byte(`{"a":,"b":1}`)).Get("a"),
+		Lazy([]byte(`{"a":]`)).Get("a"),
+	} {
+		assert.False(t, v.Exists(), v.Path())
+		assert.Equal(t, TokenUnknown, v.Type())
+		var syntax *SyntaxError
+		assert.ErrorAs(t, v.Err(), &syntax, v.Path())
+	}
+
+	// 出错后再次查找得到相同的错误
+	doc := Lazy([]byte(`{"a":1,"b" 2}`))
+	assert.Error(t, doc.Get("b").Err())
+	assert.Equal(t, doc.Get("b").Err(), doc.Get("c").Err())
+	assert.Equal(t, int64(1), doc.Get("a").Int())
+	node := doc.doc.nodes[doc.off]
+	assert.Len(t, node.keys, len(node.offs))
+}
+
+func TestLazy_Scalars(t *testing.T) {
+	assert.Equal(t, "s\n", Lazy([]byte(`"s\n"`)).String())
+	assert.Equal(t, int64(42), Lazy([]byte(" 42 ")).Int())
+	assert.False(t, Lazy([]byte("false")).Bool())
+	assert.Equal(t, "null", Lazy([]byte("null")).String())
+	assert.Equal(t, 0, Lazy([]byte("7")).Len())
+}
+
+func BenchmarkLazy(b *testing.B) {
+	var sb strings.Builder
+	sb.WriteString(`{"users":[`)
+	for i := range 1000 {
+		if i > 0 {
+			sb.WriteByte(',')
+		}
+		fmt.Fprintf(&sb, `{"id":%d,"name":"user %d","tags":["a","b","c"],"meta":{"x":[1,2,3]}}`, i, i)
+	}
+	sb.WriteString(`],"request_id":"r1"}`)
+	input := []byte(sb.String())
+
+	b.Run("lazy", func(b *testing.B) {
+		b.SetBytes(int64(len(input)))
+		for b.Loop() {
+			doc := Lazy(input)
+			_ = doc.Get("users").Index(3).Get("name").String()
+			_ = doc.Get("request_id").String()
+		}
+	})
+	b.Run("unmarshal", func(b *testing.B) {
+		b.SetBytes(int64(len(input)))
+		for b.Loop() {
+			var v map[string]any
+			_ = json.Unmarshal(input, &v)
+		}
+	})
+}
@@ -0,0 +1,235 @@
+package jsontokenizer
+
+import (
+	"encoding/json"
+	"maps"
+	"sync"
+	"unicode/utf8"
+)
+
+// ChangeOp is the kind of a Change.
+type ChangeOp string
+
+const (
+	// ChangeSet sets the value at Path, which is the root or an object member.
+	ChangeSet ChangeOp = "set"
+	// ChangeAppend appends a value to an array; Path is the new element's path.
+	ChangeAppend ChangeOp = "append"
+	// ChangeExtendString appends Value to the string at Path.
+	ChangeExtendString ChangeOp = "extend-string"
+)
+
+// Change describes one update to the tree of a Live. Path uses the notation
+// of Flatten, so keys that are not identifiers are quoted, as in $["a.b"].
+// Value is the new value for ChangeSet and ChangeAppend, a fresh empty map or
+// slice for objects and arrays, and the appended text for ChangeExtendString.
+type Change struct {
+	Path  string   `json:"path"`
+	Op    ChangeOp `json:"op"`
+	Value any      `json:"value"`
+}
+
+// Live maintains the value that a JSON document describes so far while it is
+// being received. Objects are map[string]any, arrays []any, numbers
+// json.Number holding their source text, and strings, booleans and null their
+// Go counterparts, as with encoding/json and UseNumber.
+//
+// Members and elements appear as soon as they start: a string grows as its
+// text arrives, while a number or literal is added once complete. Every
+// update is also reported as a Change, so a client holding a copy of the tree
+// can follow it. Snapshot may be called from other goroutines while the
+// document is fed; input that is not valid JSON is skipped.
+type Live struct {
+	mu       sync.RWMutex
+	t        *Tokenizer
+	root     any
+	stack    []liveFrame
+	inString bool // 当前字符串已有部分内容加入树中
+	complete bool
+}
+
+// liveFrame 是 Live 中一个尚未结束的容器
+type liveFrame struct {
+	obj   map[string]any
+	arr   []any
+	array bool
+	key   string // 对象中当前成员的键名
+	path  []byte // 容器自身的路径
+}
+
+// NewLive returns an empty Live.
+func NewLive() *Live {
+	t := NewTokenizer()
+	t.StringChunks()
+	return &Live{t: t}
+}
+
+// Feed adds the next part of the document and returns the resulting changes.
+func (l *Live) Feed(b []byte) []Change {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return l.apply(l.t.Feed(b))
+}
+
+// Push adds a single character of the document, as Tokenizer.Push does.
+func (l *Live) Push(r rune) []Change {
+	return l.Feed(utf8.AppendRune(nil, r))
+}
+
+// Close completes a value still pending at the end of the input, such as a
+// number at the top level, and returns the resulting changes.
+func (l *Live) Close() []Change {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return l.apply(l.t.Flush())
+}
+
+// Snapshot returns a deep copy of the current value, or nil if the document
+// has not started yet.
+func (l *Live) Snapshot() any {
+	l.mu.RLock()
+	defer l.mu.RUnlock()
+	return deepCopy(l.root)
+}
+
+// Complete reports whether the top-level value has been fully received.
+func (l *Live) Complete() bool {
+	l.mu.RLock()
+	defer l.mu.RUnlock()
+	return l.complete
+}
+
+// apply 根据Token更新树并返回变更
+func (l *Live) apply(tokens []Token) []Change {
+	var changes []Change
+	for _, tk := range tokens {
+		if l.complete {
+			break
+		}
+		switch tk.Type {
+		case TokenObjectStart:
+			obj := map[string]any{}
+			c := l.add(obj)
+			changes = append(changes, c)
+			l.stack = append(l.stack, liveFrame{obj: obj, path: []byte(c.Path)})
+		case TokenArrayStart:
+			arr := []any{}
+			c := l.add(arr)
+			changes = append(changes, c)
+			l.stack = append(l.stack, liveFrame{arr: arr, array: true, path: []byte(c.Path)})
+		case TokenObjectEnd, TokenArrayEnd:
+			if len(l.stack) == 0 {
+				continue
+			}
+			l.stack = l.stack[:len(l.stack)-1]
+			l.complete = len(l.stack) == 0
+		case TokenKey:
+			if n := len(l.stack); n > 0 && !l.stack[n-1].array {
+				l.stack[n-1].key = tk.Val
+			}
+		case TokenStringChunk, TokenString:
+			if l.inString {
+				l.extend(tk.Val)
+				changes = append(changes, Change{Path: l.path(true), Op: ChangeExtendString, Value: tk.Val})
+			} else {
+				changes = append(changes, l.add(tk.Val))
+			}
+			l.inString = tk.Type == TokenStringChunk
+			l.complete = tk.Type == TokenString && len(l.stack) == 0
+		case TokenNumber:
+			changes = append(changes, l.add(json.Number(tk.Val)))
+			l.complete = len(l.stack) == 0
+		case TokenBoolean:
+			changes = append(changes, l.add(tk.Val == "true"))
+			l.complete = len(l.stack) == 0
+		case TokenNull:
+			changes = append(changes, l.add(nil))
+			l.complete = len(l.stack) == 0
+		case TokenUnknown, TokenStringEscape, TokenKeyEscape, TokenComma, TokenColon, TokenQuote, TokenWhitespace:
+		}
+	}
+	return changes
+}
+
+// add 将新值放入当前容器，返回对应的变更
+func (l *Live) add(v any) Change {
+	c := Change{Path: l.path(false), Op: ChangeSet, Value: deepCopy(v)}
+	n := len(l.stack)
+	switch {
+	case n == 0:
+		l.root = v
+	case l.stack[n-1].array:
+		l.stack[n-1].arr = append(l.stack[n-1].arr, v)
+		l.store(n - 1)
+		c.Op = ChangeAppend
+	default:
+		l.stack[n-1].obj[l.stack[n-1].key] = v
+	}
+	return c
+}
+
+// path 返回当前容器中下一个值的路径，last 为 true 时返回最后一个已有值的路径
+func (l *Live) path(last bool) string {
+	n := len(l.stack)
+	if n == 0 {
+		return "$"
+	}
+	f := &l.stack[n-1]
+	if !f.array {
+		return string(appendKeySegment(f.path, f.key))
+	}
+	i := len(f.arr)
+	if last {
+		i--
+	}
+	return string(appendIndexSegment(f.path, i))
+}
+
+// extend 在当前字符串值后追加文本
+func (l *Live) extend(s string) {
+	n := len(l.stack)
+	switch {
+	case n == 0:
+		l.root = l.root.(string) + s
+	case l.stack[n-1].array:
+		f := &l.stack[n-1]
+		f.arr[len(f.arr)-1] = f.arr[len(f.arr)-1].(string) + s
+	default:
+		f := &l.stack[n-1]
+		f.obj[f.key] = f.obj[f.key].(string) + s
+	}
+}
+
+// store 将第 i 层数组的切片写回其父容器，切片扩容后父容器中的旧切片不再有效
+func (l *Live) store(i int) {
+	arr := l.stack[i].arr
+	if i == 0 {
+		l.root = arr
+		return
+	}
+	parent := &l.stack[i-1]
+	if parent.array {
+		parent.arr[len(parent.arr)-1] = arr
+	} else {
+		parent.obj[parent.key] = arr
+	}
+}
+
+// deepCopy 复制 Live 树中的值
+func deepCopy(v any) any {
+	switch v := v.(type) {
+	case map[string]any:
+		m := maps.Clone(v)
+		for k, c := range m {
+			m[k] = deepCopy(c)
+		}
+		return m
+	case []any:
+		s := make([]any, len(v))
+		for i, c := range v {
+			s[i] = deepCopy(c)
+		}
+		return s
+	}
+	return v
+}
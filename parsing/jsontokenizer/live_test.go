@@ -0,0 +1,154 @@
+package jsontokenizer
+
+import (
+	"encoding/json"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestLive(t *testing.T) {
+	l := NewLive()
+	var changes []Change
+	for _, chunk := range []string{`{"title": "Hel`, `lo", "tags": ["a`, `", 1.50`, `, {"x": [tr`, `ue, null]}], "n": 7}`} {
+		changes = append(changes, l.Feed([]byte(chunk))...)
+		if chunk == `{"title": "Hel` {
+			assert.Equal(t, map[string]any{"title": "Hel"}, l.Snapshot())
+			assert.False(t, l.Complete())
+		}
+	}
+	assert.True(t, l.Complete())
+	assert.Empty(t, l.Close())
+
+	assert.Equal(t, []Change{
+		{Path: "$", Op: ChangeSet, Value: map[string]any{}},
+		{Path: "$.title", Op: ChangeSet, Value: "Hel"},
+		{Path: "$.title", Op: ChangeExtendString, Value: "lo"},
+		{Path: "$.tags", Op: ChangeSet, Value: []any{}},
+		{Path: "$.tags[0]", Op: ChangeAppend, Value: "a"},
+		{Path: "$.tags[0]", Op: ChangeExtendString, Value: ""},
+		{Path: "$.tags[1]", Op: ChangeAppend, Value: json.Number("1.50")},
+		{Path: "$.tags[2]", Op: ChangeAppend, Value: map[string]any{}},
+		{Path: "$.tags[2].x", Op: ChangeSet, Value: []any{}},
+		{Path: "$.tags[2].x[0]", Op: ChangeAppend, Value: true},
+		{Path: "$.tags[2].x[1]", Op: ChangeAppend, Value: nil},
+		{Path: "$.n", Op: ChangeSet, Value: json.Number("7")},
+	}, changes)
+
+	expected := map[string]any{
+		"title": "Hello",
+		"tags":  []any{"a", json.Number("1.50"), map[string]any{"x": []any{true, nil}}},
+		"n":     json.Number("7"),
+	}
+	assert.Equal(t, expected, l.Snapshot())
+
+	// 快照是副本，修改它不影响 Live
+	snap := l.Snapshot().(map[string]any)
+	snap["title"] = "changed"
+	assert.Equal(t, expected, l.Snapshot())
+}
+
+func TestLive_QuotedPaths(t *testing.T) {
+	l := NewLive()
+	changes := l.Feed([]byte(`{"a.b":1,"a":{"b":1},"":["x`))
+	changes = append(changes, l.Feed([]byte(`y"]}`))...)
+	assert.Equal(t, []Change{
+		{Path: "$", Op: ChangeSet, Value: map[string]any{}},
+		{Path: `$["a.b"]`, Op: ChangeSet, Value: json.Number("1")},
+		{Path: "$.a", Op: ChangeSet, Value: map[string]any{}},
+		{Path: "$.a.b", Op: ChangeSet, Value: json.Number("1")},
+		{Path: `$[""]`, Op: ChangeSet, Value: []any{}},
+		{Path: `$[""][0]`, Op: ChangeAppend, Value: "x"},
+		{Path: `$[""][0]`, Op: ChangeExtendString, Value: "y"},
+	}, changes)
+}
+
+func TestLive_RootScalarsAndPush(t *testing.T) {
+	l := NewLive()
+	for _, r := range `"a\"b"` {
+		l.Push(r)
+	}
+	assert.Equal(t, `a"b`, l.Snapshot())
+	assert.True(t, l.Complete())
+
+	l = NewLive()
+	assert.Nil(t, l.Snapshot())
+	assert.Empty(t, l.Feed([]byte("-12")))
+	assert.Equal(t, []Change{{Path: "$", Op: ChangeSet, Value: json.Number("-12")}}, l.Close())
+	assert.True(t, l.Complete())
+}
+
+// 将变更依次应用到副本上，结果应与 Live 的快照一致
+func TestLive_ChangesReplay(t *testing.T) {
+	doc := `{"a":[[1,"xy"],{"b":"ü"}],"c":"d","e.f":{"":"g","[h]":["i"]}}`
+	l := NewLive()
+	var replica any
+	for i := range len(doc) {
+		for _, c := range l.Feed([]byte(doc[i : i+1])) {
+			replica = replayChange(t, replica, c)
+		}
+	}
+	var expected any
+	dec := json.NewDecoder(strings.NewReader(doc))
+	dec.UseNumber()
+	require.NoError(t, dec.Decode(&expected))
+	assert.Equal(t, expected, replica)
+	assert.Equal(t, l.Snapshot(), replica)
+}
+
+func replayChange(t *testing.T, root any, c Change) any {
+	t.Helper()
+	segs, _, err := parsePath(c.Path)
+	require.NoError(t, err)
+	if len(segs) == 0 {
+		if c.Op == ChangeExtendString {
+			return root.(string) + c.Value.(string)
+		}
+		return c.Value
+	}
+	// 找到父容器，再修改最后一段
+	var set func(v any, segs []pathSegment) any
+	set = func(v any, segs []pathSegment) any {
+		seg := segs[0]
+		if len(segs) > 1 {
+			if seg.isIndex {
+				v.([]any)[seg.index] = set(v.([]any)[seg.index], segs[1:])
+			} else {
+				v.(map[string]any)[seg.key] = set(v.(map[string]any)[seg.key], segs[1:])
+			}
+			return v
+		}
+		switch {
+		case c.Op == ChangeAppend:
+			return append(v.([]any), c.Value)
+		case c.Op == ChangeExtendString && seg.isIndex:
+			v.([]any)[seg.index] = v.([]any)[seg.index].(string) + c.Value.(string)
+		case c.Op == ChangeExtendString:
+			v.(map[string]any)[seg.key] = v.(map[string]any)[seg.key].(string) + c.Value.(string)
+		default:
+			v.(map[string]any)[seg.key] = c.Value
+		}
+		return v
+	}
+	return set(root, segs)
+}
+
+func TestLive_ConcurrentSnapshot(t *testing.T) {
+	l := NewLive()
+	var wg sync.WaitGroup
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		for range 100 {
+			_ = l.Snapshot()
+		}
+	}()
+	for _, c := range `{"a":[1,2,3],"b":"text"}` {
+		l.Push(c)
+	}
+	wg.Wait()
+	assert.True(t, l.Complete())
+}
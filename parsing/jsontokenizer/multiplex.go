@@ -0,0 +1,206 @@
+package jsontokenizer
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"maps"
+	"slices"
+	"sync"
+)
+
+var (
+	// ErrTooManyStreams is returned by Multiplexer.Feed when a new stream
+	// would exceed MultiplexerOptions.MaxStreams.
+	ErrTooManyStreams = errors.New("jsontokenizer: too many open streams")
+	// ErrStreamTooLarge is returned by Multiplexer.Feed when a stream exceeds
+	// MultiplexerOptions.MaxBytes.
+	ErrStreamTooLarge = errors.New("jsontokenizer: stream exceeds size limit")
+)
+
+// MultiplexerOptions configures a Multiplexer. Zero values mean no limit.
+type MultiplexerOptions struct {
+	// MaxStreams caps the number of streams open at the same time. A stream
+	// is open from its first fragment until its value is complete, it fails
+	// or it is closed.
+	MaxStreams int
+	// MaxBytes caps the input of each stream. The memory a tokenizer holds
+	// grows with its input at most, so this also bounds it per stream.
+	MaxBytes int
+}
+
+// Multiplexer tokenizes several JSON documents that arrive interleaved, such
+// as the arguments of parallel tool calls, each identified by a stream key.
+// Every stream has its own Tokenizer in coalesced mode with string chunks,
+// as set up by Tokenizer.StringChunks.
+//
+// A stream is complete once its top-level value ends; Done reports it, and
+// whitespace may still follow. Characters the tokenizer cannot place,
+// mismatched brackets, a second value and exceeding MaxBytes make the stream
+// fail: Feed returns the error, as does every later Feed or Close for the
+// key. The grammar is not otherwise validated. Complete and failed streams
+// release their tokenizer but are remembered until Close.
+//
+// A Multiplexer is safe for concurrent use.
+type Multiplexer struct {
+	mu      sync.Mutex
+	opts    MultiplexerOptions
+	streams map[string]*muxStream
+	open    int
+}
+
+// muxStream 是 Multiplexer 中的一个流
+type muxStream struct {
+	t     *Tokenizer  // 流结束或出错后为 nil
+	stack []TokenType // 未结束的容器的起始Token类型
+	size  int         // 已接收的字节数
+	done  bool        // 顶层值已经结束
+	err   error
+}
+
+// NewMultiplexer returns an empty Multiplexer.
+func NewMultiplexer(opts MultiplexerOptions) *Multiplexer {
+	return &Multiplexer{opts: opts, streams: make(map[string]*muxStream)}
+}
+
+// Feed adds the next fragment of the stream key, opening the stream if it is
+// new, and returns the tokens produced. On error the tokens before the
+// offending input are still returned.
+func (m *Multiplexer) Feed(key string, b []byte) ([]Token, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	s, ok := m.streams[key]
+	if !ok {
+		if m.opts.MaxStreams > 0 && m.open >= m.opts.MaxStreams {
+			return nil, fmt.Errorf("%w: stream %q", ErrTooManyStreams, key)
+		}
+		t := NewTokenizer()
+		t.StringChunks()
+		s = &muxStream{t: t}
+		m.streams[key] = s
+		m.open++
+	}
+	if s.err != nil {
+		return nil, s.err
+	}
+
+	s.size += len(b)
+	if m.opts.MaxBytes > 0 && s.size > m.opts.MaxBytes {
+		return nil, m.fail(s, fmt.Errorf("%w: stream %q", ErrStreamTooLarge, key))
+	}
+	if s.done {
+		// 值结束后只允许空白
+		for _, c := range b {
+			if !isWhitespace(rune(c)) {
+				return nil, m.fail(s, fmt.Errorf("jsontokenizer: stream %q: unexpected %q after value", key, c))
+			}
+		}
+		return nil, nil
+	}
+	// Feed 的结果会被下一次调用覆盖，复制后再返回给可能并发的调用方
+	return m.check(key, s, slices.Clone(s.t.Feed(b)))
+}
+
+// Close ends the stream key and forgets it. It returns the tokens still
+// pending, such as a trailing number, and an error wrapping
+// io.ErrUnexpectedEOF if the value is incomplete, or the error the stream
+// failed with.
+func (m *Multiplexer) Close(key string) ([]Token, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	s, ok := m.streams[key]
+	if !ok {
+		return nil, fmt.Errorf("jsontokenizer: stream %q: %w", key, io.ErrUnexpectedEOF)
+	}
+	defer delete(m.streams, key)
+	if s.err != nil || s.done {
+		return nil, s.err
+	}
+	tokens, err := m.check(key, s, s.t.Flush())
+	if err == nil && !s.done {
+		err = m.fail(s, fmt.Errorf("jsontokenizer: stream %q: %w", key, io.ErrUnexpectedEOF))
+	}
+	return tokens, err
+}
+
+// Done reports whether the value of the stream key is complete.
+func (m *Multiplexer) Done(key string) bool {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	s, ok := m.streams[key]
+	return ok && s.done && s.err == nil
+}
+
+// Err returns the error the stream key failed with, or nil.
+func (m *Multiplexer) Err(key string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if s, ok := m.streams[key]; ok {
+		return s.err
+	}
+	return nil
+}
+
+// Open returns the keys of the open streams in sorted order.
+func (m *Multiplexer) Open() []string {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	var keys []string
+	for _, key := range slices.Sorted(maps.Keys(m.streams)) {
+		if m.streams[key].t != nil {
+			keys = append(keys, key)
+		}
+	}
+	return keys
+}
+
+// check 跟踪容器的嵌套以判断值是否结束，遇到错误时截断Token并使流失败
+func (m *Multiplexer) check(key string, s *muxStream, tokens []Token) ([]Token, error) {
+	for i, tk := range tokens {
+		if s.done && tk.Type != TokenWhitespace {
+			return tokens[:i], m.fail(s, fmt.Errorf("jsontokenizer: stream %q: unexpected %q after value at %s", key, tk.Val, tk.Path))
+		}
+		switch tk.Type {
+		case TokenUnknown:
+			return tokens[:i], m.fail(s, fmt.Errorf("jsontokenizer: stream %q: unexpected %q at %s", key, tk.Val, tk.Path))
+		case TokenObjectStart, TokenArrayStart:
+			s.stack = append(s.stack, tk.Type)
+		case TokenObjectEnd, TokenArrayEnd:
+			want := TokenObjectStart
+			if tk.Type == TokenArrayEnd {
+				want = TokenArrayStart
+			}
+			if len(s.stack) == 0 || s.stack[len(s.stack)-1] != want {
+				return tokens[:i], m.fail(s, fmt.Errorf("jsontokenizer: stream %q: unexpected %q at %s", key, tk.Val, tk.Path))
+			}
+			s.stack = s.stack[:len(s.stack)-1]
+			s.done = len(s.stack) == 0
+		case TokenString, TokenNumber, TokenBoolean, TokenNull:
+			s.done = len(s.stack) == 0
+		case TokenStringEscape, TokenKey, TokenKeyEscape, TokenComma, TokenColon, TokenQuote, TokenWhitespace,
+			TokenStringChunk:
+		}
+	}
+	if s.done && s.t != nil {
+		m.release(s)
+	}
+	return tokens, nil
+}
+
+// fail 记录流的错误并释放其分词器
+func (m *Multiplexer) fail(s *muxStream, err error) error {
+	s.err = err
+	if s.t != nil {
+		m.release(s)
+	}
+	return err
+}
+
+// release 释放流的分词器，使其不再计入打开的流
+func (m *Multiplexer) release(s *muxStream) {
+	s.t = nil
+	s.stack = nil
+	m.open--
+}
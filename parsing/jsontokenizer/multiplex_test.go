@@ -0,0 +1,174 @@
+package jsontokenizer
+
+import (
+	"fmt"
+	"io"
+	"sync"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// muxValues 将Token格式化为 "类型 路径 值"，忽略结构性Token
+func muxValues(tokens []Token) []string {
+	var out []string
+	for _, tk := range tokens {
+		if isValueToken(tk.Type) {
+			out = append(out, fmt.Sprintf("%s %s %s", tk.Type, tk.Path, tk.Val))
+		}
+	}
+	return out
+}
+
+func TestMultiplexer_Interleaved(t *testing.T) {
+	m := NewMultiplexer(MultiplexerOptions{})
+
+	tokens, err := m.Feed("call_a", []byte(`{"city": "Pa`))
+	require.NoError(t, err)
+	assert.Equal(t, []string{"StringChunk $.city Pa"}, muxValues(tokens))
+
+	tokens, err = m.Feed("call_b", []byte(`{"n": [1, 2`))
+	require.NoError(t, err)
+	assert.Equal(t, []string{"Number $.n[0] 1"}, muxValues(tokens))
+	assert.Equal(t, []string{"call_a", "call_b"}, m.Open())
+
+	tokens, err = m.Feed("call_a", []byte(`ris"} `))
+	require.NoError(t, err)
+	assert.Equal(t, []string{"String $.city ris"}, muxValues(tokens))
+	assert.True(t, m.Done("call_a"))
+	assert.Equal(t, []string{"call_b"}, m.Open())
+
+	tokens, err = m.Feed("call_a", []byte("\n"))
+	require.NoError(t, err)
+	assert.Empty(t, tokens)
+
+	tokens, err = m.Feed("call_b", []byte(`]}`))
+	require.NoError(t, err)
+	assert.Equal(t, []string{"Number $.n[1] 2"}, muxValues(tokens))
+	assert.True(t, m.Done("call_b"))
+	assert.Empty(t, m.Open())
+
+	for _, key := range []string{"call_a", "call_b"} {
+		tokens, err = m.Close(key)
+		require.NoError(t, err)
+		assert.Empty(t, tokens)
+		assert.False(t, m.Done(key))
+	}
+}
+
+func TestMultiplexer_Close(t *testing.T) {
+	m := NewMultiplexer(MultiplexerOptions{})
+
+	_, err := m.Feed("n", []byte(`-12.5`))
+	require.NoError(t, err)
+	assert.False(t, m.Done("n"))
+	tokens, err := m.Close("n")
+	require.NoError(t, err)
+	assert.Equal(t, []string{"Number $ -12.5"}, muxValues(tokens))
+
+	_, err = m.Feed("partial", []byte(`{"a": tr`))
+	require.NoError(t, err)
+	_, err = m.Close("partial")
+	assert.ErrorIs(t, err, io.ErrUnexpectedEOF)
+
+	_, err = m.Close("unknown")
+	assert.ErrorIs(t, err, io.ErrUnexpectedEOF)
+}
+
+func TestMultiplexer_Errors(t *testing.T) {
+	tests := []struct {
+		name   string
+		chunks []string
+		values []string // 最后一个片段返回的值
+		errMsg string
+	}{
+		{"mismatched bracket", []string{`{"a": [1}`}, []string{"Number $.a[0] 1"}, `stream "k": unexpected "}" at $.a`},
+		{"stray end", []string{`]`}, nil, `stream "k": unexpected "]" at $`},
+		{"unknown character", []string{`{"a": `, `?}`}, nil, `stream "k": unexpected "?" at $.a`},
+		{"second value", []string{`{} {"b": 1}`}, nil, `stream "k": unexpected "{" after value at $`},
+		{"data after value", []string{`"s"`, ` x`}, nil, `stream "k": unexpected 'x' after value`},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			m := NewMultiplexer(MultiplexerOptions{})
+			var tokens []Token
+			var err error
+			for _, c := range tt.chunks {
+				tokens, err = m.Feed("k", []byte(c))
+			}
+			require.Error(t, err)
+			assert.Contains(t, err.Error(), tt.errMsg)
+			assert.Equal(t, tt.values, muxValues(tokens))
+
+			// 错误会被记住
+			assert.Equal(t, err, m.Err("k"))
+			_, again := m.Feed("k", []byte(`1`))
+			assert.Equal(t, err, again)
+			assert.False(t, m.Done("k"))
+			assert.Empty(t, m.Open())
+			_, again = m.Close("k")
+			assert.Equal(t, err, again)
+			assert.NoError(t, m.Err("k"))
+		})
+	}
+}
+
+func TestMultiplexer_Limits(t *testing.T) {
+	m := NewMultiplexer(MultiplexerOptions{MaxStreams: 2, MaxBytes: 8})
+
+	_, err := m.Feed("a", []byte(`[1,`))
+	require.NoError(t, err)
+	_, err = m.Feed("b", []byte(`{}`))
+	require.NoError(t, err)
+	_, err = m.Feed("c", []byte(`[`))
+	require.NoError(t, err, "b is complete and no longer counts as open")
+	_, err = m.Feed("d", []byte(`[`))
+	assert.ErrorIs(t, err, ErrTooManyStreams)
+	assert.NoError(t, m.Err("d"))
+
+	_, err = m.Feed("a", []byte(`2,3,4`))
+	require.NoError(t, err)
+	_, err = m.Feed("a", []byte(`,5]`))
+	assert.ErrorIs(t, err, ErrStreamTooLarge)
+	assert.ErrorIs(t, m.Err("a"), ErrStreamTooLarge)
+
+	_, err = m.Feed("d", []byte(`[`))
+	require.NoError(t, err, "a failed and released its slot")
+	assert.Equal(t, []string{"c", "d"}, m.Open())
+}
+
+func TestMultiplexer_Concurrent(t *testing.T) {
+	m := NewMultiplexer(MultiplexerOptions{MaxStreams: 8})
+	doc := []byte(`{"id": 1, "tags": ["x", "y"], "ok": true}`)
+
+	// 每个流的结果应与单独使用分词器逐字节输入时相同
+	single := NewTokenizer()
+	single.StringChunks()
+	var want []string
+	for j := range doc {
+		want = append(want, muxValues(single.Feed(doc[j:j+1]))...)
+	}
+
+	var wg sync.WaitGroup
+	results := make([][]string, 8)
+	for i := range results {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			key := fmt.Sprint("call_", i)
+			for j := range doc {
+				tokens, err := m.Feed(key, doc[j:j+1])
+				assert.NoError(t, err)
+				results[i] = append(results[i], muxValues(tokens)...)
+			}
+			assert.True(t, m.Done(key))
+		}()
+	}
+	wg.Wait()
+
+	for _, r := range results {
+		assert.Equal(t, want, r)
+	}
+	assert.Empty(t, m.Open())
+}
@@ -0,0 +1,420 @@
+package jsontokenizer
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"runtime"
+	"slices"
+)
+
+// DefaultParallelChunk is the size of the groups of array elements handed to
+// a worker if ParallelOptions.ChunkSize is zero.
+const DefaultParallelChunk = 256 << 10
+
+// ParallelOptions configures TokenizeParallel and DecodeParallel.
+type ParallelOptions struct {
+	// Path is the array whose elements are processed in parallel, such as
+	// $.items, "$" if empty. Keys are compared with their text in the input.
+	Path string
+	// Workers is the number of goroutines processing elements,
+	// runtime.GOMAXPROCS(0) if zero.
+	Workers int
+	// ChunkSize is the approximate number of bytes of consecutive elements
+	// processed as a unit, DefaultParallelChunk if zero.
+	ChunkSize int
+}
+
+// TokenizeParallel tokenizes the JSON read from r like a Tokenizer in
+// coalesced mode, but splits the elements of the array at opts.Path across
+// worker goroutines. A fast structural pre-scan, which only tracks strings,
+// escapes and nesting, finds the boundaries of the elements; groups of
+// elements are then tokenized concurrently, each by a Tokenizer resumed in
+// the state a sequential scan would have at its first element.
+//
+// fn receives the tokens in input order, in batches of arbitrary size, from
+// the calling goroutine. They are exactly the tokens a sequential Tokenizer
+// with Coalesce would report, paths such as $.items[12345].name included.
+// Memory is bounded by a few chunks per worker. If there is no array at
+// opts.Path the input is tokenized sequentially. Like Tokenizer,
+// TokenizeParallel does not validate the input, but it reports
+// io.ErrUnexpectedEOF if the array is not closed.
+func TokenizeParallel(r io.Reader, opts ParallelOptions, fn func([]Token) error) error {
+	return runParallel(r, opts, true, func(c *arrayChunk) parallelResult {
+		t := NewTokenizer()
+		t.Coalesce()
+		t.inner.stack = c.stack
+		t.inner.pathCacheDirty = true
+		return parallelResult{tokens: append(t.Feed(c.data), t.Flush()...)}
+	}, func(res parallelResult) error {
+		if len(res.tokens) == 0 {
+			return nil
+		}
+		return fn(res.tokens)
+	})
+}
+
+// DecodeParallel decodes the elements of the array at opts.Path in the JSON
+// read from r on worker goroutines, each with json.Unmarshal into a value
+// returned by newValue, and calls fn from the calling goroutine with every
+// element's index and value, in order. Elements are found as by
+// TokenizeParallel; the rest of the input is skipped without validation.
+func DecodeParallel(r io.Reader, opts ParallelOptions, newValue func() any, fn func(index int, v any) error) error {
+	return runParallel(r, opts, false, func(c *arrayChunk) parallelResult {
+		var res parallelResult
+		for i, start := range c.starts {
+			end := len(c.data)
+			if i+1 < len(c.starts) {
+				end = c.starts[i+1]
+			}
+			// 去掉元素之后的逗号和空白
+			elem := bytes.TrimRight(bytes.TrimRight(c.data[start:end], " \t\r\n"), ",")
+			v := newValue()
+			if err := json.Unmarshal(elem, v); err != nil {
+				res.err = fmt.Errorf("jsontokenizer: element %d: %w", c.first+i, err)
+				break
+			}
+			res.values = append(res.values, v)
+		}
+		res.first = c.first
+		return res
+	}, func(res parallelResult) error {
+		for i, v := range res.values {
+			if err := fn(res.first+i, v); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
+
+// arrayChunk 是交给工作协程的一组连续元素
+type arrayChunk struct {
+	first  int         // 第一个元素的下标
+	data   []byte      // 从第一个元素开头到下一组开头之前的文本
+	starts []int       // 各元素在 data 中的起始位置
+	stack  []container // 顺序扫描到第一个元素时的容器栈
+}
+
+// parallelResult 是一段输入的处理结果
+type parallelResult struct {
+	tokens []Token
+	first  int
+	values []any
+	err    error
+}
+
+// parallelJob 是一个待处理的分组及其结果的去向
+type parallelJob struct {
+	chunk *arrayChunk
+	out   chan parallelResult
+}
+
+// runParallel 在调用者的协程中按顺序交付结果，另起协程读取和切分输入，
+// 由工作协程执行 work。tokens 表示是否需要数组之外的Token
+func runParallel(r io.Reader, opts ParallelOptions, tokens bool, work func(*arrayChunk) parallelResult,
+	deliver func(parallelResult) error) error {
+	if opts.Path == "" {
+		opts.Path = "$"
+	}
+	if opts.Workers <= 0 {
+		opts.Workers = runtime.GOMAXPROCS(0)
+	}
+	if opts.ChunkSize <= 0 {
+		opts.ChunkSize = DefaultParallelChunk
+	}
+	segs, rest, err := parsePath(opts.Path)
+	if err == nil && rest != "" {
+		err = fmt.Errorf("invalid segment %q", rest)
+	}
+	if err != nil {
+		return fmt.Errorf("jsontokenizer: path %q: %w", opts.Path, err)
+	}
+
+	done := make(chan struct{})
+	jobs := make(chan parallelJob)
+	queue := make(chan chan parallelResult, 2*opts.Workers)
+	for range opts.Workers {
+		go func() {
+			// 读取协程关闭 jobs 后退出
+			for job := range jobs {
+				job.out <- work(job.chunk)
+			}
+		}()
+	}
+	s := &arraySplitter{
+		r: r, target: tokenizerPath(segs), chunkSize: opts.ChunkSize, tokens: tokens,
+		jobs: jobs, queue: queue, done: done,
+	}
+	go s.run()
+	defer close(done)
+
+	for out := range queue {
+		res := <-out
+		if res.err != nil {
+			return res.err
+		}
+		if err := deliver(res); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// tokenizerPath 返回 Tokenizer 报告的路径形式
+func tokenizerPath(segs []pathSegment) string {
+	path := []byte{'$'}
+	for _, seg := range segs {
+		if seg.isIndex {
+			path = appendIndexSegment(path, seg.index)
+		} else {
+			path = appendPathKey(path, []byte(seg.key))
+		}
+	}
+	return string(path)
+}
+
+// arraySplitter 读取输入，在目标数组之前和之后顺序分词，
+// 并将数组元素切分为分组交给工作协程
+type arraySplitter struct {
+	r         io.Reader
+	target    string
+	chunkSize int
+	tokens    bool
+
+	jobs  chan<- parallelJob
+	queue chan<- chan parallelResult
+	done  <-chan struct{}
+
+	t   *Tokenizer // 数组之前和之后的顺序分词
+	buf []byte     // 尚未处理的输入
+	eof bool
+}
+
+// run 执行切分，结束时关闭 jobs 和 queue
+func (s *arraySplitter) run() {
+	defer close(s.queue)
+	defer close(s.jobs)
+	if err := s.split(); err != nil {
+		s.emit(parallelResult{err: err})
+	}
+}
+
+// fill 读取更多输入，返回是否读到了数据
+func (s *arraySplitter) fill() (bool, error) {
+	if s.eof {
+		return false, nil
+	}
+	if len(s.buf) == cap(s.buf) {
+		// 扩容时不复用旧的底层数组，工作协程可能仍在使用它
+		s.buf = append(make([]byte, 0, max(2*len(s.buf), s.chunkSize, 64<<10)), s.buf...)
+	}
+	n, err := s.r.Read(s.buf[len(s.buf):cap(s.buf)])
+	s.buf = s.buf[:len(s.buf)+n]
+	if errors.Is(err, io.EOF) {
+		s.eof = true
+		err = nil
+	}
+	return n > 0 || !s.eof, err
+}
+
+// emit 将已完成的结果加入交付队列，返回 false 表示已取消
+func (s *arraySplitter) emit(res parallelResult) bool {
+	out := make(chan parallelResult, 1)
+	out <- res
+	select {
+	case s.queue <- out:
+		return true
+	case <-s.done:
+		return false
+	}
+}
+
+// sequential 顺序分词 b 并交付Token
+func (s *arraySplitter) sequential(b []byte, flush bool) bool {
+	if !s.tokens {
+		// 只需要跟踪状态以找到数组
+		for range s.t.Feed(b) {
+		}
+		return true
+	}
+	// Feed 的结果由其他协程交付，必须复制
+	tokens := slices.Clone(s.t.Feed(b))
+	if flush {
+		tokens = append(tokens, s.t.Flush()...)
+	}
+	return len(tokens) == 0 || s.emit(parallelResult{tokens: tokens})
+}
+
+// split 找到目标数组，切分其元素，再处理数组之后的输入
+func (s *arraySplitter) split() error {
+	s.t = NewTokenizer()
+	s.t.Coalesce()
+
+	// 在每个 [ 之后暂停顺序分词，检查是否到达目标数组
+	found := false
+	for !found {
+		i := bytes.IndexByte(s.buf, '[')
+		if i < 0 {
+			if !s.sequential(s.buf, false) {
+				return nil
+			}
+			s.buf = s.buf[:0]
+			more, err := s.fill()
+			if err != nil {
+				return err
+			}
+			if !more {
+				s.sequential(nil, true)
+				return nil
+			}
+			continue
+		}
+		out := s.t.Feed(s.buf[:i+1])
+		// 字符串或键名中的 [ 不产生Token
+		if n := len(out); n > 0 && !s.t.incomplete() {
+			found = out[n-1].Type == TokenArrayStart && out[n-1].Path == s.target
+		}
+		if s.tokens && !s.emit(parallelResult{tokens: slices.Clone(out)}) {
+			return nil
+		}
+		s.buf = s.buf[i+1:]
+	}
+	return s.elements()
+}
+
+// elements 从数组的 [ 之后切分元素，直到对应的 ]
+func (s *arraySplitter) elements() error {
+	var (
+		depth      int  // 元素内部的嵌套深度
+		inStr, esc bool // 是否在字符串中，下一个字符是否被转义
+		expect     = true
+		index      = -1 // 最后一个开始的元素的下标
+		chunk      *arrayChunk
+		chunkStart int // 当前分组在 buf 中的起始位置
+		stack      []container
+	)
+	for pos := 0; ; {
+		for ; pos < len(s.buf); pos++ {
+			c := s.buf[pos]
+			if inStr {
+				switch {
+				case esc:
+					esc = false
+				case c == '\\':
+					esc = true
+				case c == '"':
+					inStr = false
+				}
+				continue
+			}
+			top := depth == 0
+			switch c {
+			case ' ', '\t', '\n', '\r':
+				continue
+			case ',':
+				expect = expect || top
+				continue
+			case ']', '}':
+				if top {
+					return s.finish(chunk, chunkStart, pos, index)
+				}
+				depth--
+				continue
+			case '"':
+				inStr = true
+			case '[', '{':
+				depth++
+			}
+			if !top || !expect {
+				continue
+			}
+
+			// 新元素的开头，当前分组足够大时从这里开始新的分组
+			expect = false
+			index++
+			switch {
+			case chunk == nil:
+				// 第一个元素之前的空白属于顺序部分
+				if !s.sequential(s.buf[:pos], true) {
+					return nil
+				}
+				stack = slices.Clone(s.t.inner.stack)
+			case pos-chunkStart < s.chunkSize:
+				chunk.starts = append(chunk.starts, pos-chunkStart)
+				continue
+			case !s.dispatch(chunk, s.buf[chunkStart:pos:pos]):
+				return nil
+			}
+			chunk = &arrayChunk{first: index, starts: []int{0}, stack: slices.Clone(stack)}
+			chunk.stack[len(chunk.stack)-1].ArrayIndex = index
+			chunkStart = pos
+		}
+
+		// 丢弃已经处理的部分，保留当前分组
+		keep := chunkStart
+		if chunk == nil {
+			if !s.sequential(s.buf[:pos], false) {
+				return nil
+			}
+			keep = pos
+		}
+		s.buf, pos, chunkStart = s.buf[keep:], pos-keep, 0
+		more, err := s.fill()
+		if err != nil {
+			return err
+		}
+		if !more {
+			return fmt.Errorf("jsontokenizer: parallel: %w", io.ErrUnexpectedEOF)
+		}
+	}
+}
+
+// dispatch 将分组交给工作协程并将其结果加入交付队列，返回 false 表示已取消
+func (s *arraySplitter) dispatch(chunk *arrayChunk, data []byte) bool {
+	chunk.data = data
+	job := parallelJob{chunk: chunk, out: make(chan parallelResult, 1)}
+	select {
+	case s.jobs <- job:
+	case <-s.done:
+		return false
+	}
+	select {
+	case s.queue <- job.out:
+		return true
+	case <-s.done:
+		return false
+	}
+}
+
+// finish 交付最后一个分组，并顺序处理从 ] 开始的剩余输入
+func (s *arraySplitter) finish(chunk *arrayChunk, chunkStart, end, last int) error {
+	if chunk != nil {
+		if !s.dispatch(chunk, s.buf[chunkStart:end:end]) {
+			return nil
+		}
+		s.t.inner.stack[len(s.t.inner.stack)-1].ArrayIndex = last
+		s.t.inner.pathCacheDirty = true
+		s.buf = s.buf[end:]
+	}
+	if !s.tokens {
+		return nil
+	}
+	for {
+		if !s.sequential(s.buf, false) {
+			return nil
+		}
+		// buf 从已分出的分组之后开始，复用它不会覆盖工作协程使用的数据
+		s.buf = s.buf[:0]
+		more, err := s.fill()
+		if err != nil {
+			return err
+		}
+		if !more {
+			s.sequential(nil, true)
+			return nil
+		}
+	}
+}
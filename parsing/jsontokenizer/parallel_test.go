@@ -0,0 +1,188 @@
+package jsontokenizer
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+	"testing"
+	"testing/iotest"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// sequentialTokens 返回合并模式下顺序分词的结果
+func sequentialTokens(input string) []Token {
+	t := NewTokenizer()
+	t.Coalesce()
+	return append(t.Feed([]byte(input)), t.Flush()...)
+}
+
+func collectParallel(t *testing.T, r io.Reader, opts ParallelOptions) []Token {
+	t.Helper()
+	var out []Token
+	require.NoError(t, TokenizeParallel(r, opts, func(tokens []Token) error {
+		out = append(out, tokens...)
+		return nil
+	}))
+	return out
+}
+
+func TestTokenizeParallel(t *testing.T) {
+	var items []string
+	for i := range 50 {
+		items = append(items, fmt.Sprintf(`{"id": %d, "s": "a]\"[}\\", "n": [%d, {"x": null}], "e": []}`, i, i), `-1.5e3`, ` "x\\"`, `true`)
+	}
+	tests := []struct {
+		name  string
+		input string
+		path  string
+	}{
+		{"root", "[" + strings.Join(items, ",") + "]", ""},
+		{"nested", `{"meta": {"a": [1, 2]}, "data": {"items": [` + strings.Join(items, " ,\n ") + ` ] , "n": 1}} 7`, "$.data.items"},
+		{"trailing number", `[1,2,3]`, "$"},
+		{"empty", `{"a": [ ], "b": [1]}`, "$.a"},
+		{"whitespace", "[ \n 1 ,\t2 ] ", "$"},
+		{"not found", `{"a": {"b": 1}, "c": "[x]"}`, "$.a"},
+		{"string bracket", `{"a": "[", "b": [[1], [2]]}`, "$.b"},
+		{"brackets in value", `{"note":"a[[b","items":[1,2,3]}`, "$.items"},
+		{"empty key", `{"a": 1, "": {"": [1, {"": 2}]}}`, `$[""][""]`},
+		{"brackets in key", `{"k[[":1,"[":{"items[":["[[",[4]]}}`, `$["["]["items["]`},
+	}
+	for _, tt := range tests {
+		for _, chunk := range []int{1, 64, 1 << 20} {
+			for _, workers := range []int{1, 4} {
+				t.Run(fmt.Sprintf("%s/chunk=%d/workers=%d", tt.name, chunk, workers), func(t *testing.T) {
+					opts := ParallelOptions{Path: tt.path, Workers: workers, ChunkSize: chunk}
+					got := collectParallel(t, iotest.HalfReader(strings.NewReader(tt.input)), opts)
+					assert.Equal(t, sequentialTokens(tt.input), got)
+				})
+			}
+		}
+	}
+}
+
+func TestTokenizeParallel_Errors(t *testing.T) {
+	err := TokenizeParallel(strings.NewReader(`{"a": [1, 2`), ParallelOptions{Path: "$.a"}, func([]Token) error { return nil })
+	assert.ErrorIs(t, err, io.ErrUnexpectedEOF)
+
+	err = TokenizeParallel(strings.NewReader(`[]`), ParallelOptions{Path: "a"}, func([]Token) error { return nil })
+	assert.Error(t, err)
+
+	err = TokenizeParallel(iotest.ErrReader(errors.New("boom")), ParallelOptions{}, func([]Token) error { return nil })
+	assert.EqualError(t, err, "boom")
+
+	// fn 的错误会停止处理
+	stop := errors.New("stop")
+	calls := 0
+	input := "[" + strings.Repeat(`{"a": 1},`, 10000) + "1]"
+	err = TokenizeParallel(strings.NewReader(input), ParallelOptions{ChunkSize: 16, Workers: 2}, func([]Token) error {
+		calls++
+		if calls == 3 {
+			return stop
+		}
+		return nil
+	})
+	assert.ErrorIs(t, err, stop)
+	assert.Equal(t, 3, calls)
+}
+
+func TestDecodeParallel(t *testing.T) {
+	var items []string
+	for i := range 200 {
+		items = append(items, fmt.Sprintf(`{"id": %d, "name": "n,%d"}`, i, i))
+	}
+	input := `{"skip": [1, 2], "items": [` + strings.Join(items, " , ") + "\n]}"
+	type item struct {
+		ID   int
+		Name string
+	}
+	var got []item
+	err := DecodeParallel(strings.NewReader(input), ParallelOptions{Path: "$.items", Workers: 3, ChunkSize: 100},
+		func() any { return new(item) },
+		func(i int, v any) error {
+			assert.Equal(t, len(got), i)
+			got = append(got, *v.(*item))
+			return nil
+		})
+	require.NoError(t, err)
+	require.Len(t, got, 200)
+	assert.Equal(t, item{ID: 199, Name: "n,199"}, got[199])
+
+	var nums []int
+	err = DecodeParallel(strings.NewReader(`{"a": [0], "": {"": [1, 2]}}`), ParallelOptions{Path: `$[""][""]`},
+		func() any { return new(int) },
+		func(_ int, v any) error {
+			nums = append(nums, *v.(*int))
+			return nil
+		})
+	require.NoError(t, err)
+	assert.Equal(t, []int{1, 2}, nums)
+
+	err = DecodeParallel(strings.NewReader(`[1, 2, "x", 4]`), ParallelOptions{ChunkSize: 1},
+		func() any { return new(int) }, func(int, any) error { return nil })
+	assert.ErrorContains(t, err, "jsontokenizer: element 2: ")
+}
+
+// largeArray 生成约 size 字节的对象数组
+func largeArray(size int) []byte {
+	var b strings.Builder
+	b.WriteString(`{"items": [`)
+	for i := 0; b.Len() < size; i++ {
+		if i > 0 {
+			b.WriteString(",\n  ")
+		}
+		fmt.Fprintf(&b, `{"id": %d, "name": "item \"%d\"", "tags": ["a", "b", "c"], "price": %d.99, "meta": {"ok": true, "note": null}}`, i, i, i%1000)
+	}
+	b.WriteString("]}")
+	return []byte(b.String())
+}
+
+// BenchmarkTokenizeSequential 是并行分词的基准
+func BenchmarkTokenizeSequential(b *testing.B) {
+	data := largeArray(8 << 20)
+	b.SetBytes(int64(len(data)))
+	b.ReportAllocs()
+	for b.Loop() {
+		t := NewTokenizer()
+		t.Coalesce()
+		t.Feed(data)
+		t.Flush()
+	}
+}
+
+func BenchmarkTokenizeParallel(b *testing.B) {
+	data := largeArray(8 << 20)
+	for _, workers := range []int{1, 2, 4, 8} {
+		b.Run(fmt.Sprintf("workers=%d", workers), func(b *testing.B) {
+			b.SetBytes(int64(len(data)))
+			b.ReportAllocs()
+			for b.Loop() {
+				err := TokenizeParallel(bytes.NewReader(data), ParallelOptions{Path: "$.items", Workers: workers},
+					func([]Token) error { return nil })
+				if err != nil {
+					b.Fatal(err)
+				}
+			}
+		})
+	}
+}
+
+func BenchmarkDecodeParallel(b *testing.B) {
+	data := largeArray(8 << 20)
+	for _, workers := range []int{1, 2, 4, 8} {
+		b.Run(fmt.Sprintf("workers=%d", workers), func(b *testing.B) {
+			b.SetBytes(int64(len(data)))
+			b.ReportAllocs()
+			for b.Loop() {
+				err := DecodeParallel(bytes.NewReader(data), ParallelOptions{Path: "$.items", Workers: workers},
+					func() any { return new(map[string]any) }, func(int, any) error { return nil })
+				if err != nil {
+					b.Fatal(err)
+				}
+			}
+		})
+	}
+}
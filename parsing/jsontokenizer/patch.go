@@ -0,0 +1,503 @@
+package jsontokenizer
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/libxyz/gizmo/parsing/jsontokenizer/internal/jsonstr"
+)
+
+var (
+	// ErrPathNotFound is returned when a patch operation refers to a location
+	// that does not exist in the document.
+	ErrPathNotFound = errors.New("path not found")
+	// ErrTestFailed is returned when a "test" operation does not match.
+	ErrTestFailed = errors.New("test failed")
+
+	// errPatchAborted 用于在后续阶段失败时中止前面的阶段
+	errPatchAborted = errors.New("jsontokenizer: patch aborted")
+)
+
+// ApplyPatch applies the RFC 6902 operations in patch to the JSON document
+// read from r and writes the result to w. The "add", "remove", "replace" and
+// "test" operations are supported; "move" and "copy" are not.
+//
+// The document is never held in memory: each operation runs as a streaming
+// pass over the output of the previous one, and only the values at the
+// targeted paths are buffered. Values that are not touched keep their
+// original text, but whitespace between tokens is dropped. If an operation
+// fails, the error wraps ErrPathNotFound, ErrTestFailed or the syntax error
+// found, and part of the result may already have been written to w.
+func ApplyPatch(r io.Reader, w io.Writer, patch []Operation) error {
+	if len(patch) == 0 {
+		return transform(r, w, func(p *patcher, first Token) error { return p.copy(first) })
+	}
+	for _, op := range patch {
+		if err := checkOperation(op); err != nil {
+			return err
+		}
+	}
+
+	// 每个操作是流水线中的一个阶段，通过管道连接
+	errs := make([]error, len(patch))
+	var readers []*io.PipeReader
+	var wg sync.WaitGroup
+	src := r
+	for i, op := range patch[:len(patch)-1] {
+		pr, pw := io.Pipe()
+		readers = append(readers, pr)
+		wg.Add(1)
+		go func(in io.Reader) {
+			defer wg.Done()
+			errs[i] = applyOperation(in, pw, op)
+			pw.CloseWithError(errs[i])
+		}(src)
+		src = pr
+	}
+	errs[len(patch)-1] = applyOperation(src, w, patch[len(patch)-1])
+	for _, pr := range readers {
+		pr.CloseWithError(errPatchAborted)
+	}
+	wg.Wait()
+
+	// 返回最早失败的阶段的错误，后续阶段的错误只是它的结果
+	for _, err := range errs {
+		if err != nil && !errors.Is(err, errPatchAborted) {
+			return err
+		}
+	}
+	return nil
+}
+
+// checkOperation 检查操作是否受支持且格式正确
+func checkOperation(op Operation) error {
+	switch op.Op {
+	case "add", "replace", "test":
+		if !json.Valid(op.Value) {
+			return fmt.Errorf("jsontokenizer: %s %q: invalid value", op.Op, op.Path)
+		}
+	case "remove":
+	default:
+		return fmt.Errorf("jsontokenizer: unsupported patch operation %q", op.Op)
+	}
+	if _, err := parsePointer(op.Path); err != nil {
+		return fmt.Errorf("jsontokenizer: %s %q: %w", op.Op, op.Path, err)
+	}
+	return nil
+}
+
+// applyOperation 对文档执行一个操作
+func applyOperation(r io.Reader, w io.Writer, op Operation) error {
+	ptr, _ := parsePointer(op.Path)
+	err := transform(r, w, func(p *patcher, first Token) error {
+		return p.operation(first, op, ptr, 0)
+	})
+	if errors.Is(err, ErrPathNotFound) || errors.Is(err, ErrTestFailed) {
+		return fmt.Errorf("jsontokenizer: %s %q: %w", op.Op, op.Path, err)
+	}
+	return err
+}
+
+// ApplyMergePatch applies the RFC 7396 merge patch to the JSON document read
+// from r and writes the result to w. Members set to null in the patch are
+// removed, objects are merged recursively and any other value replaces the
+// target. Members that are not in the patch are copied without being
+// buffered, and keep their original text; whitespace between tokens is
+// dropped.
+func ApplyMergePatch(r io.Reader, w io.Writer, patch []byte) error {
+	m, err := parseMergePatch(patch)
+	if err != nil {
+		return fmt.Errorf("jsontokenizer: merge patch: %w", err)
+	}
+	return transform(r, w, func(p *patcher, first Token) error {
+		return p.merge(first, m)
+	})
+}
+
+// transform 读取一个文档，由 fn 处理后写入 w，并检查文档之后没有多余内容
+func transform(r io.Reader, w io.Writer, fn func(p *patcher, first Token) error) error {
+	p := &patcher{in: newValueReader(r), w: bufio.NewWriter(w)}
+	first, err := p.in.next()
+	if err != nil {
+		return err
+	}
+	if err := fn(p, first); err != nil {
+		return err
+	}
+	if tk, err := p.in.d.step(); !errors.Is(err, io.EOF) {
+		if err != nil {
+			return err
+		}
+		return p.in.d.syntaxError(tk, "unexpected "+describe(tk)+" after document")
+	}
+	return p.w.Flush()
+}
+
+// patcher 边读取边输出文档，在目标位置进行修改
+type patcher struct {
+	in *valueReader
+	w  *bufio.Writer
+}
+
+// copy 原样输出以 first 开头的值
+func (p *patcher) copy(first Token) error {
+	return p.pass(first, true)
+}
+
+// skip 跳过以 first 开头的值
+func (p *patcher) skip(first Token) error {
+	return p.pass(first, false)
+}
+
+// pass 读取以 first 开头的值，write 为真时同时输出
+func (p *patcher) pass(first Token, write bool) error {
+	depth := len(p.in.d.stack)
+	if first.Type == TokenObjectStart || first.Type == TokenArrayStart {
+		depth--
+	}
+	tk := first
+	for {
+		if write {
+			p.w.WriteString(tk.Raw)
+		}
+		if len(p.in.d.stack) == depth {
+			return nil
+		}
+		var err error
+		if tk, err = p.in.d.step(); err != nil {
+			if errors.Is(err, io.EOF) {
+				return io.ErrUnexpectedEOF
+			}
+			return err
+		}
+	}
+}
+
+// operation 对以 first 开头、位于 ptr[:depth] 的值执行操作
+func (p *patcher) operation(first Token, op Operation, ptr []string, depth int) error {
+	if depth == len(ptr) {
+		// 目标是整个值；删除和在对象或数组中添加由上层处理
+		switch op.Op {
+		case "test":
+			old, err := p.in.rest(first)
+			if err != nil {
+				return err
+			}
+			if ok, err := jsonEqual(old, op.Value); err != nil || !ok {
+				return errors.Join(ErrTestFailed, err)
+			}
+			p.w.Write(old)
+			return nil
+		case "remove":
+			return ErrPathNotFound
+		}
+		if err := p.skip(first); err != nil {
+			return err
+		}
+		p.w.Write(op.Value)
+		return nil
+	}
+
+	switch first.Type {
+	case TokenObjectStart:
+		return p.object(op, ptr, depth)
+	case TokenArrayStart:
+		return p.array(op, ptr, depth)
+	case TokenUnknown, TokenString, TokenStringEscape, TokenNumber, TokenBoolean, TokenNull, TokenObjectEnd,
+		TokenArrayEnd, TokenKey, TokenKeyEscape, TokenComma, TokenColon, TokenQuote, TokenWhitespace, TokenStringChunk:
+	}
+	return ErrPathNotFound
+}
+
+// object 在对象中查找 ptr[depth] 对应的成员
+func (p *patcher) object(op Operation, ptr []string, depth int) error {
+	last := depth == len(ptr)-1
+	found := false
+	n := 0 // 已输出的成员数
+	p.w.WriteByte('{')
+	for {
+		key, err := p.in.next()
+		if err != nil {
+			return err
+		}
+		if key.Type == TokenObjectEnd {
+			break
+		}
+		first, err := p.in.next()
+		if err != nil {
+			return err
+		}
+		if key.Val != ptr[depth] || found {
+			p.member(&n, key.Raw)
+			if err := p.copy(first); err != nil {
+				return err
+			}
+			continue
+		}
+
+		found = true
+		if last && op.Op == "remove" {
+			if err := p.skip(first); err != nil {
+				return err
+			}
+			continue
+		}
+		p.member(&n, key.Raw)
+		if err := p.operation(first, op, ptr, depth+1); err != nil {
+			return err
+		}
+	}
+	if !found {
+		if !last || op.Op != "add" {
+			return ErrPathNotFound
+		}
+		p.member(&n, string(jsonstr.AppendQuote(nil, ptr[depth])))
+		p.w.Write(op.Value)
+	}
+	p.w.WriteByte('}')
+	return nil
+}
+
+// member 输出成员的键名，必要时先输出逗号
+func (p *patcher) member(n *int, rawKey string) {
+	if *n > 0 {
+		p.w.WriteByte(',')
+	}
+	*n++
+	p.w.WriteString(rawKey)
+	p.w.WriteByte(':')
+}
+
+// array 在数组中查找 ptr[depth] 对应的元素
+func (p *patcher) array(op Operation, ptr []string, depth int) error {
+	last := depth == len(ptr)-1
+	insert := last && op.Op == "add"
+	index := -1 // "-" 表示数组末尾
+	if ptr[depth] != "-" || !insert {
+		var ok bool
+		if index, ok = arrayIndex(ptr[depth]); !ok {
+			return ErrPathNotFound
+		}
+	}
+
+	n := 0 // 已输出的元素数
+	sep := func() {
+		if n > 0 {
+			p.w.WriteByte(',')
+		}
+		n++
+	}
+	p.w.WriteByte('[')
+	for i := 0; ; i++ {
+		first, err := p.in.next()
+		if err != nil {
+			return err
+		}
+		if first.Type == TokenArrayEnd {
+			if insert && (index == -1 || index == i) {
+				sep()
+				p.w.Write(op.Value)
+			} else if index >= i {
+				return ErrPathNotFound
+			}
+			p.w.WriteByte(']')
+			return nil
+		}
+
+		switch {
+		case i != index:
+			sep()
+			err = p.copy(first)
+		case insert:
+			sep()
+			p.w.Write(op.Value)
+			sep()
+			err = p.copy(first)
+		case last && op.Op == "remove":
+			err = p.skip(first)
+		default:
+			sep()
+			err = p.operation(first, op, ptr, depth+1)
+		}
+		if err != nil {
+			return err
+		}
+	}
+}
+
+// merge 将合并补丁 m 应用到以 first 开头的值
+func (p *patcher) merge(first Token, m *mergePatch) error {
+	if m.vals == nil || first.Type != TokenObjectStart {
+		if err := p.skip(first); err != nil {
+			return err
+		}
+		p.w.Write(m.appendTo(nil))
+		return nil
+	}
+
+	seen := make(map[string]bool, len(m.keys))
+	n := 0
+	p.w.WriteByte('{')
+	for {
+		key, err := p.in.next()
+		if err != nil {
+			return err
+		}
+		if key.Type == TokenObjectEnd {
+			break
+		}
+		first, err := p.in.next()
+		if err != nil {
+			return err
+		}
+		child, ok := m.vals[key.Val]
+		switch {
+		case !ok:
+			p.member(&n, key.Raw)
+			err = p.copy(first)
+		case child.isNull():
+			seen[key.Val] = true
+			err = p.skip(first)
+		default:
+			seen[key.Val] = true
+			p.member(&n, key.Raw)
+			err = p.merge(first, child)
+		}
+		if err != nil {
+			return err
+		}
+	}
+	for _, k := range m.keys {
+		if child := m.vals[k]; !seen[k] && !child.isNull() {
+			p.member(&n, string(jsonstr.AppendQuote(nil, k)))
+			p.w.Write(child.appendTo(nil))
+		}
+	}
+	p.w.WriteByte('}')
+	return nil
+}
+
+// mergePatch 是解析后的合并补丁，对象按键名展开，其他值保留原始文本
+type mergePatch struct {
+	raw  json.RawMessage
+	keys []string
+	vals map[string]*mergePatch
+}
+
+// parseMergePatch 解析合并补丁
+func parseMergePatch(raw []byte) (*mergePatch, error) {
+	raw = bytes.TrimSpace(raw)
+	if !json.Valid(raw) {
+		return nil, errors.New("invalid JSON")
+	}
+	if len(raw) == 0 || raw[0] != '{' {
+		return &mergePatch{raw: raw}, nil
+	}
+
+	m := &mergePatch{vals: make(map[string]*mergePatch)}
+	dec := json.NewDecoder(bytes.NewReader(raw))
+	if _, err := dec.Token(); err != nil {
+		return nil, err
+	}
+	for dec.More() {
+		tk, err := dec.Token()
+		if err != nil {
+			return nil, err
+		}
+		var val json.RawMessage
+		if err := dec.Decode(&val); err != nil {
+			return nil, err
+		}
+		child, err := parseMergePatch(val)
+		if err != nil {
+			return nil, err
+		}
+		key := tk.(string)
+		if _, ok := m.vals[key]; !ok {
+			m.keys = append(m.keys, key)
+		}
+		m.vals[key] = child
+	}
+	return m, nil
+}
+
+// isNull 判断补丁值是否为 null
+func (m *mergePatch) isNull() bool {
+	return m.vals == nil && string(m.raw) == "null"
+}
+
+// appendTo 追加补丁作用于非对象时的结果，即去掉其中值为 null 的成员
+func (m *mergePatch) appendTo(dst []byte) []byte {
+	if m.vals == nil {
+		return append(dst, m.raw...)
+	}
+	dst = append(dst, '{')
+	n := 0
+	for _, k := range m.keys {
+		child := m.vals[k]
+		if child.isNull() {
+			continue
+		}
+		if n > 0 {
+			dst = append(dst, ',')
+		}
+		n++
+		dst = append(jsonstr.AppendQuote(dst, k), ':')
+		dst = child.appendTo(dst)
+	}
+	return append(dst, '}')
+}
+
+// parsePointer 解析 RFC 6901 JSON Pointer
+func parsePointer(s string) ([]string, error) {
+	if s == "" {
+		return nil, nil
+	}
+	if s[0] != '/' {
+		return nil, errors.New("pointer must start with /")
+	}
+	parts := strings.Split(s[1:], "/")
+	for i, part := range parts {
+		for j := range len(part) {
+			if part[j] == '~' && (j+1 == len(part) || part[j+1] != '0' && part[j+1] != '1') {
+				return nil, fmt.Errorf("invalid escape in %q", part)
+			}
+		}
+		parts[i] = pointerUnescaper.Replace(part)
+	}
+	return parts, nil
+}
+
+// pointerUnescaper 还原 JSON Pointer 中转义的键名
+var pointerUnescaper = strings.NewReplacer("~1", "/", "~0", "~")
+
+// arrayIndex 解析 JSON Pointer 中的数组下标，不允许前导零
+func arrayIndex(s string) (int, bool) {
+	if s == "" || len(s) > 1 && s[0] == '0' {
+		return 0, false
+	}
+	for i := range len(s) {
+		if !isDigit(rune(s[i])) {
+			return 0, false
+		}
+	}
+	n, err := strconv.Atoi(s)
+	return n, err == nil
+}
+
+// jsonEqual 判断两个JSON值是否相等，对象的成员顺序无关
+func jsonEqual(a, b []byte) (bool, error) {
+	errDiffer := errors.New("differ")
+	err := Diff(bytes.NewReader(a), bytes.NewReader(b), func(Operation) error { return errDiffer })
+	if errors.Is(err, errDiffer) {
+		return false, nil
+	}
+	return err == nil, err
+}
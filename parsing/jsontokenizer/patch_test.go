@@ -0,0 +1,127 @@
+package jsontokenizer
+
+import (
+	"bytes"
+	"encoding/json"
+	"strings"
+	"testing"
+	"testing/iotest"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func applyPatch(t *testing.T, doc, patch string) (string, error) {
+	t.Helper()
+	var ops []Operation
+	require.NoError(t, json.Unmarshal([]byte(patch), &ops))
+	var out bytes.Buffer
+	err := ApplyPatch(iotest.OneByteReader(strings.NewReader(doc)), &out, ops)
+	return out.String(), err
+}
+
+func TestApplyPatch(t *testing.T) {
+	doc := `{"a": 1, "b": {"c": [1, 2.50, 3]}, "d/e": "x", "big": [{"n": 1e3}]}`
+	tests := []struct {
+		patch, expected string
+	}{
+		{`[]`, `{"a":1,"b":{"c":[1,2.50,3]},"d/e":"x","big":[{"n":1e3}]}`},
+		{`[{"op":"replace","path":"/a","value":{"z":true}}]`, `{"a":{"z":true},"b":{"c":[1,2.50,3]},"d/e":"x","big":[{"n":1e3}]}`},
+		{`[{"op":"remove","path":"/a"}]`, `{"b":{"c":[1,2.50,3]},"d/e":"x","big":[{"n":1e3}]}`},
+		{`[{"op":"remove","path":"/big"}]`, `{"a":1,"b":{"c":[1,2.50,3]},"d/e":"x"}`},
+		{`[{"op":"add","path":"/b/c/1","value":9}]`, `{"a":1,"b":{"c":[1,9,2.50,3]},"d/e":"x","big":[{"n":1e3}]}`},
+		{`[{"op":"add","path":"/b/c/-","value":9}]`, `{"a":1,"b":{"c":[1,2.50,3,9]},"d/e":"x","big":[{"n":1e3}]}`},
+		{`[{"op":"add","path":"/b/c/3","value":9}]`, `{"a":1,"b":{"c":[1,2.50,3,9]},"d/e":"x","big":[{"n":1e3}]}`},
+		{`[{"op":"remove","path":"/b/c/0"}]`, `{"a":1,"b":{"c":[2.50,3]},"d/e":"x","big":[{"n":1e3}]}`},
+		{`[{"op":"add","path":"/new","value":"v"}]`, `{"a":1,"b":{"c":[1,2.50,3]},"d/e":"x","big":[{"n":1e3}],"new":"v"}`},
+		{`[{"op":"add","path":"/a","value":2}]`, `{"a":2,"b":{"c":[1,2.50,3]},"d/e":"x","big":[{"n":1e3}]}`},
+		{`[{"op":"replace","path":"/d~1e","value":null}]`, `{"a":1,"b":{"c":[1,2.50,3]},"d/e":null,"big":[{"n":1e3}]}`},
+		{`[{"op":"test","path":"/b","value":{"c":[1,2.5,3]}},{"op":"replace","path":"/big/0/n","value":0}]`, `{"a":1,"b":{"c":[1,2.50,3]},"d/e":"x","big":[{"n":0}]}`},
+		{`[{"op":"replace","path":"","value":[]}]`, `[]`},
+		{`[{"op":"remove","path":"/b/c/0"},{"op":"remove","path":"/b/c/0"},{"op":"add","path":"/b/c/0","value":7}]`, `{"a":1,"b":{"c":[7,3]},"d/e":"x","big":[{"n":1e3}]}`},
+	}
+	for _, tt := range tests {
+		got, err := applyPatch(t, doc, tt.patch)
+		require.NoError(t, err, tt.patch)
+		assert.Equal(t, tt.expected, got, tt.patch)
+	}
+}
+
+func TestApplyPatch_Errors(t *testing.T) {
+	doc := `{"a":[1,2],"b":"x"}`
+	for _, patch := range []string{
+		`[{"op":"remove","path":"/z"}]`,
+		`[{"op":"replace","path":"/a/2","value":1}]`,
+		`[{"op":"add","path":"/a/3","value":1}]`,
+		`[{"op":"add","path":"/a/01","value":1}]`,
+		`[{"op":"add","path":"/b/c","value":1}]`,
+		`[{"op":"add","path":"/z/c","value":1}]`,
+		`[{"op":"remove","path":"/a"},{"op":"remove","path":"/a"}]`,
+		`[{"op":"remove","path":""}]`,
+	} {
+		_, err := applyPatch(t, doc, patch)
+		assert.ErrorIs(t, err, ErrPathNotFound, patch)
+	}
+
+	_, err := applyPatch(t, doc, `[{"op":"test","path":"/b","value":"y"},{"op":"remove","path":"/a"}]`)
+	assert.ErrorIs(t, err, ErrTestFailed)
+	_, err = applyPatch(t, doc, `[{"op":"remove","path":"/a"},{"op":"test","path":"/a","value":[1,2]}]`)
+	assert.ErrorIs(t, err, ErrPathNotFound)
+
+	for _, patch := range []string{
+		`[{"op":"move","from":"/a","path":"/c"}]`,
+		`[{"op":"add","path":"a","value":1}]`,
+		`[{"op":"add","path":"/a~2","value":1}]`,
+		`[{"op":"add","path":"/a"}]`,
+	} {
+		_, err := applyPatch(t, doc, patch)
+		assert.Error(t, err, patch)
+	}
+
+	var se *SyntaxError
+	_, err = applyPatch(t, `{"a":1} 2`, `[{"op":"remove","path":"/a"},{"op":"add","path":"/b","value":1}]`)
+	assert.ErrorAs(t, err, &se)
+}
+
+func TestApplyMergePatch(t *testing.T) {
+	tests := []struct {
+		doc, patch, expected string
+	}{
+		{`{"a":"b"}`, `{"a":"c"}`, `{"a":"c"}`},
+		{`{"a":"b"}`, `{"b":"c"}`, `{"a":"b","b":"c"}`},
+		{`{"a":"b"}`, `{"a":null}`, `{}`},
+		{`{"a":"b","b":"c"}`, `{"a":null}`, `{"b":"c"}`},
+		{`{"a":["b"]}`, `{"a":"c"}`, `{"a":"c"}`},
+		{`{"a":"c"}`, `{"a":["b"]}`, `{"a":["b"]}`},
+		{`{"a":{"b":"c"}}`, `{"a":{"b":"d","c":null}}`, `{"a":{"b":"d"}}`},
+		{`{"a":[{"b":"c"}]}`, `{"a":[1]}`, `{"a":[1]}`},
+		{`["a","b"]`, `["c","d"]`, `["c","d"]`},
+		{`{"a":"b"}`, `["c"]`, `["c"]`},
+		{`{"a":"foo"}`, `null`, `null`},
+		{`{"a":"foo"}`, `"bar"`, `"bar"`},
+		{`{"e":null}`, `{"a":1}`, `{"e":null,"a":1}`},
+		{`[1,2]`, `{"a":"b","c":null}`, `{"a":"b"}`},
+		{`{}`, `{"a":{"bb":{"ccc":null}}}`, `{"a":{"bb":{}}}`},
+		{`{"keep": [1, 2.0], "x": {"y": 1}}`, `{"x":{"z":2}}`, `{"keep":[1,2.0],"x":{"y":1,"z":2}}`},
+	}
+	for _, tt := range tests {
+		var out bytes.Buffer
+		require.NoError(t, ApplyMergePatch(strings.NewReader(tt.doc), &out, []byte(tt.patch)), tt.patch)
+		assert.Equal(t, tt.expected, out.String(), "%s + %s", tt.doc, tt.patch)
+	}
+
+	assert.Error(t, ApplyMergePatch(strings.NewReader(`{}`), &bytes.Buffer{}, []byte(`{`)))
+}
+
+func TestParsePointer(t *testing.T) {
+	ptr, err := parsePointer(`/a~1b/~0/0/`)
+	require.NoError(t, err)
+	assert.Equal(t, []string{"a/b", "~", "0", ""}, ptr)
+
+	ptr, err = parsePointer("")
+	require.NoError(t, err)
+	assert.Empty(t, ptr)
+
+	_, err = parsePointer("/a~")
+	assert.Error(t, err)
+}
@@ -0,0 +1,267 @@
+package jsontokenizer
+
+import (
+	"cmp"
+	"fmt"
+	"io"
+	"slices"
+	"strconv"
+	"strings"
+	"text/tabwriter"
+)
+
+// PathStats describes the values found at one path pattern of the input.
+type PathStats struct {
+	// Path is the pattern in the syntax of Flatten, with every array index
+	// replaced by [*], such as $.users[*].name.
+	Path string `json:"path"`
+	// Count is the number of values at the pattern.
+	Count int64 `json:"count"`
+	// Bytes is the total size of the values' text, including nested values
+	// and the whitespace inside them.
+	Bytes int64 `json:"bytes"`
+	// MaxDepth is the deepest nesting of containers within a value: 0 for
+	// scalars, 1 for containers of scalars and so on.
+	MaxDepth int `json:"maxDepth"`
+	// MaxStringLen is the length in bytes of the longest decoded string value.
+	MaxStringLen int `json:"maxStringLen"`
+	// Types counts the values of each type.
+	Types TypeCounts `json:"types"`
+}
+
+// TypeCounts counts values by type.
+type TypeCounts struct {
+	Object  int64 `json:"object,omitempty"`
+	Array   int64 `json:"array,omitempty"`
+	String  int64 `json:"string,omitempty"`
+	Number  int64 `json:"number,omitempty"`
+	Boolean int64 `json:"boolean,omitempty"`
+	Null    int64 `json:"null,omitempty"`
+}
+
+// summary 列出非零的计数，如 "object:3 null:1"
+func (c TypeCounts) summary() string {
+	var parts []string
+	for _, e := range []struct {
+		name string
+		n    int64
+	}{{"object", c.Object}, {"array", c.Array}, {"string", c.String}, {"number", c.Number}, {"boolean", c.Boolean}, {"null", c.Null}} {
+		if e.n > 0 {
+			parts = append(parts, e.name+":"+strconv.FormatInt(e.n, 10))
+		}
+	}
+	return strings.Join(parts, " ")
+}
+
+// StatsOrder is an order for SortStats.
+type StatsOrder int
+
+const (
+	// ByPath orders by path pattern.
+	ByPath StatsOrder = iota
+	// ByBytes orders by total size, largest first.
+	ByBytes
+	// ByCount orders by number of values, most first.
+	ByCount
+	// ByDepth orders by nesting depth, deepest first.
+	ByDepth
+	// ByStringLen orders by longest string, longest first.
+	ByStringLen
+)
+
+// SortStats sorts stats in the given order. Ties keep their relative order.
+func SortStats(stats []PathStats, by StatsOrder) {
+	slices.SortStableFunc(stats, func(a, b PathStats) int {
+		switch by {
+		case ByPath:
+			return cmp.Compare(a.Path, b.Path)
+		case ByBytes:
+			return cmp.Compare(b.Bytes, a.Bytes)
+		case ByCount:
+			return cmp.Compare(b.Count, a.Count)
+		case ByDepth:
+			return cmp.Compare(b.MaxDepth, a.MaxDepth)
+		case ByStringLen:
+			return cmp.Compare(b.MaxStringLen, a.MaxStringLen)
+		}
+		return 0
+	})
+}
+
+// WriteStatsTable writes stats to w as an aligned table with a header row.
+func WriteStatsTable(w io.Writer, stats []PathStats) error {
+	tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+	fmt.Fprint(tw, "PATH\tCOUNT\tBYTES\tMAX DEPTH\tMAX STRING\tTYPES\n")
+	for _, s := range stats {
+		fmt.Fprintf(tw, "%s\t%d\t%d\t%d\t%d\t%s\n", s.Path, s.Count, s.Bytes, s.MaxDepth, s.MaxStringLen, s.Types.summary())
+	}
+	return tw.Flush()
+}
+
+// Profile reads the JSON values in r and returns the statistics of every path
+// pattern, in the order the patterns first appear.
+func Profile(r io.Reader) ([]PathStats, error) {
+	p := NewProfiler()
+	if _, err := io.Copy(p, r); err != nil {
+		return nil, err
+	}
+	if err := p.Close(); err != nil {
+		return nil, err
+	}
+	return p.Stats(), nil
+}
+
+// Profiler collects per-path statistics of the JSON written to it in a single
+// pass. Only the statistics and the open containers are kept in memory, so
+// inputs of any size can be profiled. Several values may follow each other,
+// as in NDJSON; each is profiled from $.
+type Profiler struct {
+	t      *Tokenizer
+	stack  []profileFrame // 未结束的容器
+	key    string         // 对象中下一个值的键名
+	offset int64          // 已处理的字节数
+	stats  map[string]*PathStats
+	order  []string // 按首次出现的顺序
+	err    error
+}
+
+// profileFrame 是一个未结束的容器
+type profileFrame struct {
+	path  string
+	end   TokenType // 结束容器的Token类型
+	start int64     // 起始偏移量
+	depth int       // 子值的最大嵌套深度
+}
+
+// NewProfiler returns a Profiler without statistics.
+func NewProfiler() *Profiler {
+	return &Profiler{stats: make(map[string]*PathStats)}
+}
+
+// Write profiles the next part of the input. It fails once the input is not
+// valid JSON.
+func (p *Profiler) Write(b []byte) (int, error) {
+	if p.err != nil {
+		return 0, p.err
+	}
+	if p.t == nil {
+		p.t = NewTokenizer()
+		p.t.Coalesce()
+	}
+	for _, tk := range p.t.Feed(b) {
+		if p.token(tk); p.err != nil {
+			return 0, p.err
+		}
+	}
+	return len(b), nil
+}
+
+// Close ends the input, reporting an error if it is incomplete. A Profiler
+// accepts another input after Close and keeps accumulating statistics.
+func (p *Profiler) Close() error {
+	if p.err != nil || p.t == nil {
+		return p.err
+	}
+	for _, tk := range p.t.Flush() {
+		if p.token(tk); p.err != nil {
+			return p.err
+		}
+	}
+	if len(p.stack) > 0 || p.t.incomplete() {
+		p.err = fmt.Errorf("jsontokenizer: profile: %w", io.ErrUnexpectedEOF)
+		return p.err
+	}
+	p.t, p.offset = nil, 0
+	return nil
+}
+
+// Stats returns the statistics of every path pattern seen so far, in the
+// order the patterns first appear.
+func (p *Profiler) Stats() []PathStats {
+	out := make([]PathStats, len(p.order))
+	for i, path := range p.order {
+		out[i] = *p.stats[path]
+	}
+	return out
+}
+
+// token 处理一个Token
+func (p *Profiler) token(tk Token) {
+	start := p.offset
+	p.offset += int64(len(tk.Raw))
+	switch tk.Type {
+	case TokenUnknown:
+		p.err = fmt.Errorf("jsontokenizer: profile: unexpected %q at offset %d", tk.Raw, start)
+	case TokenKey:
+		p.key = tk.Val
+	case TokenObjectStart, TokenArrayStart:
+		end := TokenObjectEnd
+		if tk.Type == TokenArrayStart {
+			end = TokenArrayEnd
+		}
+		// 容器在结束时记录，开始时先登记路径模式以保持首次出现的顺序
+		path := p.valuePath()
+		p.stat(path)
+		p.stack = append(p.stack, profileFrame{path: path, end: end, start: start})
+	case TokenObjectEnd, TokenArrayEnd:
+		if len(p.stack) == 0 || p.stack[len(p.stack)-1].end != tk.Type {
+			p.err = fmt.Errorf("jsontokenizer: profile: unexpected %q at offset %d", tk.Raw, start)
+			return
+		}
+		f := p.stack[len(p.stack)-1]
+		p.stack = p.stack[:len(p.stack)-1]
+		s := p.record(f.path, p.offset-f.start, f.depth+1)
+		if tk.Type == TokenObjectEnd {
+			s.Types.Object++
+		} else {
+			s.Types.Array++
+		}
+	case TokenString:
+		s := p.record(p.valuePath(), int64(len(tk.Raw)), 0)
+		s.Types.String++
+		s.MaxStringLen = max(s.MaxStringLen, len(tk.Val))
+	case TokenNumber:
+		p.record(p.valuePath(), int64(len(tk.Raw)), 0).Types.Number++
+	case TokenBoolean:
+		p.record(p.valuePath(), int64(len(tk.Raw)), 0).Types.Boolean++
+	case TokenNull:
+		p.record(p.valuePath(), int64(len(tk.Raw)), 0).Types.Null++
+	case TokenStringEscape, TokenKeyEscape, TokenComma, TokenColon, TokenQuote, TokenWhitespace, TokenStringChunk:
+	}
+}
+
+// valuePath 返回下一个值的路径模式
+func (p *Profiler) valuePath() string {
+	if len(p.stack) == 0 {
+		return "$"
+	}
+	top := p.stack[len(p.stack)-1]
+	if top.end == TokenArrayEnd {
+		return top.path + "[*]"
+	}
+	return string(appendKeySegment([]byte(top.path), p.key))
+}
+
+// record 记录一个已结束的值，返回路径模式的统计以便更新类型
+func (p *Profiler) record(path string, size int64, depth int) *PathStats {
+	if len(p.stack) > 0 {
+		parent := &p.stack[len(p.stack)-1]
+		parent.depth = max(parent.depth, depth)
+	}
+	s := p.stat(path)
+	s.Count++
+	s.Bytes += size
+	s.MaxDepth = max(s.MaxDepth, depth)
+	return s
+}
+
+// stat 返回路径模式的统计，不存在时创建
+func (p *Profiler) stat(path string) *PathStats {
+	s, ok := p.stats[path]
+	if !ok {
+		s = &PathStats{Path: path}
+		p.stats[path] = s
+		p.order = append(p.order, path)
+	}
+	return s
+}
@@ -0,0 +1,97 @@
+package jsontokenizer
+
+import (
+	"bytes"
+	"encoding/json"
+	"io"
+	"strings"
+	"testing"
+	"testing/iotest"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestProfile(t *testing.T) {
+	input := `{"users": [{"name": "ann", "tags": ["a", "b"]}, {"name": null, "a.b": 1.5}], "ok": true}` + "\n" + `{"ok": false, "users": []}`
+	stats, err := Profile(iotest.OneByteReader(strings.NewReader(input)))
+	require.NoError(t, err)
+	assert.Equal(t, []PathStats{
+		{Path: "$", Count: 2, Bytes: 88 + 26, MaxDepth: 4, Types: TypeCounts{Object: 2}},
+		{Path: "$.users", Count: 2, Bytes: 65 + 2, MaxDepth: 3, Types: TypeCounts{Array: 2}},
+		{Path: "$.users[*]", Count: 2, Bytes: 35 + 26, MaxDepth: 2, Types: TypeCounts{Object: 2}},
+		{Path: "$.users[*].name", Count: 2, Bytes: 5 + 4, MaxStringLen: 3, Types: TypeCounts{String: 1, Null: 1}},
+		{Path: "$.users[*].tags", Count: 1, Bytes: 10, MaxDepth: 1, Types: TypeCounts{Array: 1}},
+		{Path: "$.users[*].tags[*]", Count: 2, Bytes: 6, MaxStringLen: 1, Types: TypeCounts{String: 2}},
+		{Path: `$.users[*]["a.b"]`, Count: 1, Bytes: 3, Types: TypeCounts{Number: 1}},
+		{Path: "$.ok", Count: 2, Bytes: 9, Types: TypeCounts{Boolean: 2}},
+	}, stats)
+
+	SortStats(stats, ByBytes)
+	assert.Equal(t, []string{"$", "$.users", "$.users[*]", "$.users[*].tags", "$.users[*].name", "$.ok", "$.users[*].tags[*]", `$.users[*]["a.b"]`}, statsPaths(stats))
+	SortStats(stats, ByPath)
+	assert.Equal(t, []string{"$", "$.ok", "$.users", "$.users[*]", "$.users[*].name", "$.users[*].tags", "$.users[*].tags[*]", `$.users[*]["a.b"]`}, statsPaths(stats))
+	SortStats(stats, ByCount)
+	assert.Equal(t, "$.users[*].tags", stats[len(stats)-2].Path)
+	SortStats(stats, ByDepth)
+	assert.Equal(t, []string{"$", "$.users", "$.users[*]"}, statsPaths(stats[:3]))
+	SortStats(stats, ByStringLen)
+	assert.Equal(t, "$.users[*].name", stats[0].Path)
+}
+
+func statsPaths(stats []PathStats) []string {
+	var paths []string
+	for _, s := range stats {
+		paths = append(paths, s.Path)
+	}
+	return paths
+}
+
+func TestProfile_Scalar(t *testing.T) {
+	stats, err := Profile(strings.NewReader(` 12 "x\n" `))
+	require.NoError(t, err)
+	assert.Equal(t, []PathStats{{Path: "$", Count: 2, Bytes: 7, MaxStringLen: 2, Types: TypeCounts{String: 1, Number: 1}}}, stats)
+}
+
+func TestProfiler_Errors(t *testing.T) {
+	for _, input := range []string{`{"a": [1}`, `{"a": x}`, `]`} {
+		_, err := Profile(strings.NewReader(input))
+		assert.ErrorContains(t, err, "unexpected", input)
+	}
+	for _, input := range []string{`{"a": [1]`, `"abc`} {
+		_, err := Profile(strings.NewReader(input))
+		assert.ErrorIs(t, err, io.ErrUnexpectedEOF, input)
+	}
+
+	// 多个输入的统计会累加
+	p := NewProfiler()
+	for _, input := range []string{`{"a": 1}`, `[2`, `{"a": "x"}`} {
+		_, err := p.Write([]byte(input))
+		require.NoError(t, err)
+		if input == `[2` {
+			assert.Error(t, p.Close())
+			p = NewProfiler()
+			continue
+		}
+		require.NoError(t, p.Close())
+	}
+	require.Len(t, p.Stats(), 2)
+}
+
+func TestWriteStatsTable(t *testing.T) {
+	stats, err := Profile(strings.NewReader(`{"id": 7, "name": ["a", null]}`))
+	require.NoError(t, err)
+	var out bytes.Buffer
+	require.NoError(t, WriteStatsTable(&out, stats))
+	assert.Equal(t, strings.Join([]string{
+		"PATH       COUNT  BYTES  MAX DEPTH  MAX STRING  TYPES",
+		"$          1      30     2          0           object:1",
+		"$.id       1      1      0          0           number:1",
+		"$.name     1      11     1          0           array:1",
+		"$.name[*]  2      7      0          1           string:1 null:1",
+	}, "\n")+"\n", out.String())
+
+	b, err := json.Marshal(stats[1])
+	require.NoError(t, err)
+	assert.JSONEq(t, `{"path":"$.id","count":1,"bytes":1,"maxDepth":0,"maxStringLen":0,"types":{"number":1}}`, string(b))
+}
@@ -0,0 +1,449 @@
+package query
+
+import (
+	"cmp"
+	"encoding/json"
+	"fmt"
+	"math"
+	"slices"
+	"strconv"
+	"unicode/utf8"
+
+	"github.com/libxyz/gizmo/parsing/jsontokenizer/internal/jsonstr"
+)
+
+// 查询求值时的值为 nil、bool、json.Number、string、[]any 或 *orderedObject，
+// 对象保留成员顺序，数字保留原文
+
+// orderedObject 是保留成员顺序的对象
+type orderedObject struct {
+	keys []string
+	vals map[string]any
+}
+
+func newObject() *orderedObject {
+	return &orderedObject{vals: make(map[string]any)}
+}
+
+// set 设置成员的值，新成员追加在末尾
+func (o *orderedObject) set(k string, v any) {
+	if _, ok := o.vals[k]; !ok {
+		o.keys = append(o.keys, k)
+	}
+	o.vals[k] = v
+}
+
+// node 是查询表达式的语法树节点，eval 对输入 v 的每个结果调用 emit
+type node interface {
+	eval(v any, emit func(any) error) error
+}
+
+// identity 是 .
+type identity struct{}
+
+func (identity) eval(v any, emit func(any) error) error {
+	return emit(v)
+}
+
+// field 是 .name 或 .["name"]
+type field struct {
+	name string
+}
+
+func (f field) eval(v any, emit func(any) error) error {
+	switch v := v.(type) {
+	case nil:
+		return emit(nil)
+	case *orderedObject:
+		return emit(v.vals[f.name])
+	}
+	return fmt.Errorf("query: cannot index %s with %q", typeName(v), f.name)
+}
+
+// index 是 .[n]，负数从末尾计数
+type index struct {
+	i int
+}
+
+func (x index) eval(v any, emit func(any) error) error {
+	switch v := v.(type) {
+	case nil:
+		return emit(nil)
+	case []any:
+		i := x.i
+		if i < 0 {
+			i += len(v)
+		}
+		if i < 0 || i >= len(v) {
+			return emit(nil)
+		}
+		return emit(v[i])
+	}
+	return fmt.Errorf("query: cannot index %s with number", typeName(v))
+}
+
+// iterate 是 .[]
+type iterate struct{}
+
+func (iterate) eval(v any, emit func(any) error) error {
+	switch v := v.(type) {
+	case []any:
+		for _, e := range v {
+			if err := emit(e); err != nil {
+				return err
+			}
+		}
+		return nil
+	case *orderedObject:
+		for _, k := range v.keys {
+			if err := emit(v.vals[k]); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+	return fmt.Errorf("query: cannot iterate over %s", typeName(v))
+}
+
+// slice 是 .[from:to]，适用于数组和字符串
+type slice struct {
+	from, to       int
+	hasFrom, hasTo bool
+}
+
+// bounds 返回长度为 n 的序列中切片的范围
+func (s slice) bounds(n int) (int, int) {
+	clamp := func(i, def int, ok bool) int {
+		if !ok {
+			return def
+		}
+		if i < 0 {
+			i += n
+		}
+		return min(max(i, 0), n)
+	}
+	from, to := clamp(s.from, 0, s.hasFrom), clamp(s.to, n, s.hasTo)
+	return from, max(from, to)
+}
+
+func (s slice) eval(v any, emit func(any) error) error {
+	switch v := v.(type) {
+	case nil:
+		return emit(nil)
+	case []any:
+		from, to := s.bounds(len(v))
+		return emit(slices.Clone(v[from:to]))
+	case string:
+		// jq 按码点切分字符串
+		runes := []rune(v)
+		from, to := s.bounds(len(runes))
+		return emit(string(runes[from:to]))
+	}
+	return fmt.Errorf("query: cannot slice %s", typeName(v))
+}
+
+// pipe 是 l | r
+type pipe struct {
+	l, r node
+}
+
+func (p pipe) eval(v any, emit func(any) error) error {
+	return p.l.eval(v, func(x any) error {
+		return p.r.eval(x, emit)
+	})
+}
+
+// comma 是 l, r
+type comma struct {
+	l, r node
+}
+
+func (c comma) eval(v any, emit func(any) error) error {
+	if err := c.l.eval(v, emit); err != nil {
+		return err
+	}
+	return c.r.eval(v, emit)
+}
+
+// literal 是字符串、数字、true、false 或 null
+type literal struct {
+	v any
+}
+
+func (l literal) eval(_ any, emit func(any) error) error {
+	return emit(l.v)
+}
+
+// compare 是比较表达式，对两侧结果的每种组合输出一个布尔值
+type compare struct {
+	op   string
+	l, r node
+}
+
+func (c compare) eval(v any, emit func(any) error) error {
+	return c.r.eval(v, func(r any) error {
+		return c.l.eval(v, func(l any) error {
+			n := compareValues(l, r)
+			switch c.op {
+			case "==":
+				return emit(n == 0)
+			case "!=":
+				return emit(n != 0)
+			case "<":
+				return emit(n < 0)
+			case "<=":
+				return emit(n <= 0)
+			case ">":
+				return emit(n > 0)
+			}
+			return emit(n >= 0)
+		})
+	})
+}
+
+// and 是 l and r
+type and struct {
+	l, r node
+}
+
+func (a and) eval(v any, emit func(any) error) error {
+	return a.l.eval(v, func(l any) error {
+		if !truthy(l) {
+			return emit(false)
+		}
+		return a.r.eval(v, func(r any) error { return emit(truthy(r)) })
+	})
+}
+
+// or 是 l or r
+type or struct {
+	l, r node
+}
+
+func (o or) eval(v any, emit func(any) error) error {
+	return o.l.eval(v, func(l any) error {
+		if truthy(l) {
+			return emit(true)
+		}
+		return o.r.eval(v, func(r any) error { return emit(truthy(r)) })
+	})
+}
+
+// array 是 [body]，将 body 的全部结果收集为一个数组
+type array struct {
+	body node // [] 时为 nil
+}
+
+func (a array) eval(v any, emit func(any) error) error {
+	out := []any{}
+	if a.body != nil {
+		err := a.body.eval(v, func(x any) error {
+			out = append(out, x)
+			return nil
+		})
+		if err != nil {
+			return err
+		}
+	}
+	return emit(out)
+}
+
+// object 是 {key: value, ...}，值有多个结果时输出每种组合
+type object struct {
+	entries []entry
+}
+
+// entry 是对象构造中的一个成员
+type entry struct {
+	key   string
+	value node
+}
+
+func (o object) eval(v any, emit func(any) error) error {
+	return o.build(v, 0, newObject(), emit)
+}
+
+// build 依次求出第 i 个及之后成员的值
+func (o object) build(v any, i int, obj *orderedObject, emit func(any) error) error {
+	if i == len(o.entries) {
+		out := newObject()
+		for _, k := range obj.keys {
+			out.set(k, obj.vals[k])
+		}
+		return emit(out)
+	}
+	e := o.entries[i]
+	return e.value.eval(v, func(x any) error {
+		obj.set(e.key, x)
+		return o.build(v, i+1, obj, emit)
+	})
+}
+
+// builtin 是内置函数
+type builtin struct {
+	name string
+	arg  node // select 和 map 的参数
+}
+
+func (b builtin) eval(v any, emit func(any) error) error {
+	switch b.name {
+	case "select":
+		return b.arg.eval(v, func(x any) error {
+			if truthy(x) {
+				return emit(v)
+			}
+			return nil
+		})
+	case "map":
+		return array{pipe{iterate{}, b.arg}}.eval(v, emit)
+	case "not":
+		return emit(!truthy(v))
+	case "empty":
+		return nil
+	case "keys":
+		switch v := v.(type) {
+		case *orderedObject:
+			keys := make([]any, len(v.keys))
+			for i, k := range slices.Sorted(slices.Values(v.keys)) {
+				keys[i] = k
+			}
+			return emit(keys)
+		case []any:
+			keys := make([]any, len(v))
+			for i := range v {
+				keys[i] = json.Number(strconv.Itoa(i))
+			}
+			return emit(keys)
+		}
+		return fmt.Errorf("query: %s has no keys", typeName(v))
+	}
+
+	// length
+	switch v := v.(type) {
+	case nil:
+		return emit(json.Number("0"))
+	case json.Number:
+		f, _ := v.Float64()
+		return emit(formatNumber(math.Abs(f)))
+	case string:
+		return emit(json.Number(strconv.Itoa(utf8.RuneCountInString(v))))
+	case []any:
+		return emit(json.Number(strconv.Itoa(len(v))))
+	case *orderedObject:
+		return emit(json.Number(strconv.Itoa(len(v.keys))))
+	}
+	return fmt.Errorf("query: %s has no length", typeName(v))
+}
+
+// formatNumber 将计算得到的数字格式化为 json.Number
+func formatNumber(f float64) json.Number {
+	return json.Number(strconv.FormatFloat(f, 'g', -1, 64))
+}
+
+// truthy 判断值是否为真，只有 false 和 null 为假
+func truthy(v any) bool {
+	b, ok := v.(bool)
+	return v != nil && (!ok || b)
+}
+
+// typeName 返回值的 jq 类型名
+func typeName(v any) string {
+	switch v.(type) {
+	case nil:
+		return "null"
+	case bool:
+		return "boolean"
+	case json.Number:
+		return "number"
+	case string:
+		return "string"
+	case []any:
+		return "array"
+	}
+	return "object"
+}
+
+// typeOrder 返回类型在 jq 排序中的位置：null < false < true < 数字 < 字符串 < 数组 < 对象
+func typeOrder(v any) int {
+	switch v := v.(type) {
+	case nil:
+		return 0
+	case bool:
+		if v {
+			return 2
+		}
+		return 1
+	case json.Number:
+		return 3
+	case string:
+		return 4
+	case []any:
+		return 5
+	}
+	return 6
+}
+
+// compareValues 按 jq 的全序比较两个值
+func compareValues(a, b any) int {
+	if n := cmp.Compare(typeOrder(a), typeOrder(b)); n != 0 {
+		return n
+	}
+	switch a := a.(type) {
+	case json.Number:
+		x, _ := a.Float64()
+		y, _ := b.(json.Number).Float64()
+		return cmp.Compare(x, y)
+	case string:
+		return cmp.Compare(a, b.(string))
+	case []any:
+		return slices.CompareFunc(a, b.([]any), compareValues)
+	case *orderedObject:
+		// 先比较排序后的键名，再逐个比较对应的值
+		o := b.(*orderedObject)
+		ka, kb := slices.Sorted(slices.Values(a.keys)), slices.Sorted(slices.Values(o.keys))
+		if n := slices.Compare(ka, kb); n != 0 {
+			return n
+		}
+		for _, k := range ka {
+			if n := compareValues(a.vals[k], o.vals[k]); n != 0 {
+				return n
+			}
+		}
+	}
+	return 0
+}
+
+// appendValue 以紧凑的JSON形式追加值
+func appendValue(dst []byte, v any) []byte {
+	switch v := v.(type) {
+	case nil:
+		return append(dst, "null"...)
+	case bool:
+		return strconv.AppendBool(dst, v)
+	case json.Number:
+		return append(dst, v...)
+	case string:
+		return jsonstr.AppendQuote(dst, v)
+	case []any:
+		dst = append(dst, '[')
+		for i, e := range v {
+			if i > 0 {
+				dst = append(dst, ',')
+			}
+			dst = appendValue(dst, e)
+		}
+		return append(dst, ']')
+	case *orderedObject:
+		dst = append(dst, '{')
+		for i, k := range v.keys {
+			if i > 0 {
+				dst = append(dst, ',')
+			}
+			dst = jsonstr.AppendQuote(dst, k)
+			dst = append(dst, ':')
+			dst = appendValue(dst, v.vals[k])
+		}
+		return append(dst, '}')
+	}
+	panic(fmt.Sprintf("query: unexpected value %T", v))
+}
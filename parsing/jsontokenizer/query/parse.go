@@ -0,0 +1,438 @@
+package query
+
+import (
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// parser 是查询表达式的递归下降解析器
+type parser struct {
+	src string
+	pos int
+}
+
+// parse 解析完整的查询表达式
+func parse(src string) (node, error) {
+	p := &parser{src: src}
+	p.space()
+	if p.pos == len(p.src) {
+		return identity{}, nil
+	}
+	n, err := p.pipe(true)
+	if err != nil {
+		return nil, err
+	}
+	if p.pos < len(p.src) {
+		return nil, p.errorf("unexpected %q", p.src[p.pos:])
+	}
+	return n, nil
+}
+
+// errorf 返回带有当前位置的语法错误
+func (p *parser) errorf(format string, args ...any) error {
+	return fmt.Errorf("query: %s at offset %d", fmt.Sprintf(format, args...), p.pos)
+}
+
+// space 跳过空白
+func (p *parser) space() {
+	for p.pos < len(p.src) && strings.IndexByte(" \t\r\n", p.src[p.pos]) >= 0 {
+		p.pos++
+	}
+}
+
+// peek 返回当前字符，结束时返回 0
+func (p *parser) peek() byte {
+	if p.pos < len(p.src) {
+		return p.src[p.pos]
+	}
+	return 0
+}
+
+// consume 在当前位置为 s 时跳过它及其后的空白
+func (p *parser) consume(s string) bool {
+	if !strings.HasPrefix(p.src[p.pos:], s) {
+		return false
+	}
+	p.pos += len(s)
+	p.space()
+	return true
+}
+
+// expect 跳过 s，否则返回语法错误
+func (p *parser) expect(s string) error {
+	if !p.consume(s) {
+		if p.pos == len(p.src) {
+			return p.errorf("expected %q, found end of query", s)
+		}
+		return p.errorf("expected %q", s)
+	}
+	return nil
+}
+
+// keyword 在当前位置为完整的单词 word 时跳过它
+func (p *parser) keyword(word string) bool {
+	rest := p.src[p.pos:]
+	if !strings.HasPrefix(rest, word) || len(rest) > len(word) && isIdentByte(rest[len(word)]) {
+		return false
+	}
+	return p.consume(word)
+}
+
+// ident 读取一个标识符
+func (p *parser) ident() string {
+	start := p.pos
+	for p.pos < len(p.src) && isIdentByte(p.src[p.pos]) && (p.pos > start || !isDigit(p.src[p.pos])) {
+		p.pos++
+	}
+	s := p.src[start:p.pos]
+	p.space()
+	return s
+}
+
+// pipe 解析以 | 连接的表达式，comma 为 false 时不允许顶层的逗号，用于对象的值
+func (p *parser) pipe(comma bool) (node, error) {
+	parse := p.or
+	if comma {
+		parse = p.comma
+	}
+	l, err := parse()
+	if err != nil {
+		return nil, err
+	}
+	for p.peek() == '|' && !strings.HasPrefix(p.src[p.pos:], "|=") {
+		p.consume("|")
+		r, err := parse()
+		if err != nil {
+			return nil, err
+		}
+		l = pipe{l, r}
+	}
+	return l, nil
+}
+
+// comma 解析以逗号连接的表达式
+func (p *parser) comma() (node, error) {
+	l, err := p.or()
+	if err != nil {
+		return nil, err
+	}
+	for p.consume(",") {
+		r, err := p.or()
+		if err != nil {
+			return nil, err
+		}
+		l = comma{l, r}
+	}
+	return l, nil
+}
+
+func (p *parser) or() (node, error) {
+	l, err := p.and()
+	if err != nil {
+		return nil, err
+	}
+	for p.keyword("or") {
+		r, err := p.and()
+		if err != nil {
+			return nil, err
+		}
+		l = or{l, r}
+	}
+	return l, nil
+}
+
+func (p *parser) and() (node, error) {
+	l, err := p.compare()
+	if err != nil {
+		return nil, err
+	}
+	for p.keyword("and") {
+		r, err := p.compare()
+		if err != nil {
+			return nil, err
+		}
+		l = and{l, r}
+	}
+	return l, nil
+}
+
+// compare 解析比较表达式，比较运算符不能连用
+func (p *parser) compare() (node, error) {
+	l, err := p.postfix()
+	if err != nil {
+		return nil, err
+	}
+	for _, op := range []string{"==", "!=", "<=", ">=", "<", ">"} {
+		if p.consume(op) {
+			r, err := p.postfix()
+			if err != nil {
+				return nil, err
+			}
+			return compare{op, l, r}, nil
+		}
+	}
+	return l, nil
+}
+
+// postfix 解析一个项及其后的 .name、[...] 等后缀
+func (p *parser) postfix() (node, error) {
+	n, err := p.term()
+	if err != nil {
+		return nil, err
+	}
+	for {
+		switch {
+		case p.peek() == '[':
+			p.consume("[")
+			s, err := p.bracket()
+			if err != nil {
+				return nil, err
+			}
+			n = pipe{n, s}
+		case p.peek() == '.' && p.pos+1 < len(p.src) && (isIdentByte(p.src[p.pos+1]) || p.src[p.pos+1] == '"' || p.src[p.pos+1] == '['):
+			p.pos++
+			if p.peek() == '[' {
+				continue
+			}
+			s, err := p.field()
+			if err != nil {
+				return nil, err
+			}
+			n = pipe{n, s}
+		default:
+			return n, nil
+		}
+	}
+}
+
+// field 解析 . 之后的键名，可以是标识符或字符串
+func (p *parser) field() (node, error) {
+	if p.peek() == '"' {
+		s, err := p.string()
+		if err != nil {
+			return nil, err
+		}
+		return field{s}, nil
+	}
+	if !isIdentByte(p.peek()) || isDigit(p.peek()) {
+		return nil, p.errorf("expected field name")
+	}
+	return field{p.ident()}, nil
+}
+
+// bracket 解析 [ 之后的 ]、[n]、["key"] 或 [from:to]
+func (p *parser) bracket() (node, error) {
+	if p.consume("]") {
+		return iterate{}, nil
+	}
+	if p.peek() == '"' {
+		s, err := p.string()
+		if err != nil {
+			return nil, err
+		}
+		return field{s}, p.expect("]")
+	}
+
+	var sl slice
+	if p.peek() != ':' {
+		i, err := p.integer()
+		if err != nil {
+			return nil, err
+		}
+		if p.consume("]") {
+			return index{i}, nil
+		}
+		sl.from, sl.hasFrom = i, true
+	}
+	if err := p.expect(":"); err != nil {
+		return nil, err
+	}
+	if p.peek() != ']' {
+		i, err := p.integer()
+		if err != nil {
+			return nil, err
+		}
+		sl.to, sl.hasTo = i, true
+	}
+	return sl, p.expect("]")
+}
+
+// integer 读取一个可以为负的整数
+func (p *parser) integer() (int, error) {
+	start := p.pos
+	if p.peek() == '-' {
+		p.pos++
+	}
+	for isDigit(p.peek()) {
+		p.pos++
+	}
+	i, err := strconv.Atoi(p.src[start:p.pos])
+	if err != nil {
+		p.pos = start
+		return 0, p.errorf("expected integer")
+	}
+	p.space()
+	return i, nil
+}
+
+// term 解析不带后缀的项
+func (p *parser) term() (node, error) {
+	c := p.peek()
+	switch {
+	case c == 0:
+		return nil, p.errorf("unexpected end of query")
+	case c == '.':
+		p.pos++
+		switch {
+		case p.peek() == '.':
+			return nil, p.errorf("recursive descent is not supported")
+		case p.peek() == '"' || isIdentByte(p.peek()) && !isDigit(p.peek()):
+			return p.field()
+		}
+		p.space()
+		return identity{}, nil
+	case c == '"':
+		s, err := p.string()
+		if err != nil {
+			return nil, err
+		}
+		return literal{s}, nil
+	case isDigit(c) || c == '-' && p.pos+1 < len(p.src) && isDigit(p.src[p.pos+1]):
+		return p.number()
+	case c == '(':
+		p.consume("(")
+		n, err := p.pipe(true)
+		if err != nil {
+			return nil, err
+		}
+		return n, p.expect(")")
+	case c == '[':
+		p.consume("[")
+		if p.consume("]") {
+			return array{}, nil
+		}
+		n, err := p.pipe(true)
+		if err != nil {
+			return nil, err
+		}
+		return array{n}, p.expect("]")
+	case c == '{':
+		p.consume("{")
+		return p.object()
+	case isIdentByte(c) && !isDigit(c):
+		return p.call()
+	}
+	return nil, p.errorf("unexpected %q", c)
+}
+
+// call 解析字面量关键字和内置函数
+func (p *parser) call() (node, error) {
+	start := p.pos
+	name := p.ident()
+	switch name {
+	case "true":
+		return literal{true}, nil
+	case "false":
+		return literal{false}, nil
+	case "null":
+		return literal{nil}, nil
+	case "length", "keys", "not", "empty":
+		return builtin{name: name}, nil
+	case "select", "map":
+		if err := p.expect("("); err != nil {
+			return nil, err
+		}
+		arg, err := p.pipe(true)
+		if err != nil {
+			return nil, err
+		}
+		return builtin{name: name, arg: arg}, p.expect(")")
+	}
+	p.pos = start
+	return nil, p.errorf("unknown function %q", name)
+}
+
+// object 解析 { 之后的对象构造
+func (p *parser) object() (node, error) {
+	var o object
+	for !p.consume("}") {
+		if len(o.entries) > 0 {
+			if err := p.expect(","); err != nil {
+				return nil, err
+			}
+		}
+		var key string
+		switch c := p.peek(); {
+		case c == '"':
+			s, err := p.string()
+			if err != nil {
+				return nil, err
+			}
+			key = s
+		case isIdentByte(c) && !isDigit(c):
+			key = p.ident()
+		default:
+			return nil, p.errorf("expected object key")
+		}
+		if !p.consume(":") {
+			// {name} 是 {name: .name} 的简写
+			o.entries = append(o.entries, entry{key, field{key}})
+			continue
+		}
+		v, err := p.pipe(false)
+		if err != nil {
+			return nil, err
+		}
+		o.entries = append(o.entries, entry{key, v})
+	}
+	return o, nil
+}
+
+// string 读取一个JSON字符串字面量
+func (p *parser) string() (string, error) {
+	start := p.pos
+	p.pos++
+	for p.pos < len(p.src) && p.src[p.pos] != '"' {
+		if p.src[p.pos] == '\\' {
+			p.pos++
+		}
+		p.pos++
+	}
+	if p.pos >= len(p.src) {
+		p.pos = start
+		return "", p.errorf("unterminated string")
+	}
+	p.pos++
+	var s string
+	if err := json.Unmarshal([]byte(p.src[start:p.pos]), &s); err != nil {
+		p.pos = start
+		return "", p.errorf("invalid string %s", p.src[start:p.pos])
+	}
+	p.space()
+	return s, nil
+}
+
+// number 读取一个数字字面量，保留其原文
+func (p *parser) number() (node, error) {
+	start := p.pos
+	for p.pos < len(p.src) && strings.IndexByte("+-.eE0123456789", p.src[p.pos]) >= 0 {
+		p.pos++
+	}
+	text := p.src[start:p.pos]
+	if _, err := strconv.ParseFloat(text, 64); err != nil || !json.Valid([]byte(text)) {
+		p.pos = start
+		return nil, p.errorf("invalid number %s", text)
+	}
+	p.space()
+	return literal{json.Number(text)}, nil
+}
+
+func isIdentByte(c byte) bool {
+	return c == '_' || 'a' <= c && c <= 'z' || 'A' <= c && c <= 'Z' || isDigit(c)
+}
+
+func isDigit(c byte) bool {
+	return '0' <= c && c <= '9'
+}
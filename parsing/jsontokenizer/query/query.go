@@ -0,0 +1,279 @@
+// Package query evaluates a practical subset of the jq language over JSON
+// read with jsontokenizer.
+//
+// Supported are the identity ., field access .a.b and .["key"], indexing
+// .[n] (negative from the end), iteration .[], slices .[from:to], pipes |,
+// commas, parentheses, literals, comparisons == != < <= > >= with jq's
+// ordering of values, and, or, array construction [...], object construction
+// {name: .n, id} and the functions select(f), map(f), length, keys, not and
+// empty.
+//
+// Queries run over the token stream. The leading field accesses, indexes,
+// iterations and slices of a query are followed in the stream without
+// buffering, skipping everything they do not select, and only the selected
+// values are buffered to evaluate the rest of the query. A query such as
+//
+//	.items[] | select(.price > 10) | {name, price}
+//
+// therefore needs memory for one element of items at a time, however long the
+// array is.
+package query
+
+import (
+	"encoding/json"
+	"errors"
+	"io"
+
+	"github.com/libxyz/gizmo/parsing/jsontokenizer"
+)
+
+// Query is a parsed query.
+type Query struct {
+	src   string
+	steps []node // 可以在Token流中直接跟随的前导步骤
+	rest  node   // 其余部分，对选中的值求值
+}
+
+// Parse parses a query.
+func Parse(src string) (*Query, error) {
+	n, err := parse(src)
+	if err != nil {
+		return nil, err
+	}
+	q := &Query{src: src}
+	terms := flatten(n, nil)
+	for len(terms) > 0 && streamable(terms[0]) {
+		q.steps = append(q.steps, terms[0])
+		terms = terms[1:]
+	}
+	q.rest = identity{}
+	for i, t := range terms {
+		if i == 0 {
+			q.rest = t
+		} else {
+			q.rest = pipe{q.rest, t}
+		}
+	}
+	return q, nil
+}
+
+// String returns the source of the query.
+func (q *Query) String() string {
+	return q.src
+}
+
+// Run evaluates the query on every JSON value read from r and calls emit with
+// each result in compact form. The text of numbers is kept, and objects keep
+// the order of their members.
+func (q *Query) Run(r io.Reader, emit func(json.RawMessage) error) error {
+	dec := jsontokenizer.NewDecoder(r)
+	dec.UseNumber()
+	out := func(v any) error {
+		return emit(appendValue(nil, v))
+	}
+	for {
+		first, err := dec.Token()
+		if errors.Is(err, io.EOF) {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		if err := q.walk(dec, first, q.steps, out); err != nil {
+			return err
+		}
+	}
+}
+
+// flatten 将管道展开为依次求值的项
+func flatten(n node, terms []node) []node {
+	if p, ok := n.(pipe); ok {
+		return flatten(p.r, flatten(p.l, terms))
+	}
+	if _, ok := n.(identity); ok {
+		return terms
+	}
+	return append(terms, n)
+}
+
+// streamable 判断步骤能否在Token流中直接跟随
+func streamable(n node) bool {
+	switch n := n.(type) {
+	case field, iterate:
+		return true
+	case index:
+		return n.i >= 0
+	case slice:
+		return n.from >= 0 && n.to >= 0
+	}
+	return false
+}
+
+// walk 从以 first 开头的值出发，在Token流中跟随 steps，
+// 对选中的值缓冲后求出查询的其余部分
+func (q *Query) walk(dec *jsontokenizer.Decoder, first json.Token, steps []node, out func(any) error) error {
+	if len(steps) == 0 {
+		return q.buffered(dec, first, steps, out)
+	}
+	switch s := steps[0].(type) {
+	case field:
+		if first != json.Delim('{') {
+			break
+		}
+		found := false
+		err := members(dec, func(key string, vfirst json.Token) error {
+			if found || key != s.name {
+				return skip(dec, vfirst)
+			}
+			found = true
+			return q.walk(dec, vfirst, steps[1:], out)
+		})
+		if err != nil || found {
+			return err
+		}
+		return q.evalSteps(nil, steps[1:], out)
+	case index:
+		if first != json.Delim('[') {
+			break
+		}
+		found := false
+		err := elements(dec, func(i int, efirst json.Token) error {
+			if i != s.i {
+				return skip(dec, efirst)
+			}
+			found = true
+			return q.walk(dec, efirst, steps[1:], out)
+		})
+		if err != nil || found {
+			return err
+		}
+		return q.evalSteps(nil, steps[1:], out)
+	case iterate:
+		switch first {
+		case json.Delim('['):
+			return elements(dec, func(_ int, efirst json.Token) error {
+				return q.walk(dec, efirst, steps[1:], out)
+			})
+		case json.Delim('{'):
+			return members(dec, func(_ string, vfirst json.Token) error {
+				return q.walk(dec, vfirst, steps[1:], out)
+			})
+		}
+	case slice:
+		if first != json.Delim('[') {
+			break
+		}
+		// 只缓冲范围内的元素
+		arr := []any{}
+		err := elements(dec, func(i int, efirst json.Token) error {
+			if i < s.from || s.hasTo && i >= s.to {
+				return skip(dec, efirst)
+			}
+			v, err := decode(dec, efirst)
+			arr = append(arr, v)
+			return err
+		})
+		if err != nil {
+			return err
+		}
+		return q.evalSteps(arr, steps[1:], out)
+	}
+	// 其他类型的值按内存中的语义求值，包括报告错误
+	return q.buffered(dec, first, steps, out)
+}
+
+// buffered 读取整个值，再对其求出 steps 和查询的其余部分
+func (q *Query) buffered(dec *jsontokenizer.Decoder, first json.Token, steps []node, out func(any) error) error {
+	v, err := decode(dec, first)
+	if err != nil {
+		return err
+	}
+	return q.evalSteps(v, steps, out)
+}
+
+// evalSteps 在内存中对 v 依次求出 steps 和查询的其余部分
+func (q *Query) evalSteps(v any, steps []node, out func(any) error) error {
+	if len(steps) == 0 {
+		return q.rest.eval(v, out)
+	}
+	return steps[0].eval(v, func(x any) error {
+		return q.evalSteps(x, steps[1:], out)
+	})
+}
+
+// members 对 { 之后的每个成员调用 fn，fn 需要读完成员的值
+func members(dec *jsontokenizer.Decoder, fn func(key string, first json.Token) error) error {
+	for dec.More() {
+		key, err := dec.Token()
+		if err != nil {
+			return err
+		}
+		first, err := dec.Token()
+		if err != nil {
+			return err
+		}
+		if err := fn(key.(string), first); err != nil {
+			return err
+		}
+	}
+	_, err := dec.Token()
+	return err
+}
+
+// elements 对 [ 之后的每个元素调用 fn，fn 需要读完元素
+func elements(dec *jsontokenizer.Decoder, fn func(i int, first json.Token) error) error {
+	for i := 0; dec.More(); i++ {
+		first, err := dec.Token()
+		if err != nil {
+			return err
+		}
+		if err := fn(i, first); err != nil {
+			return err
+		}
+	}
+	_, err := dec.Token()
+	return err
+}
+
+// skip 跳过以 first 开头的值的其余部分
+func skip(dec *jsontokenizer.Decoder, first json.Token) error {
+	if first != json.Delim('{') && first != json.Delim('[') {
+		return nil
+	}
+	for depth := 1; depth > 0; {
+		tk, err := dec.Token()
+		if err != nil {
+			return err
+		}
+		switch tk {
+		case json.Delim('{'), json.Delim('['):
+			depth++
+		case json.Delim('}'), json.Delim(']'):
+			depth--
+		}
+	}
+	return nil
+}
+
+// decode 读取以 first 开头的值的其余部分
+func decode(dec *jsontokenizer.Decoder, first json.Token) (any, error) {
+	switch first {
+	case json.Delim('{'):
+		obj := newObject()
+		err := members(dec, func(key string, vfirst json.Token) error {
+			v, err := decode(dec, vfirst)
+			obj.set(key, v)
+			return err
+		})
+		return obj, err
+	case json.Delim('['):
+		arr := []any{}
+		err := elements(dec, func(_ int, efirst json.Token) error {
+			v, err := decode(dec, efirst)
+			arr = append(arr, v)
+			return err
+		})
+		return arr, err
+	}
+	return first, nil
+}
@@ -0,0 +1,185 @@
+package query
+
+import (
+	"encoding/json"
+	"errors"
+	"io"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// run 对输入求值，返回每个结果的紧凑形式
+func run(t *testing.T, src, input string) ([]string, error) {
+	t.Helper()
+	q, err := Parse(src)
+	require.NoError(t, err)
+	var out []string
+	err = q.Run(strings.NewReader(input), func(raw json.RawMessage) error {
+		out = append(out, string(raw))
+		return nil
+	})
+	return out, err
+}
+
+func TestRun(t *testing.T) {
+	doc := `{"store": {"name": "corner", "items": [
+		{"n": "apple", "price": 3, "tags": ["fruit"]},
+		{"n": "bread", "price": 4.50, "tags": []},
+		{"n": "cheese", "price": 12, "tags": ["dairy", "aged"]}
+	]}, "open": true}`
+	tests := []struct {
+		query string
+		want  []string
+	}{
+		{`.`, []string{`{"store":{"name":"corner","items":[{"n":"apple","price":3,"tags":["fruit"]},{"n":"bread","price":4.50,"tags":[]},{"n":"cheese","price":12,"tags":["dairy","aged"]}]},"open":true}`}},
+		{`.store.name`, []string{`"corner"`}},
+		{`.store["name"]`, []string{`"corner"`}},
+		{`.missing`, []string{`null`}},
+		{`.missing.deeper`, []string{`null`}},
+		{`.store.items[1].n`, []string{`"bread"`}},
+		{`.store.items[-1].n`, []string{`"cheese"`}},
+		{`.store.items[5]`, []string{`null`}},
+		{`.store.items[].n`, []string{`"apple"`, `"bread"`, `"cheese"`}},
+		{`.store.items | .[] | .price`, []string{`3`, `4.50`, `12`}},
+		{`.store.items[1:] | map(.n)`, []string{`["bread","cheese"]`}},
+		{`.store.items[:1] | length`, []string{`1`}},
+		{`.store.items[-2:] | map(.price)`, []string{`[4.50,12]`}},
+		{`.store.name[1:3]`, []string{`"or"`}},
+		{`.store.items[] | select(.price > 3) | .n`, []string{`"bread"`, `"cheese"`}},
+		{`.store.items[] | select(.price >= 4.5 and (.tags | length) == 0) | .n`, []string{`"bread"`}},
+		{`.store.items[] | select(.n == "apple" or .price < 4) | {name: .n, price}`, []string{`{"name":"apple","price":3}`}},
+		{`.store.items[] | {"item": .n, tag: .tags[]}`, []string{`{"item":"apple","tag":"fruit"}`, `{"item":"cheese","tag":"dairy"}`, `{"item":"cheese","tag":"aged"}`}},
+		{`.store.items | map(.tags | length)`, []string{`[1,0,2]`}},
+		{`[.store.items[].price]`, []string{`[3,4.50,12]`}},
+		{`.store | keys`, []string{`["items","name"]`}},
+		{`.store.items | keys`, []string{`[0,1,2]`}},
+		{`.store | length`, []string{`2`}},
+		{`.store.name | length`, []string{`6`}},
+		{`.open | not`, []string{`false`}},
+		{`.store.name, .open`, []string{`"corner"`, `true`}},
+		{`[.store.items[] | .n, empty]`, []string{`["apple","bread","cheese"]`}},
+		{`{a: 1, b: (2, 3)}`, []string{`{"a":1,"b":2}`, `{"a":1,"b":3}`}},
+		{`[1, "a", null, [2], {}, false] | map(. == null)`, []string{`[false,false,true,false,false,false]`}},
+		{`null < false and false < true and true < 0 and 0 < "" and "" < [] and [] < {}`, []string{`true`}},
+		{`[1.0 == 1, [1, 2] < [1, 3], {"a": 2} > {"a": 1}, "b" >= "ab"]`, []string{`[true,true,true,true]`}},
+		{`"tab\there" | length`, []string{`8`}},
+		{`"line\nbreak\u0001"`, []string{`"line\nbreak\u0001"`}},
+		{`-3.5 | length`, []string{`3.5`}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.query, func(t *testing.T) {
+			got, err := run(t, tt.query, doc)
+			require.NoError(t, err)
+			assert.Equal(t, tt.want, got)
+		})
+	}
+}
+
+func TestRun_Quoting(t *testing.T) {
+	got, err := run(t, `., "\b\f\u007f"`, "\"a\xff\\\"\u2028\"")
+	require.NoError(t, err)
+	assert.Equal(t, []string{"\"a\uFFFD\\\"\u2028\"", "\"\\b\\f\x7f\""}, got)
+}
+
+func TestRun_MultipleInputs(t *testing.T) {
+	got, err := run(t, `.a`, `{"a": 1} {"b": 2} [] `)
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "cannot index array with \"a\"")
+	assert.Equal(t, []string{`1`, `null`}, got)
+
+	got, err = run(t, `.[]`, "[1, 2]\n{\"x\": [3]}\n\"s\"")
+	assert.EqualError(t, err, "query: cannot iterate over string")
+	assert.Equal(t, []string{`1`, `2`, `[3]`}, got)
+}
+
+func TestRun_Errors(t *testing.T) {
+	tests := []struct {
+		query, input, err string
+	}{
+		{`.a`, `"s"`, `query: cannot index string with "a"`},
+		{`.[0]`, `{"a": 1}`, `query: cannot index object with number`},
+		{`.[]`, `null`, `query: cannot iterate over null`},
+		{`.[1:]`, `{}`, `query: cannot slice object`},
+		{`.[1:].n`, `[1, 2]`, `query: cannot index array with "n"`},
+		{`keys`, `1`, `query: number has no keys`},
+		{`length`, `true`, `query: boolean has no length`},
+		{`.a[]`, `{"a": [1, }`, `jsontokenizer: unexpected '}'`},
+	}
+	for _, tt := range tests {
+		t.Run(tt.query, func(t *testing.T) {
+			_, err := run(t, tt.query, tt.input)
+			require.Error(t, err)
+			assert.Contains(t, err.Error(), tt.err)
+		})
+	}
+}
+
+func TestParse_Errors(t *testing.T) {
+	tests := []struct {
+		query, err string
+	}{
+		{`.a |`, `query: unexpected end of query at offset 4`},
+		{`.a]`, `query: unexpected "]" at offset 2`},
+		{`..`, `query: recursive descent is not supported at offset 1`},
+		{`select(.a`, `query: expected ")", found end of query at offset 9`},
+		{`sort`, `query: unknown function "sort" at offset 0`},
+		{`.[1:x]`, `query: expected integer at offset 4`},
+		{`{1: 2}`, `query: expected object key at offset 1`},
+		{`"abc`, `query: unterminated string at offset 0`},
+		{`.a |= 1`, `query: unexpected "|= 1" at offset 3`},
+		{`1.2.3`, `query: invalid number 1.2.3 at offset 0`},
+	}
+	for _, tt := range tests {
+		t.Run(tt.query, func(t *testing.T) {
+			_, err := Parse(tt.query)
+			assert.EqualError(t, err, tt.err)
+		})
+	}
+}
+
+// TestRun_Streaming 检查结果在输入结束之前就已输出
+func TestRun_Streaming(t *testing.T) {
+	q, err := Parse(`.rows[] | select(.ok) | .id`)
+	require.NoError(t, err)
+	assert.Equal(t, []node{field{"rows"}, iterate{}}, q.steps)
+
+	pr, pw := io.Pipe()
+	results := make(chan string)
+	done := make(chan error, 1)
+	go func() {
+		done <- q.Run(pr, func(raw json.RawMessage) error {
+			results <- string(raw)
+			return nil
+		})
+	}()
+
+	go func() {
+		_, _ = io.WriteString(pw, `{"skipped": {"deep": [1, [2, {"x": 3}]]}, "rows": [{"id": 1, "ok": true}, `)
+	}()
+	assert.Equal(t, "1", <-results)
+	go func() {
+		_, _ = io.WriteString(pw, `{"id": 2, "ok": false}, {"id": 3, "ok": true}`)
+	}()
+	assert.Equal(t, "3", <-results)
+	go func() {
+		_, _ = io.WriteString(pw, `], "after": 1}`)
+		pw.Close()
+	}()
+	require.NoError(t, <-done)
+}
+
+func TestRun_EmitError(t *testing.T) {
+	q, err := Parse(`.[]`)
+	require.NoError(t, err)
+	stop := errors.New("stop")
+	n := 0
+	err = q.Run(strings.NewReader(`[1, 2, 3]`), func(json.RawMessage) error {
+		n++
+		return stop
+	})
+	assert.ErrorIs(t, err, stop)
+	assert.Equal(t, 1, n)
+}
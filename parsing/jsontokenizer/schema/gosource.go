@@ -0,0 +1,227 @@
+package schema
+
+import (
+	"bytes"
+	"fmt"
+	"go/format"
+	"strconv"
+	"strings"
+	"unicode"
+)
+
+// GoSource returns gofmt-formatted Go source in package pkg that declares a
+// type named name for the samples, with a struct type for every object shape
+// and json tags that round-trip the member names.
+//
+// Members missing from some samples get the omitempty option, and those
+// holding structs become pointers so that it takes effect, as do values that
+// are also null. Integers become int64, other numbers
+// float64 and date-time strings time.Time. Paths whose values have several
+// types, and arrays whose elements were never seen, use any. Nested struct
+// types are named after the member holding them, in singular form for array
+// elements.
+func (inf *Inferrer) GoSource(pkg, name string) ([]byte, error) {
+	g := &generator{names: map[string]bool{name: true}}
+	var decls bytes.Buffer
+	if types := inf.root.types(); len(types) == 1 && types[0] == kindObject && len(inf.root.keys) > 0 {
+		g.structs = append(g.structs, structType{name: name, n: inf.root})
+	} else {
+		fmt.Fprintf(&decls, "\ntype %s %s\n", name, g.goType(inf.root, name, "", false))
+	}
+	for i := 0; i < len(g.structs); i++ {
+		g.declare(&decls, g.structs[i])
+	}
+
+	var src bytes.Buffer
+	fmt.Fprintf(&src, "package %s\n", pkg)
+	if g.usesTime {
+		src.WriteString("\nimport \"time\"\n")
+	}
+	src.Write(decls.Bytes())
+	return format.Source(src.Bytes())
+}
+
+// generator 生成 Go 类型声明
+type generator struct {
+	structs  []structType // 按发现的顺序
+	names    map[string]bool
+	usesTime bool
+}
+
+// structType 是待生成的结构体
+type structType struct {
+	name string
+	n    *node
+}
+
+// goType 返回 n 对应的 Go 类型，结构体以 name 命名，重名时加上 parent 前缀
+// optional 表示成员可能缺失，此时结构体使用指针以便 omitempty 生效
+func (g *generator) goType(n *node, name, parent string, optional bool) string {
+	types := n.types()
+	if len(types) > 0 && types[0] == kindNull {
+		types = types[1:]
+	}
+	if len(types) != 1 {
+		return "any"
+	}
+
+	var typ string
+	switch types[0] {
+	case kindBoolean:
+		typ = "bool"
+	case kindInteger:
+		typ = "int64"
+		if !n.integral() {
+			typ = "float64"
+		}
+	case kindNumber:
+		typ = "float64"
+	case kindString:
+		typ = "string"
+		if n.format == "date-time" {
+			typ = "time.Time"
+			g.usesTime = true
+		}
+	case kindObject:
+		if len(n.keys) == 0 {
+			return "map[string]any"
+		}
+		typ = g.structName(name, parent)
+		g.structs = append(g.structs, structType{name: typ, n: n})
+	case kindArray:
+		if n.items == nil {
+			return "[]any"
+		}
+		return "[]" + g.goType(n.items, singular(name), parent, false)
+	case kindNull, numKinds:
+	}
+	if n.kinds[kindNull] > 0 || optional && (types[0] == kindObject || typ == "time.Time") {
+		return "*" + typ
+	}
+	return typ
+}
+
+// structName 返回未使用的结构体类型名
+func (g *generator) structName(name, parent string) string {
+	candidate := name
+	if g.names[candidate] {
+		candidate = parent + name
+	}
+	for i := 2; g.names[candidate]; i++ {
+		candidate = parent + name + strconv.Itoa(i)
+	}
+	g.names[candidate] = true
+	return candidate
+}
+
+// declare 输出结构体的声明
+func (g *generator) declare(w *bytes.Buffer, s structType) {
+	fmt.Fprintf(w, "\ntype %s struct {\n", s.name)
+	fields := make(map[string]bool)
+	for _, k := range s.n.keys {
+		if !validTag(k) {
+			fmt.Fprintf(w, "// member %s cannot be named in a json tag\n", strconv.Quote(k))
+			continue
+		}
+		field := fieldName(k)
+		for i := 2; fields[field]; i++ {
+			field = fieldName(k) + strconv.Itoa(i)
+		}
+		fields[field] = true
+
+		tag := k
+		optional := !s.n.required(k)
+		if optional {
+			tag += ",omitempty"
+		} else if k == "-" {
+			tag += "," // 单独的 - 表示忽略字段
+		}
+		fmt.Fprintf(w, "%s %s `json:%s`\n", field, g.goType(s.n.props[k], field, s.name, optional), strconv.Quote(tag))
+	}
+	w.WriteString("}\n")
+}
+
+// initialisms 是字段名中整体大写的缩写
+var initialisms = map[string]bool{
+	"API": true, "ASCII": true, "CPU": true, "CSS": true, "DNS": true, "HTML": true, "HTTP": true,
+	"HTTPS": true, "ID": true, "IP": true, "JSON": true, "SQL": true, "SSH": true, "TCP": true,
+	"TLS": true, "TTL": true, "UDP": true, "UI": true, "URI": true, "URL": true, "UTF8": true,
+	"UUID": true, "XML": true,
+}
+
+// fieldName 将成员名转换为导出的 Go 标识符，如 user_id 和 userId 都转换为 UserID
+func fieldName(key string) string {
+	var b strings.Builder
+	for _, word := range splitWords(key) {
+		if upper := strings.ToUpper(word); initialisms[upper] {
+			b.WriteString(upper)
+			continue
+		}
+		r := []rune(word)
+		b.WriteRune(unicode.ToUpper(r[0]))
+		b.WriteString(string(r[1:]))
+	}
+	name := b.String()
+	if name == "" {
+		return "Field"
+	}
+	if !unicode.IsUpper([]rune(name)[0]) {
+		return "X" + name
+	}
+	return name
+}
+
+// splitWords 在非字母数字字符和小写到大写的转换处拆分单词
+func splitWords(s string) []string {
+	var words []string
+	var cur []rune
+	var prev rune
+	for _, r := range s {
+		if !unicode.IsLetter(r) && !unicode.IsDigit(r) {
+			if len(cur) > 0 {
+				words = append(words, string(cur))
+			}
+			cur, prev = nil, 0
+			continue
+		}
+		if unicode.IsUpper(r) && (unicode.IsLower(prev) || unicode.IsDigit(prev)) {
+			words = append(words, string(cur))
+			cur = nil
+		}
+		cur = append(cur, r)
+		prev = r
+	}
+	if len(cur) > 0 {
+		words = append(words, string(cur))
+	}
+	return words
+}
+
+// singular 返回数组元素的类型名，如 Users 的元素为 User
+func singular(name string) string {
+	switch {
+	case strings.HasSuffix(name, "ies") && len(name) > 3:
+		return name[:len(name)-3] + "y"
+	case strings.HasSuffix(name, "sses"), strings.HasSuffix(name, "xes"), strings.HasSuffix(name, "ches"),
+		strings.HasSuffix(name, "shes"):
+		return name[:len(name)-2]
+	case strings.HasSuffix(name, "s") && !strings.HasSuffix(name, "ss") && len(name) > 1:
+		return name[:len(name)-1]
+	}
+	return name + "Item"
+}
+
+// validTag 判断成员名能否用作 json tag 中的名称，规则与 encoding/json 相同
+func validTag(s string) bool {
+	if s == "" {
+		return false
+	}
+	for _, c := range s {
+		switch {
+		case strings.ContainsRune("!#$%&()*+-./:;<=>?@[]^_{|}~ ", c):
+		case !unicode.IsLetter(c) && !unicode.IsDigit(c):
+			return false
+		}
+	}
+	return true
+}
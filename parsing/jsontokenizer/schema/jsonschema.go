@@ -0,0 +1,143 @@
+package schema
+
+import (
+	"bytes"
+
+	"github.com/libxyz/gizmo/parsing/jsontokenizer"
+	"github.com/libxyz/gizmo/parsing/jsontokenizer/internal/jsonstr"
+)
+
+// Dialect is the JSON Schema dialect declared by JSONSchema.
+const Dialect = "https://json-schema.org/draft/2020-12/schema"
+
+// JSONSchema returns an indented JSON Schema that every sample satisfies.
+// Object members are listed in the order first seen, and those present in
+// every sample of their object are required.
+func (inf *Inferrer) JSONSchema() ([]byte, error) {
+	o := &object{}
+	o.key("$schema")
+	o.b = jsonstr.AppendQuote(o.b, Dialect)
+	inf.schema(o, inf.root)
+	o.end()
+
+	var out bytes.Buffer
+	if err := jsontokenizer.Format(bytes.NewReader(o.b), &out, jsontokenizer.FormatOptions{}); err != nil {
+		return nil, err
+	}
+	return out.Bytes(), nil
+}
+
+// schema 将 n 的约束作为成员追加到 o
+func (inf *Inferrer) schema(o *object, n *node) {
+	types := n.types()
+	switch len(types) {
+	case 0:
+		return
+	case 1:
+		o.key("type")
+		o.b = jsonstr.AppendQuote(o.b, kindNames[types[0]])
+	default:
+		o.key("type")
+		o.b = append(o.b, '[')
+		for i, k := range types {
+			if i > 0 {
+				o.b = append(o.b, ',')
+			}
+			o.b = jsonstr.AppendQuote(o.b, kindNames[k])
+		}
+		o.b = append(o.b, ']')
+	}
+
+	if n.kinds[kindString] > 0 {
+		if n.format != "" {
+			o.key("format")
+			o.b = jsonstr.AppendQuote(o.b, n.format)
+		}
+		// 只有字符串和 null 时才能用枚举描述全部取值
+		if enum := n.enum(); enum != nil && len(types) == 1+min(n.kinds[kindNull], 1) {
+			o.key("enum")
+			o.b = append(o.b, '[')
+			for i, v := range enum {
+				if i > 0 {
+					o.b = append(o.b, ',')
+				}
+				o.b = jsonstr.AppendQuote(o.b, v)
+			}
+			if n.kinds[kindNull] > 0 {
+				o.b = append(o.b, ",null"...)
+			}
+			o.b = append(o.b, ']')
+		}
+	}
+	if n.minRaw != "" {
+		o.key("minimum")
+		o.b = append(o.b, n.minRaw...)
+		o.key("maximum")
+		o.b = append(o.b, n.maxRaw...)
+	}
+
+	if n.kinds[kindObject] > 0 {
+		o.key("properties")
+		props := &object{b: o.b}
+		for _, k := range n.keys {
+			props.key(k)
+			child := &object{b: props.b}
+			inf.schema(child, n.props[k])
+			child.end()
+			props.b = child.b
+		}
+		props.end()
+		o.b = props.b
+
+		var required []string
+		for _, k := range n.keys {
+			if n.required(k) {
+				required = append(required, k)
+			}
+		}
+		if required != nil {
+			o.key("required")
+			o.b = append(o.b, '[')
+			for i, k := range required {
+				if i > 0 {
+					o.b = append(o.b, ',')
+				}
+				o.b = jsonstr.AppendQuote(o.b, k)
+			}
+			o.b = append(o.b, ']')
+		}
+	}
+	if n.items != nil {
+		o.key("items")
+		items := &object{b: o.b}
+		inf.schema(items, n.items)
+		items.end()
+		o.b = items.b
+	}
+}
+
+// object 以紧凑形式逐个追加对象的成员
+type object struct {
+	b []byte
+	n int // 已追加的成员数
+}
+
+// key 开始一个成员，之后追加它的值
+func (o *object) key(k string) {
+	if o.n == 0 {
+		o.b = append(o.b, '{')
+	} else {
+		o.b = append(o.b, ',')
+	}
+	o.n++
+	o.b = jsonstr.AppendQuote(o.b, k)
+	o.b = append(o.b, ':')
+}
+
+// end 结束对象
+func (o *object) end() {
+	if o.n == 0 {
+		o.b = append(o.b, '{')
+	}
+	o.b = append(o.b, '}')
+}
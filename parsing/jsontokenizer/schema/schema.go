@@ -0,0 +1,278 @@
+// Package schema infers the shape of JSON documents from samples read with
+// jsontokenizer and describes it as a JSON Schema or as Go type declarations.
+//
+// An Inferrer records, for every path of the samples, the types seen, whether
+// object members are always present, the distinct values of strings that
+// repeat often enough to be an enumeration, a string format shared by all
+// values (date-time, date, uuid or email), the range of numbers and the merged
+// shape of array elements. Samples are read token by token, so memory depends
+// on the number of distinct paths rather than the size of the samples:
+//
+//	inf := schema.NewInferrer(schema.Options{})
+//	for _, f := range files {
+//		if err := inf.Add(f); err != nil {
+//			return err
+//		}
+//	}
+//	src, err := inf.GoSource("api", "Event")
+package schema
+
+import (
+	"encoding/json"
+	"errors"
+	"io"
+	"math"
+	"net/mail"
+	"regexp"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/libxyz/gizmo/parsing/jsontokenizer"
+)
+
+// DefaultMaxEnum is the number of distinct values up to which strings are
+// reported as an enumeration if Options.MaxEnum is zero.
+const DefaultMaxEnum = 10
+
+// Options configures an Inferrer.
+type Options struct {
+	// MaxEnum is the largest number of distinct values of a string path that
+	// is reported as an enumeration, DefaultMaxEnum if zero and none if
+	// negative. A path is an enumeration only if its values repeat, on
+	// average at least twice each, and they share no format.
+	MaxEnum int
+}
+
+// Inferrer accumulates the shape of sample documents.
+type Inferrer struct {
+	opts Options
+	root *node
+	docs int
+}
+
+// NewInferrer returns an Inferrer without samples.
+func NewInferrer(opts Options) *Inferrer {
+	if opts.MaxEnum == 0 {
+		opts.MaxEnum = DefaultMaxEnum
+	}
+	return &Inferrer{opts: opts, root: newNode()}
+}
+
+// Add reads every JSON value from r, such as a single document or NDJSON, and
+// adds each as a sample. Values read before an error are kept.
+func (inf *Inferrer) Add(r io.Reader) error {
+	dec := jsontokenizer.NewDecoder(r)
+	dec.UseNumber()
+	for {
+		first, err := dec.Token()
+		if errors.Is(err, io.EOF) {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		if err := inf.observe(dec, first, inf.root); err != nil {
+			return err
+		}
+		inf.docs++
+	}
+}
+
+// Samples returns the number of values added.
+func (inf *Inferrer) Samples() int {
+	return inf.docs
+}
+
+// kind 是值的类型，整数与其他数字分开记录
+type kind int
+
+const (
+	kindNull kind = iota
+	kindBoolean
+	kindInteger
+	kindNumber
+	kindString
+	kindObject
+	kindArray
+	numKinds
+)
+
+// kindNames 是各类型在 JSON Schema 中的名称
+var kindNames = [numKinds]string{"null", "boolean", "integer", "number", "string", "object", "array"}
+
+// node 记录一个路径上出现过的所有值
+type node struct {
+	kinds [numKinds]int // 各类型出现的次数
+
+	// 字符串
+	values   []string // 不同的取值，超过上限后为 nil
+	overflow bool
+	format   string // 所有字符串共同的格式
+
+	// 数字
+	min, max       float64
+	minRaw, maxRaw string
+
+	// 对象
+	keys    []string // 按首次出现的顺序
+	props   map[string]*node
+	present map[string]int // 成员出现在多少个对象中
+
+	// 数组
+	items *node // 没有见过元素时为 nil
+}
+
+func newNode() *node {
+	return &node{props: make(map[string]*node), present: make(map[string]int)}
+}
+
+// observe 读取以 first 开头的值的其余部分并记录到 n
+func (inf *Inferrer) observe(dec *jsontokenizer.Decoder, first json.Token, n *node) error {
+	switch v := first.(type) {
+	case nil:
+		n.kinds[kindNull]++
+	case bool:
+		n.kinds[kindBoolean]++
+	case json.Number:
+		n.number(v)
+	case string:
+		inf.str(n, v)
+	case json.Delim:
+		if v == '[' {
+			n.kinds[kindArray]++
+			for dec.More() {
+				efirst, err := dec.Token()
+				if err != nil {
+					return err
+				}
+				if n.items == nil {
+					n.items = newNode()
+				}
+				if err := inf.observe(dec, efirst, n.items); err != nil {
+					return err
+				}
+			}
+			_, err := dec.Token()
+			return err
+		}
+		n.kinds[kindObject]++
+		seen := make(map[string]bool)
+		for dec.More() {
+			key, err := dec.Token()
+			if err != nil {
+				return err
+			}
+			vfirst, err := dec.Token()
+			if err != nil {
+				return err
+			}
+			k := key.(string)
+			child, ok := n.props[k]
+			if !ok {
+				child = newNode()
+				n.props[k] = child
+				n.keys = append(n.keys, k)
+			}
+			if !seen[k] {
+				seen[k] = true
+				n.present[k]++
+			}
+			if err := inf.observe(dec, vfirst, child); err != nil {
+				return err
+			}
+		}
+		_, err := dec.Token()
+		return err
+	}
+	return nil
+}
+
+// number 记录一个数字
+func (n *node) number(v json.Number) {
+	if strings.ContainsAny(string(v), ".eE") {
+		n.kinds[kindNumber]++
+	} else {
+		n.kinds[kindInteger]++
+	}
+	f, _ := v.Float64()
+	if n.minRaw == "" || f < n.min {
+		n.min, n.minRaw = f, string(v)
+	}
+	if n.maxRaw == "" || f > n.max {
+		n.max, n.maxRaw = f, string(v)
+	}
+}
+
+// str 记录一个字符串
+func (inf *Inferrer) str(n *node, s string) {
+	f := detectFormat(s)
+	if n.kinds[kindString] == 0 {
+		n.format = f
+	} else if n.format != f {
+		n.format = ""
+	}
+	n.kinds[kindString]++
+
+	if n.overflow || slices.Contains(n.values, s) {
+		return
+	}
+	if len(n.values) >= inf.opts.MaxEnum {
+		n.values, n.overflow = nil, true
+		return
+	}
+	n.values = append(n.values, s)
+}
+
+// enum 返回作为枚举报告的字符串取值，不是枚举时返回 nil
+func (n *node) enum() []string {
+	if n.overflow || n.format != "" || len(n.values) == 0 || n.kinds[kindString] < 2*len(n.values) {
+		return nil
+	}
+	return n.values
+}
+
+// types 返回出现过的类型，同时出现整数和其他数字时只报告 number
+func (n *node) types() []kind {
+	var out []kind
+	for k := range numKinds {
+		if n.kinds[k] == 0 || k == kindInteger && n.kinds[kindNumber] > 0 {
+			continue
+		}
+		out = append(out, k)
+	}
+	return out
+}
+
+// required 判断成员是否出现在每个对象中
+func (n *node) required(key string) bool {
+	return n.present[key] == n.kinds[kindObject]
+}
+
+// integral 判断整数是否都在 int64 的范围内
+func (n *node) integral() bool {
+	return n.min >= math.MinInt64 && n.max < math.MaxInt64
+}
+
+var uuidPattern = regexp.MustCompile(`^[0-9a-fA-F]{8}-[0-9a-fA-F]{4}-[0-9a-fA-F]{4}-[0-9a-fA-F]{4}-[0-9a-fA-F]{12}$`)
+
+// detectFormat 返回字符串符合的 JSON Schema 格式，都不符合时返回 ""
+func detectFormat(s string) string {
+	if uuidPattern.MatchString(s) {
+		return "uuid"
+	}
+	if len(s) >= len(time.DateOnly) && s[4] == '-' {
+		if _, err := time.Parse(time.RFC3339Nano, s); err == nil {
+			return "date-time"
+		}
+		if _, err := time.Parse(time.DateOnly, s); err == nil {
+			return "date"
+		}
+	}
+	if strings.Contains(s, "@") {
+		if a, err := mail.ParseAddress(s); err == nil && a.Address == s && a.Name == "" {
+			return "email"
+		}
+	}
+	return ""
+}
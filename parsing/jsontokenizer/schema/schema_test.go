@@ -0,0 +1,191 @@
+package schema
+
+import (
+	"encoding/json"
+	"strings"
+	"testing"
+	"testing/iotest"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+const events = `{"id":"0b5f7e1c-3c1a-4d7b-9a3e-2f1c7a9b8d01","type":"push","at":"2024-05-01T10:00:00Z","n":12,"user":{"email":"a@b.co"},"tags":["x"],"meta":null}
+{"id":"1b5f7e1c-3c1a-4d7b-9a3e-2f1c7a9b8d01","type":"push","at":"2024-05-02T10:00:00.5+08:00","n":3,"user":{"email":"c@d.co","day":"2024-05-02"},"tags":[],"meta":{"k":1.5}}
+{"id":"2b5f7e1c-3c1a-4d7b-9a3e-2f1c7a9b8d01","type":"issue","at":"2024-05-03T10:00:00Z","n":-1,"user":{"email":"e@f.co"},"meta":null,"x":1}
+{"id":"3b5f7e1c-3c1a-4d7b-9a3e-2f1c7a9b8d01","type":"issue","at":"2024-05-03T10:00:00Z","n":5,"user":{"email":"e@f.co"},"meta":null,"x":"a"}`
+
+func infer(t *testing.T, opts Options, input string) *Inferrer {
+	t.Helper()
+	inf := NewInferrer(opts)
+	require.NoError(t, inf.Add(iotest.OneByteReader(strings.NewReader(input))))
+	return inf
+}
+
+func TestJSONSchema(t *testing.T) {
+	inf := infer(t, Options{}, events)
+	assert.Equal(t, 4, inf.Samples())
+	b, err := inf.JSONSchema()
+	require.NoError(t, err)
+	assert.JSONEq(t, `{
+		"$schema": "https://json-schema.org/draft/2020-12/schema",
+		"type": "object",
+		"properties": {
+			"id": {"type": "string", "format": "uuid"},
+			"type": {"type": "string", "enum": ["push", "issue"]},
+			"at": {"type": "string", "format": "date-time"},
+			"n": {"type": "integer", "minimum": -1, "maximum": 12},
+			"user": {
+				"type": "object",
+				"properties": {
+					"email": {"type": "string", "format": "email"},
+					"day": {"type": "string", "format": "date"}
+				},
+				"required": ["email"]
+			},
+			"tags": {"type": "array", "items": {"type": "string"}},
+			"meta": {
+				"type": ["null", "object"],
+				"properties": {"k": {"type": "number", "minimum": 1.5, "maximum": 1.5}},
+				"required": ["k"]
+			},
+			"x": {"type": ["integer", "string"], "minimum": 1, "maximum": 1}
+		},
+		"required": ["id", "type", "at", "n", "user", "meta"]
+	}`, string(b))
+}
+
+func TestJSONSchema_Values(t *testing.T) {
+	tests := []struct {
+		name   string
+		opts   Options
+		input  string
+		schema string
+	}{
+		{"empty", Options{}, ``, `{}`},
+		{"numbers", Options{}, `1 2.5 -3`, `{"type":"number","minimum":-3,"maximum":2.5}`},
+		{"nullable enum", Options{}, `"a" "a" null "b" "b"`, `{"type":["null","string"],"enum":["a","b",null]}`},
+		{"unique strings", Options{}, `"a" "b" "c"`, `{"type":"string"}`},
+		{"too many values", Options{MaxEnum: 2}, `"a" "b" "c" "a" "b" "c"`, `{"type":"string"}`},
+		{"no enum", Options{MaxEnum: -1}, `"a" "a"`, `{"type":"string"}`},
+		{"mixed enum", Options{}, `"a" "a" 1`, `{"type":["integer","string"],"minimum":1,"maximum":1}`},
+		{"mixed format", Options{}, `"2024-01-02" "2024-01-02T00:00:00Z"`, `{"type":"string"}`},
+		{"empty array", Options{}, `[] [[]]`, `{"type":"array","items":{"type":"array"}}`},
+		{"duplicate key", Options{}, `{"a":1,"a":2} {}`, `{"type":"object","properties":{"a":{"type":"integer","minimum":1,"maximum":2}}}`},
+		{"escaped key", Options{}, `{"q\"\\\u0001\t":1}`, `{"type":"object","properties":{"q\"\\\u0001\t":{"type":"integer","minimum":1,"maximum":1}},"required":["q\"\\\u0001\t"]}`},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			b, err := infer(t, tt.opts, tt.input).JSONSchema()
+			require.NoError(t, err)
+			var expected, actual map[string]any
+			require.NoError(t, json.Unmarshal([]byte(tt.schema), &expected))
+			require.NoError(t, json.Unmarshal(b, &actual))
+			assert.Equal(t, Dialect, actual["$schema"])
+			delete(actual, "$schema")
+			assert.Equal(t, expected, actual)
+		})
+	}
+}
+
+func TestGoSource(t *testing.T) {
+	src, err := infer(t, Options{}, events).GoSource("api", "Event")
+	require.NoError(t, err)
+	assert.Equal(t, "package api\n\n"+
+		"import \"time\"\n\n"+
+		"type Event struct {\n"+
+		"\tID   string    `json:\"id\"`\n"+
+		"\tType string    `json:\"type\"`\n"+
+		"\tAt   time.Time `json:\"at\"`\n"+
+		"\tN    int64     `json:\"n\"`\n"+
+		"\tUser User      `json:\"user\"`\n"+
+		"\tTags []string  `json:\"tags,omitempty\"`\n"+
+		"\tMeta *Meta     `json:\"meta\"`\n"+
+		"\tX    any       `json:\"x,omitempty\"`\n"+
+		"}\n\n"+
+		"type User struct {\n"+
+		"\tEmail string `json:\"email\"`\n"+
+		"\tDay   string `json:\"day,omitempty\"`\n"+
+		"}\n\n"+
+		"type Meta struct {\n"+
+		"\tK float64 `json:\"k\"`\n"+
+		"}\n", string(src))
+}
+
+func TestGoSource_Names(t *testing.T) {
+	input := `{"items":[{"a":{"v":1}}],"a":{"w":true},"entries":[{"-":1,"":2,"a,b":3,"2fa":4,"名字":5,"user_id":6,"userId":7}],"any":[]}`
+	src, err := infer(t, Options{}, input).GoSource("p", "Doc")
+	require.NoError(t, err)
+	assert.Equal(t, "package p\n\n"+
+		"type Doc struct {\n"+
+		"\tItems   []Item  `json:\"items\"`\n"+
+		"\tA       A       `json:\"a\"`\n"+
+		"\tEntries []Entry `json:\"entries\"`\n"+
+		"\tAny     []any   `json:\"any\"`\n"+
+		"}\n\n"+
+		"type Item struct {\n"+
+		"\tA ItemA `json:\"a\"`\n"+
+		"}\n\n"+
+		"type A struct {\n"+
+		"\tW bool `json:\"w\"`\n"+
+		"}\n\n"+
+		"type Entry struct {\n"+
+		"\tField int64 `json:\"-,\"`\n"+
+		"\t// member \"\" cannot be named in a json tag\n"+
+		"\t// member \"a,b\" cannot be named in a json tag\n"+
+		"\tX2fa    int64 `json:\"2fa\"`\n"+
+		"\tX名字     int64 `json:\"名字\"`\n"+
+		"\tUserID  int64 `json:\"user_id\"`\n"+
+		"\tUserID2 int64 `json:\"userId\"`\n"+
+		"}\n\n"+
+		"type ItemA struct {\n"+
+		"\tV int64 `json:\"v\"`\n"+
+		"}\n", string(src))
+
+	src, err = infer(t, Options{}, `[{"a":1}] [{"b":null}]`).GoSource("p", "Events")
+	require.NoError(t, err)
+	assert.Contains(t, string(src), "type Events []Event\n")
+	assert.Contains(t, string(src), "\tB any   `json:\"b,omitempty\"`\n")
+
+	src, err = infer(t, Options{}, `{"a":{"v":1},"t":"2024-05-01T10:00:00Z","n":null} {"n":{"v":2}}`).GoSource("p", "Doc")
+	require.NoError(t, err)
+	assert.Contains(t, string(src), "\tA *A         `json:\"a,omitempty\"`\n")
+	assert.Contains(t, string(src), "\tT *time.Time `json:\"t,omitempty\"`\n")
+	assert.Contains(t, string(src), "\tN *N         `json:\"n\"`\n")
+}
+
+func TestFieldName(t *testing.T) {
+	for key, name := range map[string]string{
+		"id": "ID", "user_id": "UserID", "createdAt": "CreatedAt", "content-type": "ContentType",
+		"HTTPStatus": "HTTPStatus", "api2Key": "Api2Key", "": "Field", "__": "Field",
+	} {
+		assert.Equal(t, name, fieldName(key), key)
+	}
+	for plural, one := range map[string]string{
+		"Users": "User", "Entries": "Entry", "Addresses": "Address", "Boxes": "Box", "Class": "ClassItem", "Data": "DataItem",
+	} {
+		assert.Equal(t, one, singular(plural), plural)
+	}
+}
+
+func TestDetectFormat(t *testing.T) {
+	for s, f := range map[string]string{
+		"2024-05-01T10:00:00Z":                 "date-time",
+		"2024-05-01T10:00:00.123+08:00":        "date-time",
+		"2024-05-01":                           "date",
+		"2024-13-01":                           "",
+		"0B5F7E1C-3C1A-4D7B-9A3E-2F1C7A9B8D01": "uuid",
+		"a-b.c@example.com":                    "email",
+		"Bob <bob@example.com>":                "",
+		"@":                                    "",
+		"hello":                                "",
+	} {
+		assert.Equal(t, f, detectFormat(s), s)
+	}
+}
+
+func TestAddError(t *testing.T) {
+	inf := NewInferrer(Options{})
+	assert.Error(t, inf.Add(strings.NewReader(`{"a":1} {"a":`)))
+	assert.Equal(t, 1, inf.Samples())
+}
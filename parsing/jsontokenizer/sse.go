@@ -0,0 +1,248 @@
+package jsontokenizer
+
+import (
+	"bufio"
+	"bytes"
+	"cmp"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"maps"
+	"slices"
+	"strings"
+)
+
+// DefaultFragmentPath is where OpenAI-style chat completion streams carry
+// tool-call argument fragments.
+const DefaultFragmentPath = "$.choices[0].delta.tool_calls[*].function.arguments"
+
+// SSEOptions configures ReadSSE.
+type SSEOptions struct {
+	// FragmentPath locates the argument fragment in the JSON data of each
+	// event. One [*] segment may select every element of an array, such as
+	// the tool calls of a delta. It defaults to DefaultFragmentPath.
+	FragmentPath string
+	// IndexKey names the member of the elements selected by [*] that holds
+	// the tool-call index; elements without it use their array position. It
+	// defaults to "index".
+	IndexKey string
+}
+
+// ToolCallEvent is a value-level token of the arguments of one tool call.
+type ToolCallEvent struct {
+	Index int
+	// Token is a TokenString, TokenNumber, TokenBoolean or TokenNull, or a
+	// TokenStringChunk carrying the part of a string received so far. Its
+	// path is relative to the arguments of the call.
+	Token Token
+}
+
+// ReadSSE reads a Server-Sent Events stream from r, such as the body of a
+// streaming chat completion, and tokenizes the JSON arguments of every tool
+// call as their fragments arrive. fn is called with the value-level tokens of
+// each call, in stream order; strings are reported in chunks as in
+// Tokenizer.StringChunks.
+//
+// Events whose data is "[DONE]" end the stream. Events that are not JSON, or
+// that have no fragment at FragmentPath, are skipped. ReadSSE returns the
+// first error of r or fn.
+func ReadSSE(r io.Reader, opts SSEOptions, fn func(ToolCallEvent) error) error {
+	ex, err := newFragmentExtractor(opts)
+	if err != nil {
+		return err
+	}
+	calls := make(map[int]*Tokenizer)
+	emit := func(index int, tokens []Token) error {
+		for _, tk := range tokens {
+			if !isValueToken(tk.Type) {
+				continue
+			}
+			if err := fn(ToolCallEvent{Index: index, Token: tk}); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+
+	err = readSSE(r, func(data []byte) error {
+		for _, f := range ex.extract(data) {
+			t, ok := calls[f.index]
+			if !ok {
+				t = NewTokenizer()
+				t.StringChunks()
+				calls[f.index] = t
+			}
+			if err := emit(f.index, t.Feed([]byte(f.text))); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+	// 参数末尾的数字等只有在输入结束时才能确定已完整
+	for _, index := range slices.Sorted(maps.Keys(calls)) {
+		if err := emit(index, calls[index].Flush()); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// errSSEDone 表示收到了 [DONE] 事件
+var errSSEDone = errors.New("sse done")
+
+// readSSE 解析事件流，对每个事件的数据调用 fn，直到输入结束或收到 [DONE]
+func readSSE(r io.Reader, fn func(data []byte) error) error {
+	br := bufio.NewReader(r)
+	var data []byte
+	hasData := false
+	dispatch := func() error {
+		if !hasData {
+			return nil
+		}
+		d := data
+		data, hasData = data[:0], false
+		if string(d) == "[DONE]" {
+			return errSSEDone
+		}
+		return fn(d)
+	}
+
+	for {
+		line, rerr := br.ReadBytes('\n')
+		if rerr != nil && !errors.Is(rerr, io.EOF) {
+			return fmt.Errorf("jsontokenizer: read: %w", rerr)
+		}
+		line = bytes.TrimRight(line, "\r\n")
+		var err error
+		switch {
+		case len(line) == 0:
+			err = dispatch()
+		case line[0] == ':':
+			// 注释
+		default:
+			field, value, _ := bytes.Cut(line, []byte{':'})
+			value = bytes.TrimPrefix(value, []byte{' '})
+			if string(field) == "data" {
+				if hasData {
+					data = append(data, '\n')
+				}
+				data = append(data, value...)
+				hasData = true
+			}
+		}
+		if err == nil && rerr != nil {
+			// 输入结束时分派最后一个没有以空行结尾的事件
+			err = dispatch()
+		}
+		if errors.Is(err, errSSEDone) {
+			return nil
+		}
+		if err != nil || rerr != nil {
+			return err
+		}
+	}
+}
+
+// fragment 是一个事件中属于某个工具调用的参数片段
+type fragment struct {
+	index int
+	text  string
+}
+
+// fragmentExtractor 按配置的路径从事件数据中提取参数片段
+type fragmentExtractor struct {
+	prefix, suffix []pathSegment // [*] 之前和之后的路径
+	wildcard       bool
+	indexKey       string
+}
+
+func newFragmentExtractor(opts SSEOptions) (*fragmentExtractor, error) {
+	path := cmp.Or(opts.FragmentPath, DefaultFragmentPath)
+	before, after, wildcard := strings.Cut(path, "[*]")
+	prefix, rest, err := parsePath(before)
+	if err == nil && rest != "" {
+		err = fmt.Errorf("invalid segment %q", rest)
+	}
+	var suffix []pathSegment
+	if err == nil {
+		suffix, rest, err = parsePath("$" + after)
+	}
+	if err == nil && rest != "" {
+		err = fmt.Errorf("invalid segment %q", rest)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("jsontokenizer: fragment path %q: %w", path, err)
+	}
+	return &fragmentExtractor{prefix: prefix, suffix: suffix, wildcard: wildcard, indexKey: cmp.Or(opts.IndexKey, "index")}, nil
+}
+
+// extract 返回事件数据中的全部参数片段
+func (e *fragmentExtractor) extract(data []byte) []fragment {
+	var v any
+	if json.Unmarshal(data, &v) != nil {
+		return nil
+	}
+	if !e.wildcard {
+		if s, ok := lookupString(v, e.prefix); ok && s != "" {
+			return []fragment{{text: s}}
+		}
+		return nil
+	}
+
+	v, _ = lookup(v, e.prefix)
+	elems, _ := v.([]any)
+	var out []fragment
+	for i, elem := range elems {
+		index := i
+		if obj, ok := elem.(map[string]any); ok {
+			if n, ok := obj[e.indexKey].(float64); ok {
+				index = int(n)
+			}
+		}
+		if s, ok := lookupString(elem, e.suffix); ok && s != "" {
+			out = append(out, fragment{index: index, text: s})
+		}
+	}
+	return out
+}
+
+// lookup 沿路径查找 encoding/json 解码得到的值
+func lookup(v any, segs []pathSegment) (any, bool) {
+	for _, seg := range segs {
+		if seg.isIndex {
+			arr, ok := v.([]any)
+			if !ok || seg.index >= len(arr) {
+				return nil, false
+			}
+			v = arr[seg.index]
+			continue
+		}
+		obj, ok := v.(map[string]any)
+		if !ok {
+			return nil, false
+		}
+		if v, ok = obj[seg.key]; !ok {
+			return nil, false
+		}
+	}
+	return v, true
+}
+
+// lookupString 沿路径查找字符串值
+func lookupString(v any, segs []pathSegment) (string, bool) {
+	v, ok := lookup(v, segs)
+	if !ok {
+		return "", false
+	}
+	s, ok := v.(string)
+	return s, ok
+}
+
+// isValueToken 判断Token是否为标量值或字符串分片
+func isValueToken(t TokenType) bool {
+	return t == TokenString || t == TokenStringChunk || t == TokenNumber || t == TokenBoolean || t == TokenNull
+}
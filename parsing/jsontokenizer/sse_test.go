@@ -0,0 +1,104 @@
+package jsontokenizer
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// toolCallChunk 构造一个 OpenAI 风格的流式响应事件
+func toolCallChunk(index int, args string) string {
+	b, _ := json.Marshal(map[string]any{
+		"choices": []any{map[string]any{
+			"delta": map[string]any{
+				"tool_calls": []any{map[string]any{
+					"index":    index,
+					"function": map[string]any{"arguments": args},
+				}},
+			},
+		}},
+	})
+	return "data: " + string(b) + "\n\n"
+}
+
+func sseServer(t *testing.T, events []string) *httptest.Server {
+	t.Helper()
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+		w.Header().Set("Content-Type", "text/event-stream")
+		for _, e := range events {
+			_, _ = fmt.Fprint(w, e)
+			w.(http.Flusher).Flush()
+		}
+	}))
+	t.Cleanup(srv.Close)
+	return srv
+}
+
+func TestReadSSE(t *testing.T) {
+	srv := sseServer(t, []string{
+		": keep-alive\n\n",
+		"event: message\ndata: {\"choices\":[{\"delta\":{\"role\":\"assistant\"}}]}\n\n",
+		toolCallChunk(0, `{"city": "Par`),
+		toolCallChunk(1, `{"q": [1, tr`),
+		toolCallChunk(0, `is", "days": 3`),
+		toolCallChunk(1, `ue]}`),
+		toolCallChunk(0, `}`),
+		"data: [DONE]\n\n",
+		toolCallChunk(0, `ignored`),
+	})
+	resp, err := http.Get(srv.URL)
+	require.NoError(t, err)
+	defer resp.Body.Close()
+
+	var got []string
+	err = ReadSSE(resp.Body, SSEOptions{}, func(e ToolCallEvent) error {
+		got = append(got, fmt.Sprintf("%d %s %s %s", e.Index, e.Token.Type, e.Token.Path, e.Token.Val))
+		return nil
+	})
+	require.NoError(t, err)
+	assert.Equal(t, []string{
+		"0 StringChunk $.city Par",
+		"1 Number $.q[0] 1",
+		"0 String $.city is",
+		"1 Boolean $.q[1] true",
+		"0 Number $.days 3", // 数字在后续片段的 } 到达后才完整
+	}, got)
+}
+
+func TestReadSSE_CustomPath(t *testing.T) {
+	stream := "data: {\"delta\":{\"partial_json\":\"[\\\"a\"}}\r\n\r\n" +
+		"data: {\"delta\":\n" + // 多行数据以换行连接
+		"data: {\"partial_json\":\"b\\\", 2.5\"}}\n\n" +
+		"data: {\"delta\":{\"partial_json\":\"]\"}}"
+	var got []string
+	err := ReadSSE(strings.NewReader(stream), SSEOptions{FragmentPath: "$.delta.partial_json"}, func(e ToolCallEvent) error {
+		got = append(got, fmt.Sprintf("%d %s %s", e.Index, e.Token.Path, e.Token.Val))
+		return nil
+	})
+	require.NoError(t, err)
+	assert.Equal(t, []string{"0 $[0] a", "0 $[0] b", "0 $[1] 2.5"}, got)
+}
+
+func TestReadSSE_TrailingNumberAndErrors(t *testing.T) {
+	var got []string
+	err := ReadSSE(strings.NewReader(toolCallChunk(2, `42`)), SSEOptions{}, func(e ToolCallEvent) error {
+		got = append(got, fmt.Sprintf("%d %s", e.Index, e.Token.Val))
+		return nil
+	})
+	require.NoError(t, err)
+	assert.Equal(t, []string{"2 42"}, got)
+
+	stop := errors.New("stop")
+	err = ReadSSE(strings.NewReader(toolCallChunk(0, `[1,2]`)), SSEOptions{}, func(ToolCallEvent) error { return stop })
+	assert.ErrorIs(t, err, stop)
+
+	err = ReadSSE(strings.NewReader(""), SSEOptions{FragmentPath: "a.b"}, func(ToolCallEvent) error { return nil })
+	assert.Error(t, err)
+}
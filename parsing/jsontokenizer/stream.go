@@ -0,0 +1,183 @@
+package jsontokenizer
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+	"sync"
+)
+
+// streamConfig 是 Stream 的配置
+type streamConfig struct {
+	bufferSize int
+	readSize   int
+	tokenizer  *Tokenizer
+}
+
+// StreamOption configures Stream.
+type StreamOption func(*streamConfig)
+
+// WithBufferSize sets the capacity of the token channel. Once it is full,
+// Stream stops reading until the consumer catches up. The default is 64.
+func WithBufferSize(n int) StreamOption {
+	return func(c *streamConfig) {
+		c.bufferSize = max(n, 0)
+	}
+}
+
+// WithReadSize sets the size of the buffer passed to the reader. The default
+// is 4096 bytes.
+func WithReadSize(n int) StreamOption {
+	return func(c *streamConfig) {
+		c.readSize = max(n, 1)
+	}
+}
+
+// WithTokenizer makes Stream use t, so that modes such as Coalesce or
+// AutoEscape can be enabled on it beforehand. t must not be used elsewhere
+// while the stream runs.
+func WithTokenizer(t *Tokenizer) StreamOption {
+	return func(c *streamConfig) {
+		c.tokenizer = t
+	}
+}
+
+// Stream tokenizes r on a separate goroutine and sends the tokens, as
+// produced by Feed, to the returned channel.
+//
+// The token channel is closed when r is exhausted, reading fails or ctx is
+// done. The error channel then receives the read error or ctx.Err(), if any,
+// and is closed. Cancellation is observed between reads and while waiting for
+// the consumer, so a Read that blocks forever still blocks the goroutine.
+func Stream(ctx context.Context, r io.Reader, opts ...StreamOption) (<-chan Token, <-chan error) {
+	cfg := streamConfig{bufferSize: 64, readSize: 4096}
+	for _, opt := range opts {
+		opt(&cfg)
+	}
+	if cfg.tokenizer == nil {
+		cfg.tokenizer = NewTokenizer()
+	}
+
+	tokens := make(chan Token, cfg.bufferSize)
+	errc := make(chan error, 1)
+	go func() {
+		defer close(errc)
+		defer close(tokens)
+		if err := stream(ctx, r, cfg, tokens); err != nil {
+			errc <- err
+		}
+	}()
+	return tokens, errc
+}
+
+// stream 循环读取输入并发送Token，直到输入结束、读取失败或 ctx 结束
+func stream(ctx context.Context, r io.Reader, cfg streamConfig, tokens chan<- Token) error {
+	buf := make([]byte, cfg.readSize)
+	for {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+		n, rerr := r.Read(buf)
+		if err := send(ctx, tokens, cfg.tokenizer.Feed(buf[:n])); err != nil {
+			return err
+		}
+		if errors.Is(rerr, io.EOF) {
+			return send(ctx, tokens, cfg.tokenizer.Flush())
+		}
+		if rerr != nil {
+			return fmt.Errorf("jsontokenizer: read: %w", rerr)
+		}
+	}
+}
+
+// send 依次发送Token，消费者跟不上时阻塞
+func send(ctx context.Context, tokens chan<- Token, batch []Token) error {
+	for _, tk := range batch {
+		select {
+		case tokens <- tk:
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+	}
+	return nil
+}
+
+// Router fans a token stream out to subscribers by path prefix.
+type Router struct {
+	mu   sync.Mutex
+	subs []subscription
+}
+
+// subscription 是一个订阅者及其路径前缀
+type subscription struct {
+	prefix string
+	ch     chan Token
+}
+
+// NewRouter creates an empty Router.
+func NewRouter() *Router {
+	return &Router{}
+}
+
+// Subscribe returns a channel receiving every token whose path is prefix or
+// lies below it, so "$.users" matches "$.users[0].name" but not "$.usersCount".
+// "$" matches every token. Subscribe must be called before Run.
+func (r *Router) Subscribe(prefix string, buffer int) <-chan Token {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	ch := make(chan Token, max(buffer, 0))
+	r.subs = append(r.subs, subscription{prefix: prefix, ch: ch})
+	return ch
+}
+
+// Run delivers tokens from in to the matching subscribers until in is closed
+// or ctx is done, then closes all subscriber channels. A slow subscriber
+// holds back delivery to the others. Run returns ctx.Err() if it was
+// cancelled.
+func (r *Router) Run(ctx context.Context, in <-chan Token) error {
+	r.mu.Lock()
+	subs := r.subs
+	r.mu.Unlock()
+	defer func() {
+		for _, s := range subs {
+			close(s.ch)
+		}
+	}()
+
+	for {
+		var tk Token
+		select {
+		case t, ok := <-in:
+			if !ok {
+				return nil
+			}
+			tk = t
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+		for _, s := range subs {
+			if !hasPathPrefix(tk.Path, s.prefix) {
+				continue
+			}
+			select {
+			case s.ch <- tk:
+			case <-ctx.Done():
+				return ctx.Err()
+			}
+		}
+	}
+}
+
+// hasPathPrefix 判断 path 是否等于 prefix 或位于其之下
+func hasPathPrefix(path, prefix string) bool {
+	if !strings.HasPrefix(path, prefix) {
+		return false
+	}
+	if len(path) == len(prefix) || prefix == "$" {
+		return true
+	}
+	c := path[len(prefix)]
+	return c == '.' || c == '['
+}
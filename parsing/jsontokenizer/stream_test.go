@@ -0,0 +1,94 @@
+package jsontokenizer
+
+import (
+	"context"
+	"errors"
+	"io"
+	"strings"
+	"testing"
+	"testing/iotest"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func collect(tokens <-chan Token) []Token {
+	var out []Token
+	for tk := range tokens {
+		out = append(out, tk)
+	}
+	return out
+}
+
+func TestStream(t *testing.T) {
+	json := `{"users":[{"id":1,"name":"张三"},{"id":2,"name":"李四"}],"total":2}`
+	z := NewTokenizer()
+	z.Coalesce()
+	want := append(z.Feed([]byte(json)), z.Flush()...)
+
+	coalesced := NewTokenizer()
+	coalesced.Coalesce()
+	tokens, errc := Stream(context.Background(), iotest.OneByteReader(strings.NewReader(json)),
+		WithTokenizer(coalesced), WithBufferSize(0), WithReadSize(3))
+
+	assert.Equal(t, want, collect(tokens))
+	assert.NoError(t, <-errc)
+}
+
+func TestStream_ReadError(t *testing.T) {
+	boom := errors.New("boom")
+	r := io.MultiReader(strings.NewReader(`[1,`), iotest.ErrReader(boom))
+	tokens, errc := Stream(context.Background(), r)
+
+	got := collect(tokens)
+	require.NotEmpty(t, got)
+	assert.Equal(t, "[", got[0].Val)
+	assert.ErrorIs(t, <-errc, boom)
+}
+
+func TestStream_Cancel(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	tokens, errc := Stream(ctx, strings.NewReader(`[1,2,3,4,5,6,7,8,9]`), WithBufferSize(0))
+
+	<-tokens
+	cancel()
+	// 取消后生产者不再阻塞，通道最终会被关闭
+	for range tokens {
+	}
+	assert.ErrorIs(t, <-errc, context.Canceled)
+}
+
+func TestRouter(t *testing.T) {
+	json := `{"users":[{"name":"a"},{"name":"b"}],"usersCount":2}`
+	z := NewTokenizer()
+	z.Coalesce()
+	tokens, errc := Stream(context.Background(), strings.NewReader(json), WithTokenizer(z))
+
+	r := NewRouter()
+	names := r.Subscribe("$.users", 16)
+	all := r.Subscribe("$", 64)
+
+	done := make(chan error, 1)
+	go func() { done <- r.Run(context.Background(), tokens) }()
+
+	var got []string
+	for tk := range names {
+		if tk.Type == TokenString {
+			got = append(got, tk.Path+"="+tk.Val)
+		}
+	}
+	assert.Equal(t, []string{"$.users[0].name=a", "$.users[1].name=b"}, got)
+	assert.NotEmpty(t, collect(all))
+	assert.NoError(t, <-done)
+	assert.NoError(t, <-errc)
+}
+
+func TestHasPathPrefix(t *testing.T) {
+	assert.True(t, hasPathPrefix("$.a", "$"))
+	assert.True(t, hasPathPrefix("$[0]", "$"))
+	assert.True(t, hasPathPrefix("$.a", "$.a"))
+	assert.True(t, hasPathPrefix("$.a.b", "$.a"))
+	assert.True(t, hasPathPrefix("$.a[1]", "$.a"))
+	assert.False(t, hasPathPrefix("$.ab", "$.a"))
+	assert.False(t, hasPathPrefix("$", "$.a"))
+}
@@ -0,0 +1,507 @@
+package jsontokenizer
+
+import (
+	"bytes"
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"strconv"
+
+	"github.com/libxyz/gizmo/parsing/jsontokenizer/internal/jsonstr"
+)
+
+// 磁带上每个条目占一个 uint64：高 8 位是种类，低 56 位是数据。
+// 容器开头的数据低 32 位是对应结尾条目的位置，高 24 位是子值的个数（饱和）；
+// 容器结尾的数据是对应开头条目的位置；键名、字符串和数字的数据是其内容在
+// 旁路缓冲区中的偏移量，内容以 4 字节小端长度开头
+const (
+	tapeObjectStart = '{'
+	tapeObjectEnd   = '}'
+	tapeArrayStart  = '['
+	tapeArrayEnd    = ']'
+	tapeKey         = 'k' // 键名原文，不含引号，保留转义
+	tapeString      = '"' // 解码后的字符串
+	tapeNumber      = 'd' // 数字原文
+	tapeTrue        = 't'
+	tapeFalse       = 'f'
+	tapeNull        = 'n'
+
+	tapeKindShift   = 56
+	tapePayloadMask = 1<<tapeKindShift - 1
+	tapeCountShift  = 32
+	tapeCountMax    = 1<<(tapeKindShift-tapeCountShift) - 1
+
+	// tapeVersion 是 MarshalBinary 格式的版本
+	tapeVersion = 1
+)
+
+// tapeMagic 是 MarshalBinary 输出的开头
+var tapeMagic = []byte("JTTP")
+
+// ErrBadTape is returned by Tape.UnmarshalBinary for data that is not a tape
+// or is corrupt.
+var ErrBadTape = errors.New("jsontokenizer: bad tape")
+
+// Tape is a compiled form of a JSON document for fast repeated reading, in
+// the spirit of simdjson's tape: a flat array of one 64-bit entry per value,
+// key and container end, where every container start points to its end, plus
+// a side buffer holding the text of keys, strings and numbers.
+//
+// A Tape is built once by tokenizing the document and can then be navigated
+// with TapeValue, skipping any subtree in constant time, or replayed to a
+// Visitor, any number of times and concurrently, without parsing again.
+type Tape struct {
+	entries []uint64
+	side    []byte
+}
+
+// BuildTape reads the JSON document in r, which must hold a single valid
+// value, and returns its tape.
+func BuildTape(r io.Reader) (*Tape, error) {
+	in := newValueReader(r)
+	t := &Tape{}
+	var open []int // 未结束的容器的开头条目
+	for {
+		tk, err := in.next()
+		if err != nil {
+			return nil, err
+		}
+		if n := len(open); n > 0 && tk.Type != TokenKey && tk.Type != TokenObjectEnd && tk.Type != TokenArrayEnd {
+			// 开头条目在容器结束前用于计数
+			if e := &t.entries[open[n-1]]; *e>>tapeCountShift&tapeCountMax < tapeCountMax {
+				*e += 1 << tapeCountShift
+			}
+		}
+		switch tk.Type {
+		case TokenObjectStart, TokenArrayStart:
+			open = append(open, len(t.entries))
+			t.entries = append(t.entries, uint64(tk.Raw[0])<<tapeKindShift)
+			continue
+		case TokenObjectEnd, TokenArrayEnd:
+			start := open[len(open)-1]
+			open = open[:len(open)-1]
+			t.entries[start] |= uint64(len(t.entries))
+			t.entries = append(t.entries, uint64(tk.Raw[0])<<tapeKindShift|uint64(start))
+		case TokenKey:
+			t.appendText(tapeKey, tk.Raw[1:len(tk.Raw)-1])
+			continue
+		case TokenString:
+			t.appendText(tapeString, tk.Val)
+		case TokenNumber:
+			t.appendText(tapeNumber, tk.Raw)
+		case TokenBoolean:
+			t.entries = append(t.entries, uint64(tk.Raw[0])<<tapeKindShift)
+		case TokenNull:
+			t.entries = append(t.entries, tapeNull<<tapeKindShift)
+		case TokenUnknown, TokenStringEscape, TokenKeyEscape, TokenComma, TokenColon, TokenQuote, TokenWhitespace,
+			TokenStringChunk:
+		}
+		if len(open) == 0 {
+			break
+		}
+	}
+	if tk, err := in.d.step(); !errors.Is(err, io.EOF) {
+		if err != nil {
+			return nil, err
+		}
+		return nil, in.d.syntaxError(tk, "after top-level value")
+	}
+	return t, nil
+}
+
+// appendText 添加内容保存在旁路缓冲区中的条目
+func (t *Tape) appendText(kind byte, s string) {
+	t.entries = append(t.entries, uint64(kind)<<tapeKindShift|uint64(len(t.side)))
+	t.side = binary.LittleEndian.AppendUint32(t.side, uint32(len(s)))
+	t.side = append(t.side, s...)
+}
+
+// kind 返回条目的种类
+func (t *Tape) kind(i int) byte {
+	return byte(t.entries[i] >> tapeKindShift)
+}
+
+// payload 返回条目的数据
+func (t *Tape) payload(i int) uint64 {
+	return t.entries[i] & tapePayloadMask
+}
+
+// text 返回条目在旁路缓冲区中的内容
+func (t *Tape) text(i int) []byte {
+	off := t.payload(i)
+	n := uint64(binary.LittleEndian.Uint32(t.side[off:]))
+	return t.side[off+4 : off+4+n : off+4+n]
+}
+
+// next 返回第 i 个条目开始的值之后的位置
+func (t *Tape) next(i int) int {
+	switch t.kind(i) {
+	case tapeObjectStart, tapeArrayStart:
+		return int(t.payload(i)&(1<<tapeCountShift-1)) + 1
+	}
+	return i + 1
+}
+
+// Root returns the document's top-level value.
+func (t *Tape) Root() TapeValue {
+	return TapeValue{t: t}
+}
+
+// MarshalBinary encodes the tape in a versioned binary form, to cache it
+// outside the process.
+func (t *Tape) MarshalBinary() ([]byte, error) {
+	b := append([]byte(nil), tapeMagic...)
+	b = binary.AppendUvarint(b, tapeVersion)
+	b = binary.AppendUvarint(b, uint64(len(t.entries)))
+	for _, e := range t.entries {
+		b = binary.LittleEndian.AppendUint64(b, e)
+	}
+	return append(b, t.side...), nil
+}
+
+// UnmarshalBinary decodes a tape encoded by MarshalBinary. The tape keeps
+// referring to data. It returns an error wrapping ErrBadTape, and leaves the
+// tape unchanged, if data is not a well-formed tape.
+func (t *Tape) UnmarshalBinary(data []byte) error {
+	if !bytes.HasPrefix(data, tapeMagic) {
+		return ErrBadTape
+	}
+	data = data[len(tapeMagic):]
+	v, n := binary.Uvarint(data)
+	if n <= 0 || v != tapeVersion {
+		return fmt.Errorf("%w: version %d, want %d", ErrBadTape, v, tapeVersion)
+	}
+	data = data[n:]
+	count, n := binary.Uvarint(data)
+	if n <= 0 || count == 0 || count > uint64(len(data)-n)/8 {
+		return fmt.Errorf("%w: truncated", ErrBadTape)
+	}
+	data = data[n:]
+	entries := make([]uint64, count)
+	for i := range entries {
+		entries[i] = binary.LittleEndian.Uint64(data[8*i:])
+	}
+	tape := Tape{entries: entries, side: data[8*count:]}
+	if err := tape.validate(); err != nil {
+		return err
+	}
+	*t = tape
+	return nil
+}
+
+// validate 检查磁带构成一个完整的值，容器的跳转位置和子值个数正确，
+// 旁路缓冲区中的内容不越界，保证导航时不会越界访问
+func (t *Tape) validate() error {
+	type frame struct {
+		start     int
+		count     uint64
+		expectKey bool // 对象中下一个条目应为键名或结尾
+	}
+	var open []frame
+	for i := range t.entries {
+		if len(open) == 0 && i > 0 {
+			return fmt.Errorf("%w: entry %d after the top-level value", ErrBadTape, i)
+		}
+		var top *frame
+		if len(open) > 0 {
+			top = &open[len(open)-1]
+		}
+		object := top != nil && t.kind(top.start) == tapeObjectStart
+		switch k := t.kind(i); k {
+		case tapeObjectEnd, tapeArrayEnd:
+			if top == nil || (k == tapeObjectEnd) != object || object && !top.expectKey {
+				return fmt.Errorf("%w: unexpected end at entry %d", ErrBadTape, i)
+			}
+			p := t.payload(top.start)
+			if t.payload(i) != uint64(top.start) || p&(1<<tapeCountShift-1) != uint64(i) ||
+				p>>tapeCountShift != min(top.count, tapeCountMax) {
+				return fmt.Errorf("%w: container at entry %d", ErrBadTape, top.start)
+			}
+			open = open[:len(open)-1]
+			continue
+		case tapeKey:
+			if !object || !top.expectKey {
+				return fmt.Errorf("%w: unexpected key at entry %d", ErrBadTape, i)
+			}
+			top.expectKey = false
+			if !t.validText(i) {
+				return fmt.Errorf("%w: text of entry %d out of range", ErrBadTape, i)
+			}
+			continue
+		case tapeObjectStart, tapeArrayStart, tapeString, tapeNumber, tapeTrue, tapeFalse, tapeNull:
+		default:
+			return fmt.Errorf("%w: entry %d has kind %d", ErrBadTape, i, k)
+		}
+		if object && top.expectKey {
+			return fmt.Errorf("%w: value without key at entry %d", ErrBadTape, i)
+		}
+		if top != nil {
+			top.count++
+			top.expectKey = object
+		}
+		switch t.kind(i) {
+		case tapeObjectStart, tapeArrayStart:
+			open = append(open, frame{start: i, expectKey: t.kind(i) == tapeObjectStart})
+		case tapeString, tapeNumber:
+			if !t.validText(i) {
+				return fmt.Errorf("%w: text of entry %d out of range", ErrBadTape, i)
+			}
+		}
+	}
+	if len(open) > 0 {
+		return fmt.Errorf("%w: container at entry %d not closed", ErrBadTape, open[len(open)-1].start)
+	}
+	return nil
+}
+
+// validText 判断条目在旁路缓冲区中的内容是否完整
+func (t *Tape) validText(i int) bool {
+	off := t.payload(i)
+	if off > uint64(len(t.side)) || uint64(len(t.side))-off < 4 {
+		return false
+	}
+	n := uint64(binary.LittleEndian.Uint32(t.side[off:]))
+	return n <= uint64(len(t.side))-off-4
+}
+
+// Walk replays the document to v, reporting the same events with the same
+// paths as a Walker fed the original text. A string is reported with a
+// single OnStringChunk call, or none if it is empty.
+func (t *Tape) Walk(v Visitor) {
+	var stack []container
+	var key []byte
+	for i := 0; i < len(t.entries); i++ {
+		n := len(stack)
+		if n > 0 && stack[n-1].IsArray() && i > 0 {
+			if k := t.kind(i - 1); k != tapeArrayStart {
+				// 上一个元素已经结束
+				stack[n-1].ArrayIndex++
+			}
+		}
+		path := PathView{stack: stack}
+		switch t.kind(i) {
+		case tapeObjectStart:
+			v.OnObjectStart(path)
+			stack = append(stack, container{Type: containerTypeObject, ExpectKey: true})
+		case tapeArrayStart:
+			v.OnArrayStart(path)
+			stack = append(stack, container{Type: containerTypeArray})
+		case tapeObjectEnd:
+			stack = stack[:n-1]
+			v.OnObjectEnd(PathView{stack: stack})
+		case tapeArrayEnd:
+			stack = stack[:n-1]
+			v.OnArrayEnd(PathView{stack: stack})
+		case tapeKey:
+			raw := t.text(i)
+			stack[n-1].Key, stack[n-1].ExpectKey = raw, false
+			var u unescaper
+			key = u.finish(u.append(key[:0], raw))
+			v.OnKey(PathView{stack: stack[:n-1]}, key)
+		case tapeString:
+			v.OnStringStart(path)
+			if s := t.text(i); len(s) > 0 {
+				v.OnStringChunk(path, s)
+			}
+			v.OnStringEnd(path)
+		case tapeNumber:
+			v.OnNumber(path, t.text(i))
+		case tapeTrue, tapeFalse:
+			v.OnBool(path, t.kind(i) == tapeTrue)
+		case tapeNull:
+			v.OnNull(path)
+		}
+	}
+}
+
+// TapeValue is a value in a Tape. The zero TapeValue represents a missing
+// value.
+type TapeValue struct {
+	t *Tape
+	i int
+}
+
+// Exists reports whether v is a value of the tape.
+func (v TapeValue) Exists() bool {
+	return v.t != nil
+}
+
+// Type returns TokenObjectStart for objects, TokenArrayStart for arrays,
+// TokenString, TokenNumber, TokenBoolean or TokenNull, and TokenUnknown for a
+// missing value.
+func (v TapeValue) Type() TokenType {
+	if v.t == nil {
+		return TokenUnknown
+	}
+	switch v.t.kind(v.i) {
+	case tapeObjectStart:
+		return TokenObjectStart
+	case tapeArrayStart:
+		return TokenArrayStart
+	case tapeString:
+		return TokenString
+	case tapeNumber:
+		return TokenNumber
+	case tapeTrue, tapeFalse:
+		return TokenBoolean
+	case tapeNull:
+		return TokenNull
+	}
+	return TokenUnknown
+}
+
+// Len returns the number of members of an object or elements of an array,
+// and 0 for other values.
+func (v TapeValue) Len() int {
+	if t := v.Type(); t != TokenObjectStart && t != TokenArrayStart {
+		return 0
+	}
+	n := int(v.t.payload(v.i) >> tapeCountShift)
+	if n < tapeCountMax {
+		return n
+	}
+	// 计数已饱和，逐个跳过子值
+	n = 0
+	for i := v.i + 1; v.t.kind(i) != tapeObjectEnd && v.t.kind(i) != tapeArrayEnd; i = v.t.next(i) {
+		if v.t.kind(i) != tapeKey {
+			n++
+		}
+	}
+	return n
+}
+
+// Get returns the member of an object with the given key, the first one if
+// the key is repeated. Other members are skipped in constant time each.
+func (v TapeValue) Get(key string) (TapeValue, bool) {
+	found := TapeValue{}
+	v.Members(func(k string, val TapeValue) bool {
+		if k == key {
+			found = val
+		}
+		return k != key
+	})
+	return found, found.Exists()
+}
+
+// Index returns the element of an array at index i.
+func (v TapeValue) Index(i int) (TapeValue, bool) {
+	found := TapeValue{}
+	v.Elements(func(j int, val TapeValue) bool {
+		if j == i {
+			found = val
+		}
+		return j < i
+	})
+	return found, found.Exists()
+}
+
+// Members calls fn with every member of an object in order until fn returns
+// false.
+func (v TapeValue) Members(fn func(key string, v TapeValue) bool) {
+	if v.Type() != TokenObjectStart {
+		return
+	}
+	var u unescaper
+	var buf []byte
+	for i := v.i + 1; v.t.kind(i) == tapeKey; i = v.t.next(i + 1) {
+		raw := v.t.text(i)
+		key := string(raw)
+		if bytes.IndexByte(raw, '\\') >= 0 {
+			buf = u.finish(u.append(buf[:0], raw))
+			key = string(buf)
+		}
+		if !fn(key, TapeValue{v.t, i + 1}) {
+			return
+		}
+	}
+}
+
+// Elements calls fn with every element of an array in order until fn
+// returns false.
+func (v TapeValue) Elements(fn func(i int, v TapeValue) bool) {
+	if v.Type() != TokenArrayStart {
+		return
+	}
+	for i, n := v.i+1, 0; v.t.kind(i) != tapeArrayEnd; i, n = v.t.next(i), n+1 {
+		if !fn(n, TapeValue{v.t, i}) {
+			return
+		}
+	}
+}
+
+// String returns the decoded value of a string and the JSON text of any other
+// value, or "" for a missing value.
+func (v TapeValue) String() string {
+	if v.Type() == TokenString {
+		return string(v.t.text(v.i))
+	}
+	return string(v.Raw())
+}
+
+// Int returns the value of a number truncated to an integer, or 0 if the
+// value is not a number or out of range.
+func (v TapeValue) Int() int64 {
+	if v.Type() != TokenNumber {
+		return 0
+	}
+	return Result{Type: TokenNumber, Raw: v.t.text(v.i)}.Int()
+}
+
+// Float returns the value of a number, or 0 if the value is not a number.
+func (v TapeValue) Float() float64 {
+	if v.Type() != TokenNumber {
+		return 0
+	}
+	f, _ := strconv.ParseFloat(string(v.t.text(v.i)), 64)
+	return f
+}
+
+// Bool reports whether the value is true.
+func (v TapeValue) Bool() bool {
+	return v.t != nil && v.t.kind(v.i) == tapeTrue
+}
+
+// Raw returns the value as compact JSON, with the original text of numbers
+// and keys, or nil for a missing value.
+func (v TapeValue) Raw() json.RawMessage {
+	if v.t == nil {
+		return nil
+	}
+	return v.AppendJSON(nil)
+}
+
+// AppendJSON appends the value as compact JSON to dst.
+func (v TapeValue) AppendJSON(dst []byte) []byte {
+	if v.t == nil {
+		return dst
+	}
+	t := v.t
+	end := t.next(v.i)
+	for i := v.i; i < end; i++ {
+		k := t.kind(i)
+		if i > v.i && k != tapeObjectEnd && k != tapeArrayEnd {
+			if p := t.kind(i - 1); p != tapeObjectStart && p != tapeArrayStart && p != tapeKey {
+				dst = append(dst, ',')
+			}
+		}
+		switch k {
+		case tapeObjectStart, tapeObjectEnd, tapeArrayStart, tapeArrayEnd:
+			dst = append(dst, k)
+		case tapeKey:
+			dst = append(append(append(dst, '"'), t.text(i)...), '"', ':')
+		case tapeString:
+			dst = jsonstr.AppendQuote(dst, string(t.text(i)))
+		case tapeNumber:
+			dst = append(dst, t.text(i)...)
+		case tapeTrue:
+			dst = append(dst, "true"...)
+		case tapeFalse:
+			dst = append(dst, "false"...)
+		case tapeNull:
+			dst = append(dst, "null"...)
+		}
+	}
+	return dst
+}
@@ -0,0 +1,210 @@
+package jsontokenizer
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+const tapeInput = `{"id":"evt_1","n":-12.5,"ok":true,"none":null,"b\"":"x\ty",
+	"data":{"items":[{"name":"a"},{"name":"bé","tags":["x", "y"]},[],{}],"count":3}}`
+
+func TestTape_Navigate(t *testing.T) {
+	tape, err := BuildTape(strings.NewReader(tapeInput))
+	require.NoError(t, err)
+	root := tape.Root()
+	assert.Equal(t, TokenObjectStart, root.Type())
+	assert.Equal(t, 6, root.Len())
+
+	id, ok := root.Get("id")
+	require.True(t, ok)
+	assert.Equal(t, "evt_1", id.String())
+	n, _ := root.Get("n")
+	assert.Equal(t, TokenNumber, n.Type())
+	assert.Equal(t, -12.5, n.Float())
+	assert.Equal(t, int64(-12), n.Int())
+	b, _ := root.Get("ok")
+	assert.True(t, b.Bool())
+	null, _ := root.Get("none")
+	assert.Equal(t, TokenNull, null.Type())
+	esc, ok := root.Get(`b"`)
+	require.True(t, ok)
+	assert.Equal(t, "x\ty", esc.String())
+
+	data, _ := root.Get("data")
+	items, _ := data.Get("items")
+	assert.Equal(t, 4, items.Len())
+	second, ok := items.Index(1)
+	require.True(t, ok)
+	assert.Equal(t, `{"name":"bé","tags":["x","y"]}`, string(second.Raw()))
+	count, _ := data.Get("count")
+	assert.Equal(t, int64(3), count.Int())
+	empty, _ := items.Index(2)
+	assert.Equal(t, "[]", empty.String())
+	assert.Equal(t, 0, empty.Len())
+
+	_, ok = root.Get("missing")
+	assert.False(t, ok)
+	missing, ok := items.Index(4)
+	assert.False(t, ok)
+	assert.False(t, missing.Exists())
+	assert.Equal(t, TokenUnknown, missing.Type())
+	assert.Equal(t, "", missing.String())
+	_, ok = id.Get("x")
+	assert.False(t, ok)
+
+	var keys []string
+	root.Members(func(k string, _ TapeValue) bool {
+		keys = append(keys, k)
+		return k != "none"
+	})
+	assert.Equal(t, []string{"id", "n", "ok", "none"}, keys)
+}
+
+func TestTape_RawMatchesCompact(t *testing.T) {
+	tape, err := BuildTape(strings.NewReader(tapeInput))
+	require.NoError(t, err)
+	var want strings.Builder
+	require.NoError(t, Minify(strings.NewReader(tapeInput), &want))
+	// 字符串以解码后的值重新编码，原文中的 \t 保持不变
+	assert.Equal(t, want.String(), string(tape.Root().Raw()))
+
+	for _, in := range []string{`"s"`, `0`, `true`, `null`, `[]`, `{}`} {
+		tape, err := BuildTape(strings.NewReader(in))
+		require.NoError(t, err)
+		assert.Equal(t, in, string(tape.Root().Raw()))
+	}
+}
+
+func TestTape_WalkMatchesWalker(t *testing.T) {
+	inputs := []string{tapeInput, `[[],[1,[2,[3]]],{"a":[{}]}]`, `{"":{"":[1,{}]}}`, `"root"`, `7`}
+	for _, in := range inputs {
+		want := &recordingVisitor{}
+		w := NewWalker(want)
+		_, _ = w.Write([]byte(in))
+		_ = w.Close()
+
+		tape, err := BuildTape(strings.NewReader(in))
+		require.NoError(t, err)
+		for range 2 {
+			got := &recordingVisitor{}
+			tape.Walk(got)
+			assert.Equal(t, want.events, got.events, in)
+		}
+	}
+}
+
+func TestTape_SaturatedLen(t *testing.T) {
+	tape, err := BuildTape(strings.NewReader(`[0,[1,2],{"a":3}]`))
+	require.NoError(t, err)
+	// 模拟子值过多导致计数饱和，此时 Len 逐个跳过子值
+	tape.entries[0] |= tapeCountMax << tapeCountShift
+	assert.Equal(t, 3, tape.Root().Len())
+	last, ok := tape.Root().Index(2)
+	require.True(t, ok)
+	assert.Equal(t, `{"a":3}`, last.String())
+}
+
+func TestTape_Errors(t *testing.T) {
+	for _, in := range []string{``, `{"a":}`, `[1,2`, `{} {}`, `[1] x`} {
+		_, err := BuildTape(strings.NewReader(in))
+		assert.Error(t, err, in)
+	}
+}
+
+func TestTape_Binary(t *testing.T) {
+	tape, err := BuildTape(strings.NewReader(tapeInput))
+	require.NoError(t, err)
+	data, err := tape.MarshalBinary()
+	require.NoError(t, err)
+
+	var got Tape
+	require.NoError(t, got.UnmarshalBinary(data))
+	assert.Equal(t, tape.Root().Raw(), got.Root().Raw())
+
+	assert.ErrorIs(t, got.UnmarshalBinary([]byte("nope")), ErrBadTape)
+	assert.ErrorIs(t, got.UnmarshalBinary(data[:len(tapeMagic)+2]), ErrBadTape)
+	bad := append([]byte(nil), data...)
+	bad[len(tapeMagic)] = 9
+	assert.ErrorIs(t, got.UnmarshalBinary(bad), ErrBadTape)
+}
+
+// visitTape 读取磁带中的每个值
+func visitTape(v TapeValue) {
+	_ = v.String()
+	_ = v.Int()
+	_ = v.Len()
+	v.Members(func(_ string, m TapeValue) bool {
+		visitTape(m)
+		return true
+	})
+	v.Elements(func(_ int, e TapeValue) bool {
+		visitTape(e)
+		return true
+	})
+}
+
+func TestTape_CorruptBinary(t *testing.T) {
+	tape, err := BuildTape(strings.NewReader(tapeInput))
+	require.NoError(t, err)
+	data, err := tape.MarshalBinary()
+	require.NoError(t, err)
+
+	// 损坏的数据要么被拒绝，要么仍能安全地读取
+	check := func(bad []byte) {
+		var got Tape
+		if err := got.UnmarshalBinary(bad); err != nil {
+			assert.ErrorIs(t, err, ErrBadTape)
+			return
+		}
+		assert.NotPanics(t, func() {
+			got.Walk(NopVisitor{})
+			visitTape(got.Root())
+		})
+	}
+	for i := range data {
+		for _, mask := range []byte{0x01, 0x10, 0x80, 0xff} {
+			bad := append([]byte(nil), data...)
+			bad[i] ^= mask
+			check(bad)
+		}
+		check(data[:i])
+	}
+
+	// 条目完整但内容超出旁路缓冲区
+	var got Tape
+	require.NoError(t, got.UnmarshalBinary(data))
+	truncated := data[:len(data)-len(got.side)/2]
+	assert.ErrorIs(t, got.UnmarshalBinary(truncated), ErrBadTape)
+}
+
+func BenchmarkTape(b *testing.B) {
+	var sb strings.Builder
+	sb.WriteByte('[')
+	for i := range 1000 {
+		if i > 0 {
+			sb.WriteByte(',')
+		}
+		fmt.Fprintf(&sb, `{"id":%d,"name":"user %d","tags":["a","b","c"],"meta":{"x":[1,2,3]}}`, i, i)
+	}
+	sb.WriteByte(']')
+	input := sb.String()
+	tape, err := BuildTape(strings.NewReader(input))
+	require.NoError(b, err)
+
+	b.Run("build", func(b *testing.B) {
+		b.SetBytes(int64(len(input)))
+		for b.Loop() {
+			_, _ = BuildTape(strings.NewReader(input))
+		}
+	})
+	b.Run("index", func(b *testing.B) {
+		for b.Loop() {
+			v, _ := tape.Root().Index(999)
+			_, _ = v.Get("name")
+		}
+	})
+}
@@ -3,9 +3,9 @@
 package jsontokenizer
 
 import (
-	"fmt"
+	"bytes"
 	"strconv"
-	"strings"
+	"unicode/utf8"
 )
 
 // state 表示解析器的当前状态
@@ -42,13 +42,43 @@ const (
 	TokenColon                         // 冒号分隔符 ':'
 	TokenQuote                         // 引号 '"'
 	TokenWhitespace                    // 空白字符
+	TokenStringChunk                   // 合并模式下尚未结束的字符串值的一部分
 )
 
+var tokenTypeNames = [...]string{
+	TokenUnknown:      "Unknown",
+	TokenString:       "String",
+	TokenStringEscape: "StringEscape",
+	TokenNumber:       "Number",
+	TokenBoolean:      "Boolean",
+	TokenNull:         "Null",
+	TokenObjectStart:  "ObjectStart",
+	TokenObjectEnd:    "ObjectEnd",
+	TokenArrayStart:   "ArrayStart",
+	TokenArrayEnd:     "ArrayEnd",
+	TokenKey:          "Key",
+	TokenKeyEscape:    "KeyEscape",
+	TokenComma:        "Comma",
+	TokenColon:        "Colon",
+	TokenQuote:        "Quote",
+	TokenWhitespace:   "Whitespace",
+	TokenStringChunk:  "StringChunk",
+}
+
+// String returns the name of the token type without the Token prefix.
+func (t TokenType) String() string {
+	if t < 0 || int(t) >= len(tokenTypeNames) {
+		return "TokenType(" + strconv.Itoa(int(t)) + ")"
+	}
+	return tokenTypeNames[t]
+}
+
 // container 表示JSON中的容器结构（对象或数组）
 type container struct {
 	Type       containerType // 容器类型（对象或数组）
 	ArrayIndex int           // 仅用于数组，表示当前索引
-	Key        string        // 仅用于对象，表示当前键名
+	Key        []byte        // 仅用于对象，表示当前键名（原始文本），设置后内容不再改变
+	ExpectKey  bool          // 仅用于对象，表示下一个字符串是键名
 }
 
 func (c *container) IsArray() bool {
@@ -66,13 +96,7 @@ func (c *container) IsObject() bool {
 }
 
 func (c *container) IsEmpty() bool {
-	return c.Type == containerTypeObject && c.Key == "" || c.Type == containerTypeArray && c.ArrayIndex < 0
-}
-
-func (c *container) SetKey(s string) {
-	if c != nil {
-		c.Key = s
-	}
+	return c.Type == containerTypeObject && c.ExpectKey || c.Type == containerTypeArray && c.ArrayIndex < 0
 }
 
 func (c *container) SetArrayIndex(i int) {
@@ -99,18 +123,21 @@ type event struct {
 // innerTokenizer 是JSON流式解析器的主要结构
 // 使用状态机模式逐个字符解析JSON
 type innerTokenizer struct {
-	state          state       // 当前解析状态
-	stack          []container // 容器栈，用于跟踪嵌套结构
-	buffer         []rune      // 临时缓冲区，用于累积字符
-	escapeNext     bool        // 标记下一个字符是否为转义字符
-	pathCache      string      // 路径缓存，用于性能优化
-	pathCacheDirty bool        // 标记路径缓存是否需要更新
+	state          state             // 当前解析状态
+	stack          []container       // 容器栈，用于跟踪嵌套结构
+	buffer         []byte            // 临时缓冲区，用于累积字符（UTF-8）
+	escapeNext     bool              // 标记下一个字符是否为转义字符
+	pathCache      string            // 路径缓存，用于性能优化
+	pathCacheDirty bool              // 标记路径缓存是否需要更新
+	skipPath       bool              // 不构建路径，事件的Path为空
+	keys           map[string][]byte // 已出现过的键名，容器中的键名共享这些不可变的切片
 }
 
 // newInnerTokenizer 创建一个新的JSON解析器实例
 func newInnerTokenizer() *innerTokenizer {
 	return &innerTokenizer{
-		state: stateIdle,
+		state:     stateIdle,
+		pathCache: "$",
 	}
 }
 
@@ -136,6 +163,16 @@ func (p *innerTokenizer) Push(r rune) event {
 	return event
 }
 
+// reset 将解析器恢复到初始状态，保留已分配的缓冲区
+func (p *innerTokenizer) reset() {
+	p.state = stateIdle
+	p.stack = p.stack[:0]
+	p.buffer = p.buffer[:0]
+	p.escapeNext = false
+	p.pathCache = "$"
+	p.pathCacheDirty = false
+}
+
 func (p *innerTokenizer) resetState() {
 	p.state = stateIdle
 }
@@ -155,7 +192,6 @@ func (p *innerTokenizer) peekStack() *container {
 	if len(p.stack) == 0 {
 		return nil
 	}
-	p.pathCacheDirty = true
 	return &p.stack[len(p.stack)-1]
 }
 
@@ -167,11 +203,11 @@ func (p *innerTokenizer) pushStack(c container) {
 func (p *innerTokenizer) handleIdleState(r rune) event {
 	switch r {
 	case '{':
-		p.pushStack(container{Type: containerTypeObject})
+		p.pushStack(container{Type: containerTypeObject, ExpectKey: true})
 		return event{
 			Char: r,
 			Type: TokenObjectStart,
-			Path: p.buildPath(),
+			Path: p.getPathCache(),
 		}
 	case '}':
 		p.popStack()
@@ -180,10 +216,10 @@ func (p *innerTokenizer) handleIdleState(r rune) event {
 		return event{
 			Char: r,
 			Type: TokenObjectEnd,
-			Path: p.buildPath(),
+			Path: p.getPathCache(),
 		}
 	case '[':
-		path := p.buildPath()
+		path := p.getPathCache()
 		p.pushStack(container{Type: containerTypeArray})
 		return event{
 			Char: r,
@@ -197,11 +233,11 @@ func (p *innerTokenizer) handleIdleState(r rune) event {
 		return event{
 			Char: r,
 			Type: TokenArrayEnd,
-			Path: p.buildPath(),
+			Path: p.getPathCache(),
 		}
 	case '"':
-		p.buffer = []rune{}
-		if p.peekStack().IsObject() && p.peekStack().Key == "" {
+		p.resetBuffer()
+		if p.peekStack().IsObject() && p.peekStack().ExpectKey {
 			p.state = stateKey
 		} else {
 			p.state = stateString
@@ -209,7 +245,7 @@ func (p *innerTokenizer) handleIdleState(r rune) event {
 		return event{
 			Char: r,
 			Type: TokenQuote,
-			Path: p.buildPath(),
+			Path: p.getPathCache(),
 		}
 	case ':':
 		p.resetState()
@@ -217,7 +253,7 @@ func (p *innerTokenizer) handleIdleState(r rune) event {
 		return event{
 			Char: r,
 			Type: TokenColon,
-			Path: p.buildPath(),
+			Path: p.getPathCache(),
 		}
 	case ',':
 		p.resetState()
@@ -225,18 +261,20 @@ func (p *innerTokenizer) handleIdleState(r rune) event {
 		if p.peekStack().IsArray() {
 			p.peekStack().ArrayIndex++
 		} else if p.peekStack().IsObject() {
-			p.peekStack().Key = ""
+			p.peekStack().Key = nil
+			p.peekStack().ExpectKey = true
 		}
+		p.pathCacheDirty = true
 		return event{
 			Char: r,
 			Type: TokenComma,
-			Path: p.buildPath(),
+			Path: p.getPathCache(),
 		}
 	case ' ', '\t', '\n', '\r':
 		return event{
 			Char: r,
 			Type: TokenWhitespace,
-			Path: p.buildPath(),
+			Path: p.getPathCache(),
 		}
 	default:
 		return p.handleValueStart(r)
@@ -246,7 +284,7 @@ func (p *innerTokenizer) handleIdleState(r rune) event {
 func (p *innerTokenizer) handleStrState(r rune, isKey bool) event {
 	if p.escapeNext {
 		p.escapeNext = false
-		p.buffer = append(p.buffer, r)
+		p.buffer = utf8.AppendRune(p.buffer, r)
 		var eventType TokenType
 		if isKey {
 			eventType = TokenKey
@@ -264,7 +302,9 @@ func (p *innerTokenizer) handleStrState(r rune, isKey bool) event {
 	case '"':
 		path := p.getPathCache()
 		if isKey {
-			p.peekStack().SetKey(string(p.buffer))
+			p.peekStack().Key = p.internKey(p.buffer)
+			p.peekStack().ExpectKey = false
+			p.pathCacheDirty = true
 		}
 		p.resetState()
 		return event{
@@ -273,7 +313,7 @@ func (p *innerTokenizer) handleStrState(r rune, isKey bool) event {
 			Path: path,
 		}
 	case '\\':
-		p.buffer = append(p.buffer, r)
+		p.buffer = utf8.AppendRune(p.buffer, r)
 		if p.escapeNext {
 			p.escapeNext = false
 		} else {
@@ -289,7 +329,7 @@ func (p *innerTokenizer) handleStrState(r rune, isKey bool) event {
 			Path: p.getPathCache(),
 		}
 	default:
-		p.buffer = append(p.buffer, r)
+		p.buffer = utf8.AppendRune(p.buffer, r)
 		var eventType TokenType
 		if isKey {
 			eventType = TokenKey
@@ -305,8 +345,8 @@ func (p *innerTokenizer) handleStrState(r rune, isKey bool) event {
 }
 
 func (p *innerTokenizer) handleNumberState(r rune) event {
-	if isDigit(r) || r == '.' || r == 'e' || r == 'E' || r == '+' || r == '-' {
-		p.buffer = append(p.buffer, r)
+	if isNumberChar(r) {
+		p.buffer = utf8.AppendRune(p.buffer, r)
 		return event{
 			Char: r,
 			Type: TokenNumber,
@@ -321,7 +361,7 @@ func (p *innerTokenizer) handleNumberState(r rune) event {
 
 func (p *innerTokenizer) handleKeywordState(r rune) event {
 	if isKeywordChar(r) {
-		p.buffer = append(p.buffer, r)
+		p.buffer = utf8.AppendRune(p.buffer, r)
 		var eventType TokenType
 		switch p.state {
 		case stateBoolean:
@@ -348,7 +388,7 @@ func (p *innerTokenizer) handleKeywordState(r rune) event {
 func (p *innerTokenizer) handleValueStart(r rune) event {
 	setBuffer := func(r rune) {
 		p.resetBuffer()
-		p.buffer = append(p.buffer, r)
+		p.buffer = utf8.AppendRune(p.buffer, r)
 	}
 	switch {
 	case isDigit(r) || r == '-':
@@ -393,7 +433,31 @@ func (p *innerTokenizer) handleValueStart(r rune) event {
 	}
 }
 
+// maxInternedKeys 限制 internKey 记住的键名数量，键名各不相同的文档不会无限占用内存
+const maxInternedKeys = 1024
+
+// internKey 返回与 b 内容相同的不可变切片，重复出现的键名不再分配内存
+func (p *innerTokenizer) internKey(b []byte) []byte {
+	if key, ok := p.keys[string(b)]; ok {
+		return key
+	}
+	key := bytes.Clone(b)
+	if key == nil {
+		key = []byte{}
+	}
+	if len(p.keys) < maxInternedKeys {
+		if p.keys == nil {
+			p.keys = make(map[string][]byte)
+		}
+		p.keys[string(key)] = key
+	}
+	return key
+}
+
 func (p *innerTokenizer) getPathCache() string {
+	if p.skipPath {
+		return ""
+	}
 	if !p.pathCacheDirty {
 		return p.pathCache
 	}
@@ -408,20 +472,29 @@ func (p *innerTokenizer) buildPath() string {
 	if len(p.stack) == 0 {
 		return "$"
 	}
-	path := strings.Builder{}
-	path.WriteString("$")
+	path := make([]byte, 0, 32)
+	path = append(path, '$')
 	for _, c := range p.stack {
 		if c.IsEmpty() {
 			continue
 		}
 		if c.IsObject() {
-			path.WriteRune('.')
-			path.WriteString(c.Key)
+			path = appendPathKey(path, c.Key)
 		} else if c.IsArray() {
-			path.WriteString(fmt.Sprintf("[%d]", c.ArrayIndex))
+			path = append(path, '[')
+			path = strconv.AppendInt(path, int64(c.ArrayIndex), 10)
+			path = append(path, ']')
 		}
 	}
-	return path.String()
+	return string(path)
+}
+
+// appendPathKey 追加路径中的键名，空键名写作 [""]
+func appendPathKey(path, key []byte) []byte {
+	if len(key) == 0 {
+		return append(path, `[""]`...)
+	}
+	return append(append(path, '.'), key...)
 }
 
 // isDigit 检查字符是否为数字
@@ -440,6 +513,18 @@ type Tokenizer struct {
 	inner      *innerTokenizer
 	autoEscape bool
 	escaping   bool // Whether to escape strings automatically
+
+	pending []byte  // Trailing bytes of an incomplete UTF-8 character held back by Feed
+	out     []Token // Tokens produced by the current Feed call
+	acc     []byte  // Val of the last token in out, still open for coalescing
+	accOpen bool    // Whether acc holds the Val of the last token in out
+
+	coalesce     bool    // Whether Feed reports one token per lexical element
+	stringChunks bool    // Whether unterminated strings are reported at the end of Feed
+	elem         element // Lexical element being accumulated in coalesced mode
+
+	embedded []string     // Paths of string values holding nested JSON
+	nested   *embeddedDoc // Nested document of the string being tokenized
 }
 
 // NewTokenizer creates a new Parser instance.
@@ -460,6 +545,7 @@ type Token struct {
 	Val  string    // The string value of the event
 	Type TokenType // The type of the event
 	Path string    // The JSON Pointer path of the event
+	Raw  string    // The source text of the event, only set in coalesced mode
 }
 
 func fromInnerToken(e event) *Token {
@@ -484,13 +570,10 @@ func (p *Tokenizer) Push(r rune) *Token {
 	}
 
 	if e.Type == TokenString && p.escaping {
-		p.buf = append(p.buf, r)
-		unescaped, err := strconv.Unquote(`"` + string(p.buf) + `"`)
-		if err != nil {
+		unescaped, ok := p.unescape(r)
+		if !ok {
 			return nil
 		}
-		p.escaping = false
-		p.buf = p.buf[:0] // Clear the buffer after unescaping
 		return &Token{
 			Val:  unescaped,
 			Type: e.Type,
@@ -499,3 +582,16 @@ func (p *Tokenizer) Push(r rune) *Token {
 	}
 	return fromInnerToken(e)
 }
+
+// unescape appends r to the pending escape sequence and returns the decoded
+// text once the sequence is complete.
+func (p *Tokenizer) unescape(r rune) (string, bool) {
+	p.buf = append(p.buf, r)
+	unescaped, err := strconv.Unquote(`"` + string(p.buf) + `"`)
+	if err != nil {
+		return "", false
+	}
+	p.escaping = false
+	p.buf = p.buf[:0] // Clear the buffer after unescaping
+	return unescaped, true
+}
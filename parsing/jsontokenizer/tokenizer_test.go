@@ -1,6 +1,7 @@
 package jsontokenizer
 
 import (
+	"slices"
 	"strings"
 	"testing"
 
@@ -283,3 +284,29 @@ func TestNestedCatch(t *testing.T) {
 	}
 	assert.Equal(t, "李四", nameBuilder.String())
 }
+
+// TestParser_StackKeysImmutable 测试容器栈的浅拷贝中的键名不会被之后的输入覆盖
+func TestParser_StackKeysImmutable(t *testing.T) {
+	tk := NewTokenizer()
+	tk.Feed([]byte(`{"abc":{"xyz":1`))
+	saved := slices.Clone(tk.inner.stack)
+	require.Len(t, saved, 2)
+
+	tk.Feed([]byte(`},"de":{"q":{"r":2}}}`))
+	assert.Equal(t, "abc", string(saved[0].Key))
+	assert.Equal(t, "xyz", string(saved[1].Key))
+}
+
+// TestParser_EmptyKeyPath 测试空键名出现在路径中
+func TestParser_EmptyKeyPath(t *testing.T) {
+	tk := NewTokenizer()
+	tk.Coalesce()
+	var paths []string
+	for _, tok := range append(tk.Feed([]byte(`{"a":{"":"s","b":{"":[1]}}}`)), tk.Flush()...) {
+		switch tok.Type {
+		case TokenString, TokenNumber:
+			paths = append(paths, tok.Path)
+		}
+	}
+	assert.Equal(t, []string{`$.a[""]`, `$.a.b[""][0]`}, paths)
+}
@@ -0,0 +1,132 @@
+package jsontokenizer
+
+import (
+	"bytes"
+	"unicode/utf16"
+	"unicode/utf8"
+)
+
+// unescaper 增量解码JSON字符串内容中的转义序列
+// 输入可以在任意字节处被截断，未完成的转义序列会保留到下一次调用
+type unescaper struct {
+	seq []byte // 尚未完成的转义序列，包含开头的反斜杠
+	hi  rune   // 等待低位代理项的高位代理项，0 表示没有
+}
+
+// append 解码原始字符串内容 raw 并追加到 dst
+func (u *unescaper) append(dst, raw []byte) []byte {
+	for len(raw) > 0 {
+		if len(u.seq) == 0 {
+			i := bytes.IndexByte(raw, '\\')
+			if i < 0 {
+				i = len(raw)
+			}
+			if i > 0 {
+				dst = u.flushSurrogate(dst)
+				dst = append(dst, raw[:i]...)
+				raw = raw[i:]
+				continue
+			}
+		}
+		u.seq = append(u.seq, raw[0])
+		raw = raw[1:]
+		dst = u.step(dst)
+	}
+	return dst
+}
+
+// idle 判断当前是否没有未完成的转义序列
+func (u *unescaper) idle() bool {
+	return len(u.seq) == 0 && u.hi == 0
+}
+
+// finish 在字符串结束时输出所有未完成的内容
+// 孤立的高位代理项输出为 U+FFFD，截断的转义序列按原样输出
+func (u *unescaper) finish(dst []byte) []byte {
+	dst = u.flushSurrogate(dst)
+	dst = append(dst, u.seq...)
+	u.seq = u.seq[:0]
+	return dst
+}
+
+// step 在转义序列新增一个字节后尝试完成解码
+func (u *unescaper) step(dst []byte) []byte {
+	if len(u.seq) < 2 {
+		return dst
+	}
+	if u.seq[1] != 'u' {
+		dst = u.flushSurrogate(dst)
+		switch u.seq[1] {
+		case '"', '\\', '/':
+			dst = append(dst, u.seq[1])
+		case 'b':
+			dst = append(dst, '\b')
+		case 'f':
+			dst = append(dst, '\f')
+		case 'n':
+			dst = append(dst, '\n')
+		case 'r':
+			dst = append(dst, '\r')
+		case 't':
+			dst = append(dst, '\t')
+		default:
+			// 非法转义，按原样输出
+			dst = append(dst, u.seq...)
+		}
+		u.seq = u.seq[:0]
+		return dst
+	}
+	if len(u.seq) < 6 {
+		return dst
+	}
+
+	r, ok := parseHex4(u.seq[2:6])
+	switch {
+	case !ok:
+		dst = u.flushSurrogate(dst)
+		dst = append(dst, u.seq...)
+	case r >= 0xD800 && r < 0xDC00:
+		dst = u.flushSurrogate(dst)
+		u.hi = r
+	case utf16.IsSurrogate(r):
+		if u.hi != 0 {
+			dst = utf8.AppendRune(dst, utf16.DecodeRune(u.hi, r))
+			u.hi = 0
+		} else {
+			dst = utf8.AppendRune(dst, utf8.RuneError)
+		}
+	default:
+		dst = u.flushSurrogate(dst)
+		dst = utf8.AppendRune(dst, r)
+	}
+	u.seq = u.seq[:0]
+	return dst
+}
+
+// flushSurrogate 将没有配对的高位代理项输出为 U+FFFD
+func (u *unescaper) flushSurrogate(dst []byte) []byte {
+	if u.hi == 0 {
+		return dst
+	}
+	u.hi = 0
+	return utf8.AppendRune(dst, utf8.RuneError)
+}
+
+// parseHex4 解析 \u 转义中的四位十六进制数
+func parseHex4(b []byte) (rune, bool) {
+	var r rune
+	for _, c := range b {
+		switch {
+		case c >= '0' && c <= '9':
+			c -= '0'
+		case c >= 'a' && c <= 'f':
+			c = c - 'a' + 10
+		case c >= 'A' && c <= 'F':
+			c = c - 'A' + 10
+		default:
+			return 0, false
+		}
+		r = r<<4 | rune(c)
+	}
+	return r, true
+}
@@ -0,0 +1,36 @@
+package jsontokenizer
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestUnescaper(t *testing.T) {
+	cases := map[string]string{
+		`plain`:      "plain",
+		`a\"b\\c\/d`: `a"b\c/d`,
+		`\b\f\n\r\t`: "\b\f\n\r\t",
+		`Aé中`:        "Aé中",
+		`🚀`:          "🚀",
+		`\ud83dx`:    "�x",
+		`\ude80`:     "�",
+		`\q\u12`:     `\q\u12`,
+		`\uzzzz`:     `\uzzzz`,
+		`\ud83dA`:    "�A",
+		`中文\n`:       "中文\n",
+	}
+	for raw, want := range cases {
+		var u unescaper
+		got := u.finish(u.append(nil, []byte(raw)))
+		assert.Equal(t, want, string(got), "raw %q", raw)
+
+		// 逐字节输入的结果应与整体输入一致
+		var bu unescaper
+		var dst []byte
+		for i := range len(raw) {
+			dst = bu.append(dst, []byte(raw[i:i+1]))
+		}
+		assert.Equal(t, want, string(bu.finish(dst)), "raw %q byte by byte", raw)
+	}
+}
@@ -0,0 +1,220 @@
+package jsontokenizer
+
+import "strconv"
+
+// Visitor receives value-level parsing events from a Walker.
+//
+// The PathView and byte slices passed to a method are borrowed from the
+// Walker and are only valid during the call; copy them to retain them. A
+// string value is reported as OnStringStart, zero or more OnStringChunk calls
+// carrying its decoded content, and OnStringEnd.
+type Visitor interface {
+	OnObjectStart(path PathView)
+	OnObjectEnd(path PathView)
+	OnArrayStart(path PathView)
+	OnArrayEnd(path PathView)
+	OnKey(path PathView, key []byte)
+	OnStringStart(path PathView)
+	OnStringChunk(path PathView, chunk []byte)
+	OnStringEnd(path PathView)
+	OnNumber(path PathView, num []byte)
+	OnBool(path PathView, v bool)
+	OnNull(path PathView)
+}
+
+// NopVisitor implements Visitor with methods that do nothing. Embed it to
+// implement only the events of interest.
+type NopVisitor struct{}
+
+func (NopVisitor) OnObjectStart(PathView)         {}
+func (NopVisitor) OnObjectEnd(PathView)           {}
+func (NopVisitor) OnArrayStart(PathView)          {}
+func (NopVisitor) OnArrayEnd(PathView)            {}
+func (NopVisitor) OnKey(PathView, []byte)         {}
+func (NopVisitor) OnStringStart(PathView)         {}
+func (NopVisitor) OnStringChunk(PathView, []byte) {}
+func (NopVisitor) OnStringEnd(PathView)           {}
+func (NopVisitor) OnNumber(PathView, []byte)      {}
+func (NopVisitor) OnBool(PathView, bool)          {}
+func (NopVisitor) OnNull(PathView)                {}
+
+// PathView is a borrowed view of the path of a value, such as $.users[0].name.
+// Segment 0 is the outermost one. Keys are reported as they appear in the
+// input, escapes intact, matching the paths reported by Tokenizer.
+type PathView struct {
+	stack []container
+}
+
+// Len returns the number of segments in the path.
+func (v PathView) Len() int {
+	return len(v.stack)
+}
+
+// IsIndex reports whether segment i is an array index rather than a key.
+func (v PathView) IsIndex(i int) bool {
+	return v.stack[i].IsArray()
+}
+
+// Index returns the array index of segment i.
+func (v PathView) Index(i int) int {
+	return v.stack[i].ArrayIndex
+}
+
+// Key returns the object key of segment i.
+func (v PathView) Key(i int) []byte {
+	return v.stack[i].Key
+}
+
+// AppendTo appends the string form of the path to dst.
+func (v PathView) AppendTo(dst []byte) []byte {
+	dst = append(dst, '$')
+	for _, c := range v.stack {
+		if c.IsEmpty() {
+			continue
+		}
+		if c.IsObject() {
+			dst = appendPathKey(dst, c.Key)
+		} else {
+			dst = append(dst, '[')
+			dst = strconv.AppendInt(dst, int64(c.ArrayIndex), 10)
+			dst = append(dst, ']')
+		}
+	}
+	return dst
+}
+
+// String returns the path in the same form as Token.Path. It allocates.
+func (v PathView) String() string {
+	return string(v.AppendTo(nil))
+}
+
+// Walker drives a Visitor from a stream of JSON bytes written to it. After
+// its buffers have grown to fit the input, a Walker allocates nothing.
+type Walker struct {
+	inner   *innerTokenizer
+	v       Visitor
+	pending []byte    // 不完整的 UTF-8 字符
+	str     TokenType // 正在处理的字符串类型（TokenKey 或 TokenString）
+	key     []byte    // 解码后的键名
+	chunk   []byte    // 解码后的字符串片段
+	unes    unescaper // 字符串转义解码状态
+	scalar  []byte    // 正在累积的数字或关键字
+	scalarT TokenType // scalar 的类型，TokenUnknown 表示没有
+}
+
+// NewWalker creates a Walker that reports events to v.
+func NewWalker(v Visitor) *Walker {
+	inner := newInnerTokenizer()
+	inner.skipPath = true
+	return &Walker{inner: inner, v: v}
+}
+
+// Write processes p, invoking the Visitor for every event it completes. It
+// never fails; malformed input is skipped.
+func (w *Walker) Write(p []byte) (int, error) {
+	w.pending = w.inner.feedUTF8(w.pending, p, w)
+	return len(p), nil
+}
+
+// Close reports the value still pending at the end of the input, such as a
+// trailing number.
+func (w *Walker) Close() error {
+	w.valueEnd()
+	return nil
+}
+
+// Reset prepares the Walker for a new input, keeping its buffers.
+func (w *Walker) Reset() {
+	w.inner.reset()
+	w.pending = w.pending[:0]
+	w.str = TokenUnknown
+	w.key = w.key[:0]
+	w.unes = unescaper{seq: w.unes.seq[:0]}
+	w.scalar = w.scalar[:0]
+	w.scalarT = TokenUnknown
+}
+
+// view 返回当前值的路径
+func (w *Walker) view() PathView {
+	return PathView{stack: w.inner.stack}
+}
+
+// outerView 返回栈顶容器自身的路径
+func (w *Walker) outerView() PathView {
+	if len(w.inner.stack) == 0 {
+		return PathView{}
+	}
+	return PathView{stack: w.inner.stack[:len(w.inner.stack)-1]}
+}
+
+// emit implements runSink.
+func (w *Walker) emit(t TokenType, _ string, val []byte) {
+	switch t {
+	case TokenObjectStart:
+		w.v.OnObjectStart(w.outerView())
+	case TokenObjectEnd:
+		w.v.OnObjectEnd(w.view())
+	case TokenArrayStart:
+		w.v.OnArrayStart(w.outerView())
+	case TokenArrayEnd:
+		w.v.OnArrayEnd(w.view())
+	case TokenQuote:
+		w.quote()
+	case TokenString, TokenStringEscape:
+		if w.unes.idle() && t == TokenString {
+			w.v.OnStringChunk(w.view(), val)
+			return
+		}
+		w.chunk = w.unes.append(w.chunk[:0], val)
+		if len(w.chunk) > 0 {
+			w.v.OnStringChunk(w.view(), w.chunk)
+		}
+	case TokenKey, TokenKeyEscape:
+		w.key = w.unes.append(w.key, val)
+	case TokenNumber, TokenBoolean, TokenNull:
+		w.scalarT = t
+		w.scalar = append(w.scalar, val...)
+	case TokenUnknown, TokenComma, TokenColon, TokenWhitespace, TokenStringChunk:
+	}
+}
+
+// quote 处理字符串的开始和结束
+func (w *Walker) quote() {
+	switch w.inner.state {
+	case stateKey:
+		w.str = TokenKey
+		w.key = w.key[:0]
+	case stateString:
+		w.str = TokenString
+		w.v.OnStringStart(w.view())
+	case stateIdle, stateNumber, stateBoolean, stateNull:
+		if w.str == TokenKey {
+			// 键名已写入栈顶容器，事件路径为该对象自身的路径
+			w.key = w.unes.finish(w.key)
+			w.v.OnKey(w.outerView(), w.key)
+		} else {
+			w.chunk = w.unes.finish(w.chunk[:0])
+			if len(w.chunk) > 0 {
+				w.v.OnStringChunk(w.view(), w.chunk)
+			}
+			w.v.OnStringEnd(w.view())
+		}
+		w.str = TokenUnknown
+	}
+}
+
+// valueEnd implements runSink.
+func (w *Walker) valueEnd() {
+	switch {
+	case w.scalarT == TokenNumber:
+		w.v.OnNumber(w.view(), w.scalar)
+	case w.scalarT == TokenBoolean:
+		w.v.OnBool(w.view(), string(w.scalar) == "true")
+	case w.scalarT == TokenNull:
+		w.v.OnNull(w.view())
+	default:
+		return
+	}
+	w.scalar = w.scalar[:0]
+	w.scalarT = TokenUnknown
+}
@@ -0,0 +1,147 @@
+package jsontokenizer
+
+import (
+	"fmt"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// recordingVisitor 将收到的事件记录为字符串，字符串片段合并后在结束时记录
+type recordingVisitor struct {
+	events []string
+	str    []byte
+}
+
+func (r *recordingVisitor) add(format string, args ...any) {
+	r.events = append(r.events, fmt.Sprintf(format, args...))
+}
+
+func (r *recordingVisitor) OnObjectStart(p PathView) { r.add("{ %s", p) }
+func (r *recordingVisitor) OnObjectEnd(p PathView)   { r.add("} %s", p) }
+func (r *recordingVisitor) OnArrayStart(p PathView)  { r.add("[ %s", p) }
+func (r *recordingVisitor) OnArrayEnd(p PathView)    { r.add("] %s", p) }
+func (r *recordingVisitor) OnKey(p PathView, k []byte) {
+	r.add("key %s %q", p, k)
+}
+func (r *recordingVisitor) OnStringStart(PathView)             { r.str = r.str[:0] }
+func (r *recordingVisitor) OnStringChunk(_ PathView, c []byte) { r.str = append(r.str, c...) }
+func (r *recordingVisitor) OnStringEnd(p PathView)             { r.add("str %s %q", p, r.str) }
+func (r *recordingVisitor) OnNumber(p PathView, n []byte) {
+	r.add("num %s %s", p, n)
+}
+func (r *recordingVisitor) OnBool(p PathView, v bool) { r.add("bool %s %t", p, v) }
+func (r *recordingVisitor) OnNull(p PathView)         { r.add("null %s", p) }
+
+func TestWalker_Events(t *testing.T) {
+	json := `{"a":"x\ty","b\"":[1, true,null,{"c":-2.5}],"d":[]}`
+	expected := []string{
+		"{ $",
+		`key $ "a"`,
+		`str $.a "x\ty"`,
+		`key $ "b\""`,
+		`[ $.b\"`,
+		`num $.b\"[0] 1`,
+		`bool $.b\"[1] true`,
+		`null $.b\"[2]`,
+		`{ $.b\"[3]`,
+		`key $.b\"[3] "c"`,
+		`num $.b\"[3].c -2.5`,
+		`} $.b\"[3]`,
+		`] $.b\"`,
+		`key $ "d"`,
+		"[ $.d",
+		"] $.d",
+		"} $",
+	}
+
+	for _, size := range []int{1, 3, len(json)} {
+		v := &recordingVisitor{}
+		w := NewWalker(v)
+		for b := []byte(json); len(b) > 0; {
+			n := min(size, len(b))
+			_, _ = w.Write(b[:n])
+			b = b[n:]
+		}
+		_ = w.Close()
+		assert.Equal(t, expected, v.events, "chunk size %d", size)
+	}
+}
+
+func TestWalker_EmptyKey(t *testing.T) {
+	v := &recordingVisitor{}
+	w := NewWalker(v)
+	_, _ = w.Write([]byte(`{"a":{"":"s"},"":1}`))
+	_ = w.Close()
+	assert.Equal(t, []string{
+		"{ $",
+		`key $ "a"`,
+		"{ $.a",
+		`key $.a ""`,
+		`str $.a[""] "s"`,
+		"} $.a",
+		`key $ ""`,
+		`num $[""] 1`,
+		"} $",
+	}, v.events)
+}
+
+func TestWalker_RootScalarAndClose(t *testing.T) {
+	v := &recordingVisitor{}
+	w := NewWalker(v)
+	_, _ = w.Write([]byte("42"))
+	assert.Empty(t, v.events)
+	_ = w.Close()
+	assert.Equal(t, []string{"num $ 42"}, v.events)
+
+	v.events = nil
+	w.Reset()
+	_, _ = w.Write([]byte(`"🚀"`))
+	assert.Equal(t, []string{`str $ "🚀"`}, v.events)
+}
+
+// countingVisitor 统计事件数量，不保留任何数据
+type countingVisitor struct {
+	NopVisitor
+	values, keys, bytes int
+}
+
+func (c *countingVisitor) OnKey(_ PathView, k []byte)         { c.keys++; c.bytes += len(k) }
+func (c *countingVisitor) OnStringChunk(_ PathView, b []byte) { c.bytes += len(b) }
+func (c *countingVisitor) OnStringEnd(PathView)               { c.values++ }
+func (c *countingVisitor) OnNumber(_ PathView, n []byte)      { c.values++; c.bytes += len(n) }
+func (c *countingVisitor) OnBool(PathView, bool)              { c.values++ }
+func (c *countingVisitor) OnNull(PathView)                    { c.values++ }
+
+func TestWalker_ZeroAlloc(t *testing.T) {
+	data := benchmarkDocument(100)
+	v := &countingVisitor{}
+	w := NewWalker(v)
+	run := func() {
+		w.Reset()
+		_, _ = w.Write(data)
+		_ = w.Close()
+	}
+	run() // 预热缓冲区
+
+	allocs := testing.AllocsPerRun(10, run)
+	assert.Zero(t, allocs)
+	// 预热一次，AllocsPerRun 额外预热一次再运行十次
+	assert.Equal(t, 12*(1+100*11), v.keys)
+}
+
+func BenchmarkWalker(b *testing.B) {
+	data := benchmarkDocument(1000)
+	w := NewWalker(&countingVisitor{})
+	b.SetBytes(int64(len(data)))
+	b.ReportAllocs()
+	for b.Loop() {
+		w.Reset()
+		for chunk := data; len(chunk) > 0; {
+			n := min(32<<10, len(chunk))
+			_, _ = w.Write(chunk[:n])
+			chunk = chunk[n:]
+		}
+		_ = w.Close()
+	}
+}